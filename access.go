@@ -0,0 +1,33 @@
+package foundation
+
+// LinkAccess is the access mode of a link, which restricts who can visit it.
+type LinkAccess string
+
+const (
+	// AccessPublic links can be visited by everyone.
+	AccessPublic LinkAccess = "public"
+	// AccessLogin links can only be visited by logged in users.
+	AccessLogin LinkAccess = "login"
+	// AccessPassword links can be visited after entering the link password.
+	AccessPassword LinkAccess = "password"
+)
+
+// LinkAccessModes are the access modes that a link can have.
+var LinkAccessModes = []LinkAccess{AccessPublic, AccessLogin, AccessPassword}
+
+// AccessOptions control who can visit a link.
+type AccessOptions struct {
+	// Access is one of LinkAccessModes. Empty means AccessPublic.
+	Access LinkAccess `bun:"access,notnull" json:"access"`
+	// PasswordHash is the link password of AccessPassword links, hashed
+	// with auth.HashPassword. It is never shown or logged.
+	PasswordHash string `bun:"password_hash,notnull" json:"-"`
+}
+
+// Mode returns the access mode of the link.
+func (o *AccessOptions) Mode() LinkAccess {
+	if o.Access == "" {
+		return AccessPublic
+	}
+	return o.Access
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/foundation"
+)
+
+const (
+	unlockCookiePrefix = "foundation_unlock_"
+	// UnlockDuration is how long a password protected link stays unlocked
+	// after its password was entered.
+	UnlockDuration = time.Hour
+)
+
+var (
+	ErrWrongLinkPassword = errors.New("wrong link password")
+	ErrTooManyAttempts   = errors.New("too many failed attempts, please try again later")
+)
+
+// UnlockLink checks the password of a password protected link and sets a
+// cookie that unlocks the link for UnlockDuration. Failed attempts are
+// rate limited with the login settings, by link and RemoteIP.
+func UnlockLink(r *foundation.Request, link *foundation.Link, password string) error {
+	limitKey := fmt.Sprintf("%s:link:%s", RemoteIP(r), link.ShortLink)
+	if globalRateLimiter.isBlocked(limitKey) {
+		return ErrTooManyAttempts
+	}
+	if len(password) > 1024 || link.PasswordHash == "" {
+		return ErrWrongLinkPassword
+	}
+	ok, err := verifyPassword(password, link.PasswordHash)
+	if err != nil {
+		return err
+	}
+	globalRateLimiter.recordAttempt(r, limitKey, ok)
+	if !ok {
+		return ErrWrongLinkPassword
+	}
+
+	expires := time.Now().Add(UnlockDuration)
+	http.SetCookie(r.Writer, &http.Cookie{
+		Name:     unlockCookieName(link),
+		Value:    unlockToken(link, expires.Unix()),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.Config.TLSEnabled(),
+		SameSite: http.SameSiteLaxMode,
+		Expires:  expires,
+	})
+	return nil
+}
+
+// LinkUnlocked reports whether the request has an unexpired unlock cookie
+// for link. Changing the password of a link locks it again.
+func LinkUnlocked(r *foundation.Request, link *foundation.Link) bool {
+	cookie, err := r.Request.Cookie(unlockCookieName(link))
+	if err != nil || link.PasswordHash == "" {
+		return false
+	}
+	expiresText, _, _ := strings.Cut(cookie.Value, ".")
+	expires, err := strconv.ParseInt(expiresText, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(cookie.Value), []byte(unlockToken(link, expires)))
+}
+
+// unlockCookieName is per link, so that unlocking one link doesn't forget
+// the others. Older short links can contain characters that aren't allowed
+// in cookie names, so the name is derived from a hash of the short link.
+func unlockCookieName(link *foundation.Link) string {
+	sum := sha256.Sum256([]byte(link.ShortLink))
+	return unlockCookiePrefix + base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// unlockToken signs the short link and expiry with the password hash of
+// the link, which only the server knows.
+func unlockToken(link *foundation.Link, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(link.PasswordHash))
+	fmt.Fprintf(mac, "%s|%d", link.ShortLink, expires)
+	return fmt.Sprintf("%d.%s", expires, base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mbertschler/foundation"
+)
+
+func TestUnlockLink(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := &foundation.Link{
+		ShortLink:     "docs@go.example.com",
+		AccessOptions: foundation.AccessOptions{Access: foundation.AccessPassword, PasswordHash: hash},
+	}
+	ctx := &foundation.Context{Context: context.Background(), Config: &foundation.Config{}}
+	newRequest := func() *foundation.Request {
+		return &foundation.Request{
+			Context: ctx,
+			Writer:  httptest.NewRecorder(),
+			Request: httptest.NewRequest("POST", "/docs", nil),
+		}
+	}
+
+	req := newRequest()
+	if err := UnlockLink(req, link, "wrong"); !errors.Is(err, ErrWrongLinkPassword) {
+		t.Fatalf("expected wrong password, got %v", err)
+	}
+	if err := UnlockLink(req, link, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	cookies := req.Writer.(*httptest.ResponseRecorder).Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != unlockCookieName(link) || !cookies[0].HttpOnly {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+
+	visit := newRequest()
+	if LinkUnlocked(visit, link) {
+		t.Error("expected the link to be locked without cookie")
+	}
+	visit.Request.AddCookie(cookies[0])
+	if !LinkUnlocked(visit, link) {
+		t.Error("expected the cookie to unlock the link")
+	}
+
+	other := &foundation.Link{ShortLink: "docs", AccessOptions: link.AccessOptions}
+	if LinkUnlocked(visit, other) {
+		t.Error("expected the cookie to only unlock its link")
+	}
+	changed, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link.PasswordHash = changed
+	if LinkUnlocked(visit, link) {
+		t.Error("expected a new password to lock the link again")
+	}
+}
+
+func TestUnlockLinkRateLimit(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := &foundation.Link{
+		ShortLink:     "ratelimited",
+		AccessOptions: foundation.AccessOptions{Access: foundation.AccessPassword, PasswordHash: hash},
+	}
+	config := &foundation.Config{LoginMaxAttempts: 2}
+	ctx := &foundation.Context{Context: context.Background(), Config: config}
+	newRequest := func(forwardedFor string) *foundation.Request {
+		r := httptest.NewRequest("POST", "/ratelimited", nil)
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		return &foundation.Request{Context: ctx, Writer: httptest.NewRecorder(), Request: r}
+	}
+
+	// the header is ignored, so changing it doesn't avoid the limit
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if err := UnlockLink(newRequest(ip), link, "wrong"); !errors.Is(err, ErrWrongLinkPassword) {
+			t.Fatalf("expected wrong password, got %v", err)
+		}
+	}
+	if err := UnlockLink(newRequest("10.0.0.3"), link, "secret"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected too many attempts, got %v", err)
+	}
+
+	config.TrustProxyHeaders = true
+	if err := UnlockLink(newRequest("10.0.0.3"), link, "secret"); err != nil {
+		t.Fatalf("expected a trusted address to be limited separately, got %v", err)
+	}
+}
+
+func TestUnlockCookieName(t *testing.T) {
+	names := map[string]bool{}
+	for _, key := range []string{"docs", "docs@go.example.com", "über links", "a;b=c"} {
+		name := unlockCookieName(&foundation.Link{ShortLink: key})
+		cookie := &http.Cookie{Name: name, Value: "x"}
+		if err := cookie.Valid(); err != nil {
+			t.Errorf("cookie name %q of %q is invalid: %v", name, key, err)
+		}
+		names[name] = true
+	}
+	if len(names) != 4 {
+		t.Errorf("expected a different cookie name per link, got %v", names)
+	}
+}
+
+func TestLoginRateLimitKey(t *testing.T) {
+	config := &foundation.Config{}
+	ctx := &foundation.Context{Context: context.Background(), Config: config}
+	r := httptest.NewRequest("POST", "/admin/login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req := &foundation.Request{Context: ctx, Writer: httptest.NewRecorder(), Request: r}
+
+	if key := globalRateLimiter.getClientKey(req, "admin"); key != "192.0.2.1:admin" {
+		t.Errorf("expected the connection address, got %q", key)
+	}
+	config.TrustProxyHeaders = true
+	if key := globalRateLimiter.getClientKey(req, "admin"); key != "10.0.0.1:admin" {
+		t.Errorf("expected the forwarded address, got %q", key)
+	}
+}
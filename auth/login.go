@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/metrics"
 )
 
 var (
@@ -11,12 +12,25 @@ var (
 	PasswordFormKey = "password"
 )
 
+var loginAttempts = metrics.NewCounterVec("foundation_login_attempts_total",
+	"Number of login attempts by result.", "result")
+
 const (
 	// somehash is the password "hehehe"
 	someHash = "$argon2id$t=3,m=32768,p=4$32J16ZbXQegxU2CU3nOu/lfkno/g+Sv4pZti9LIgfX0$H6lc+0VxTFkPy9yc7z14tHq0bSYknIqmlj66ST67F+"
 )
 
 func (h *Handler) Login(r *foundation.Request) error {
+	err := h.login(r)
+	if err != nil {
+		loginAttempts.WithLabelValues("failure").Inc()
+		return err
+	}
+	loginAttempts.WithLabelValues("success").Inc()
+	return nil
+}
+
+func (h *Handler) login(r *foundation.Request) error {
 	err := r.Request.ParseForm()
 	if err != nil {
 		return err
@@ -74,7 +88,7 @@ func (h *Handler) Login(r *foundation.Request) error {
 		return err
 	}
 
-	setSessionCookie(r.Writer, session)
+	setSessionCookie(r, session)
 	r.Session = session
 	r.User = user
 	return nil
@@ -99,6 +113,6 @@ func (h *Handler) Logout(r *foundation.Request) error {
 	r.Session = session
 	r.User = nil
 
-	setSessionCookie(r.Writer, session)
+	setSessionCookie(r, session)
 	return nil
 }
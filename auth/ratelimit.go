@@ -3,6 +3,7 @@ package auth
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"sync"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 type RateLimit struct {
 	attempts     int
 	lastAttempt  time.Time
+	window       time.Duration
 	blockedUntil time.Time
 }
 
@@ -45,7 +47,7 @@ func (rl *RateLimiter) cleanup() {
 		now := time.Now()
 		for key, limit := range rl.limits {
 			// Remove entries that are past their block time and window
-			if now.After(limit.blockedUntil) && now.Sub(limit.lastAttempt) > rl.window {
+			if now.After(limit.blockedUntil) && now.Sub(limit.lastAttempt) > limit.window {
 				delete(rl.limits, key)
 			}
 		}
@@ -53,24 +55,57 @@ func (rl *RateLimiter) cleanup() {
 	}
 }
 
+// settings returns the limits from the current config, falling back to the
+// defaults of the rate limiter for unset values.
+func (rl *RateLimiter) settings(r *foundation.Request) (maxAttempts int, window, blockDuration time.Duration) {
+	config := r.Current()
+	maxAttempts, window, blockDuration = rl.maxAttempts, rl.window, rl.blockDuration
+	if config.LoginMaxAttempts > 0 {
+		maxAttempts = config.LoginMaxAttempts
+	}
+	if config.LoginWindow > 0 {
+		window = config.LoginWindow.Duration()
+	}
+	if config.LoginBlockDuration > 0 {
+		blockDuration = config.LoginBlockDuration.Duration()
+	}
+	return maxAttempts, window, blockDuration
+}
+
 func (rl *RateLimiter) getClientKey(r *foundation.Request, username string) string {
-	// Get IP address
-	ip := r.Request.Header.Get("X-Forwarded-For")
+	// Combine IP and username for the key
+	return fmt.Sprintf("%s:%s", RemoteIP(r), username)
+}
+
+// ClientIP returns the IP address of the client, preferring the headers
+// set by a reverse proxy.
+func ClientIP(r *http.Request) string {
+	ip := r.Header.Get("X-Forwarded-For")
 	if ip == "" {
-		ip = r.Request.Header.Get("X-Real-IP")
+		ip = r.Header.Get("X-Real-IP")
 	}
 	if ip == "" {
-		host, _, _ := net.SplitHostPort(r.Request.RemoteAddr)
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
 		ip = host
 	}
+	return ip
+}
 
-	// Combine IP and username for the key
-	return fmt.Sprintf("%s:%s", ip, username)
+// RemoteIP returns the IP address of the connection, or the one from the
+// headers of a reverse proxy if TrustProxyHeaders is set.
+func RemoteIP(r *foundation.Request) string {
+	if r.Current().TrustProxyHeaders {
+		return ClientIP(r.Request)
+	}
+	host, _, _ := net.SplitHostPort(r.Request.RemoteAddr)
+	return host
 }
 
 func (rl *RateLimiter) IsBlocked(r *foundation.Request, username string) bool {
-	key := rl.getClientKey(r, username)
+	return rl.isBlocked(rl.getClientKey(r, username))
+}
 
+func (rl *RateLimiter) isBlocked(key string) bool {
 	rl.mu.RLock()
 	limit, exists := rl.limits[key]
 	rl.mu.RUnlock()
@@ -84,7 +119,11 @@ func (rl *RateLimiter) IsBlocked(r *foundation.Request, username string) bool {
 }
 
 func (rl *RateLimiter) RecordAttempt(r *foundation.Request, username string, success bool) {
-	key := rl.getClientKey(r, username)
+	rl.recordAttempt(r, rl.getClientKey(r, username), success)
+}
+
+func (rl *RateLimiter) recordAttempt(r *foundation.Request, key string, success bool) {
+	maxAttempts, window, blockDuration := rl.settings(r)
 	now := time.Now()
 
 	rl.mu.Lock()
@@ -97,12 +136,13 @@ func (rl *RateLimiter) RecordAttempt(r *foundation.Request, username string, suc
 	}
 
 	// Reset if outside the window
-	if now.Sub(limit.lastAttempt) > rl.window {
+	if now.Sub(limit.lastAttempt) > window {
 		limit.attempts = 0
 		limit.blockedUntil = time.Time{}
 	}
 
 	limit.lastAttempt = now
+	limit.window = window
 
 	if success {
 		// Reset on successful login
@@ -110,8 +150,8 @@ func (rl *RateLimiter) RecordAttempt(r *foundation.Request, username string, suc
 		limit.blockedUntil = time.Time{}
 	} else {
 		limit.attempts++
-		if limit.attempts >= rl.maxAttempts {
-			limit.blockedUntil = now.Add(rl.blockDuration)
+		if limit.attempts >= maxAttempts {
+			limit.blockedUntil = now.Add(blockDuration)
 		}
 	}
 }
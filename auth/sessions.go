@@ -26,7 +26,7 @@ func (h *Handler) GetOrCreateSession(r *foundation.Request) (*foundation.Session
 			// If session was rotated, update the cookie
 			if rotatedSession.ID != session.ID {
 				r.PreviousSession = session // keep previous session for CSRF checks
-				setSessionCookie(r.Writer, rotatedSession)
+				setSessionCookie(r, rotatedSession)
 			}
 			return rotatedSession, nil
 		}
@@ -39,21 +39,21 @@ func (h *Handler) GetOrCreateSession(r *foundation.Request) (*foundation.Session
 		return nil, err
 	}
 
-	setSessionCookie(r.Writer, session)
+	setSessionCookie(r, session)
 	return session, nil
 }
 
-func setSessionCookie(w http.ResponseWriter, session *foundation.Session) {
+func setSessionCookie(r *foundation.Request, session *foundation.Session) {
 	cookie := &http.Cookie{
 		Name:     sessionCookieName,
 		Value:    session.ID,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
+		Secure:   r.Config.TLSEnabled(),
 		SameSite: http.SameSiteLaxMode,
 		Expires:  session.ExpiresAt,
 	}
-	http.SetCookie(w, cookie)
+	http.SetCookie(r.Writer, cookie)
 }
 
 func (h *Handler) getSessionFromRequest(r *foundation.Request) (*foundation.Session, error) {
@@ -3,13 +3,13 @@ package main
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"time"
 
 	"github.com/mbertschler/foundation"
 	"github.com/mbertschler/foundation/service"
-	"github.com/pkg/errors"
 )
 
 // Default configuration values
@@ -36,12 +36,14 @@ func main() {
 
 	flag.StringVar(&configPath, "config", defaultConfigPath, "foundation config JSON file path")
 	flag.BoolVar(&devMode, "dev", false, "dev mode: serve asset files from browser/dist directory instead of Go embedded assets")
+	configFlags := foundation.RegisterConfigFlags(flag.CommandLine)
 	// more flags if needed
+	flag.Usage = usage
 	flag.Parse()
 
-	config, err := loadConfig(configPath)
+	config, err := loadConfig(configPath, configFlags)
 	if err != nil {
-		log.Fatal("failed to load config:", err)
+		log.Fatal("failed to load config: ", err)
 		return
 	}
 
@@ -50,32 +52,135 @@ func main() {
 		log.Fatal("failed to process config:", err)
 		return
 	}
+	if devMode {
+		log.Println("dev mode, serving asset files from disk")
+	}
 
-	exitCode := service.RunApp(config)
-	os.Exit(exitCode)
+	switch flag.Arg(0) {
+	case "":
+		source := &service.ConfigSource{
+			Path: configPath,
+			Load: func() (*foundation.Config, error) {
+				config, err := loadConfig(configPath, configFlags)
+				if err != nil {
+					return nil, err
+				}
+				err = postProcessConfig(config, startup)
+				return config, err
+			},
+		}
+		if !isFlagSet("config") {
+			if _, err := os.Stat(configPath); os.IsNotExist(err) {
+				source.Path = ""
+			}
+		}
+		exitCode := service.RunApp(config, source)
+		os.Exit(exitCode)
+	case "backup":
+		err = service.Backup(config)
+	case "restore":
+		err = service.Restore(config, flag.Arg(1))
+	case "links":
+		err = runLinksCommand(config, flag.Args()[1:])
+	case "config":
+		if flag.Arg(1) != "print" {
+			usage()
+			os.Exit(2)
+		}
+		err = printConfig(config)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s failed: %v", flag.Arg(0), err)
+	}
 }
 
-func loadConfig(path string) (*foundation.Config, error) {
-	config := defaultConfig
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  (none)              run the server")
+	fmt.Fprintln(out, "  backup              write a database snapshot to BackupDir")
+	fmt.Fprintln(out, "  restore [snapshot]  replace the database with a snapshot, the newest if omitted;")
+	fmt.Fprintln(out, "                      the server must not be running")
+	fmt.Fprintln(out, "  config print        print the effective config with secrets masked")
+	fmt.Fprintln(out, "  links export [-tag tag] <file>")
+	fmt.Fprintln(out, "                      export all links, or those with a tag, to a .csv or")
+	fmt.Fprintln(out, "                      .json file")
+	fmt.Fprintln(out, "  links import [-dry-run] [-owner user] <file>")
+	fmt.Fprintln(out, "                      import links from a .csv or .json file, new links")
+	fmt.Fprintln(out, "                      without owner in the file belong to -owner")
+	fmt.Fprintln(out, "\nConfig values are read from the config file, then from FOUNDATION_*")
+	fmt.Fprintln(out, "environment variables like FOUNDATION_HOST_PORT, then from flags.")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
 
-	buf, err := os.ReadFile(path)
-	if err != nil {
-		return nil, errors.Wrap(err, "can't read config file")
+func loadConfig(path string, flags *foundation.ConfigFlags) (*foundation.Config, error) {
+	// the default config file is optional, an explicitly passed one is not
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) && !isFlagSet("config") {
+		path = ""
 	}
-	err = json.Unmarshal(buf, &config)
-	if err != nil {
-		log.Fatal("config file JSON error:", err)
+	return foundation.LoadConfig(defaultConfig, path, os.Environ(), flags)
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func runLinksCommand(config *foundation.Config, args []string) error {
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("links "+args[0], flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only print the changes")
+	owner := fs.String("owner", "", "user name of the owner of new links")
+	tag := fs.String("tag", "", "only export links with this tag")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		usage()
+		os.Exit(2)
 	}
 
-	return &config, nil
+	switch args[0] {
+	case "export":
+		return service.ExportLinks(config, fs.Arg(0), *tag)
+	case "import":
+		return service.ImportLinks(config, fs.Arg(0), *owner, *dryRun)
+	}
+	usage()
+	os.Exit(2)
+	return nil
+}
+
+func printConfig(config *foundation.Config) error {
+	buf, err := json.MarshalIndent(config.Masked(), "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(buf))
+	return nil
 }
 
 func postProcessConfig(config *foundation.Config, startup time.Time) error {
 	config.Startup = startup
 
 	if devMode {
-		log.Println("dev mode, serving asset files from disk")
 		config.DevFileServer = true
+		if config.LogLevel == "" {
+			config.LogLevel = "debug"
+		}
 	}
 	return nil
 }
@@ -1,13 +1,138 @@
 package foundation
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
-// Config is the type definition of the JSON config file.
+// Config is the type definition of the JSON config file. Every field can
+// also be set with a FOUNDATION_* environment variable or a flag, see
+// LoadConfig. Fields tagged with secret are masked when printed, fields
+// tagged with reload are applied without a restart when the config changes.
 type Config struct {
 	HostPort      string
 	DBPath        string
 	LitestreamYml string
 
-	Startup       time.Time
-	DevFileServer bool
+	// MetricsToken enables /metrics on the main server for requests
+	// with an "Authorization: Bearer <MetricsToken>" header.
+	MetricsToken string `secret:"true"`
+	// MetricsHostPort serves /metrics on a separate listener, for
+	// example "localhost:9090", that is not reachable from outside.
+	MetricsHostPort string
+
+	// TLSCertFile and TLSKeyFile enable HTTPS on HostPort with a PEM
+	// encoded certificate and key. Changed files are picked up without
+	// a restart.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSSelfSigned generates a self-signed certificate for development.
+	// It is written to TLSCertFile and TLSKeyFile if they are set and
+	// don't exist yet, otherwise it is only kept in memory.
+	TLSSelfSigned bool
+	// HTTPRedirectHostPort serves plain HTTP on a separate listener, for
+	// example ":80", that redirects all requests to HTTPS.
+	HTTPRedirectHostPort string
+
+	// ShutdownDrain is how long /readyz reports that the server is shutting
+	// down before it stops accepting requests, e.g. "10s", so that load
+	// balancers can take it out of rotation first.
+	ShutdownDrain Duration `reload:"true"`
+
+	// BackupDir enables built-in gzip compressed snapshots of the
+	// database in this directory.
+	BackupDir string
+	// BackupInterval is the time between scheduled snapshots, e.g. "6h".
+	// Without it snapshots are only created on demand.
+	BackupInterval Duration
+	// BackupKeep is the number of newest snapshots that are kept.
+	BackupKeep int
+	// BackupMaxAge deletes snapshots older than this, e.g. "720h".
+	// The newest snapshot is never deleted.
+	BackupMaxAge Duration
+
+	// LogLevel is one of "debug", "info", "warn" or "error". Messages of
+	// the standard log package have level info.
+	LogLevel string `reload:"true"`
+
+	// LoginMaxAttempts failed logins within LoginWindow block further
+	// attempts from the same IP and username for LoginBlockDuration.
+	LoginMaxAttempts   int      `reload:"true"`
+	LoginWindow        Duration `reload:"true"`
+	LoginBlockDuration Duration `reload:"true"`
+	// TrustProxyHeaders rate limits logins and link unlocks by the client
+	// address in the X-Forwarded-For and X-Real-IP headers instead of the
+	// address of the connection. Only set it behind a reverse proxy that sets them,
+	// otherwise clients can choose their address.
+	TrustProxyHeaders bool `reload:"true"`
+
+	// SessionDuration is how long a new session is valid.
+	SessionDuration Duration `reload:"true"`
+	// SessionRotationInterval is the age after which a user session is
+	// replaced by a new one.
+	SessionRotationInterval Duration `reload:"true"`
+
+	// VisitRetention deletes raw link visits older than this, e.g.
+	// "2160h". The visit counters and daily rollups are kept. Without it
+	// visits are kept forever.
+	VisitRetention Duration `reload:"true"`
+
+	// TrashRetention is how long deleted links and users stay in the
+	// trash before they are purged. The default is 30 days.
+	TrashRetention Duration `reload:"true"`
+
+	// Domains are additional hosts that serve their own namespace of short
+	// links, like {"go.example.com": "https://intranet.example.com"}.
+	// Requests for other hosts use the default namespace. The value is an
+	// optional fallback URL for short links that don't exist on the domain.
+	Domains map[string]string `reload:"true"`
+
+	// PreviewLinks shows a preview page with the destination of every link
+	// instead of redirecting right away, like the Preview option of a link.
+	PreviewLinks bool `reload:"true"`
+	// TrustedDomains enables a warning page before redirects to other
+	// hosts, like ["example.com"]. Subdomains of trusted domains, the
+	// Domains and the host of the request are trusted as well.
+	TrustedDomains []string `reload:"true"`
+
+	// SecurityHeaders are set on every response. If it is not set, a
+	// default set of headers is used. Headers with empty values are skipped.
+	SecurityHeaders map[string]string `reload:"true"`
+
+	Startup       time.Time `json:"-"`
+	DevFileServer bool      `json:"-"`
+}
+
+// TLSEnabled reports whether the server serves HTTPS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSSelfSigned
+}
+
+// Duration is a time.Duration that is written as a string like "1h30m" in JSON.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(buf []byte) error {
+	var s string
+	err := json.Unmarshal(buf, &s)
+	if err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
 }
@@ -0,0 +1,393 @@
+package foundation
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// EnvPrefix is the prefix of environment variables that override config
+// fields, for example FOUNDATION_HOST_PORT for HostPort.
+const EnvPrefix = "FOUNDATION_"
+
+const maskedSecret = "********"
+
+// LoadConfig builds the effective config in layers: defaults, then the JSON
+// file at path (skipped if path is empty), then FOUNDATION_* variables from
+// environ, then flags. Unknown JSON keys are errors, unknown environment
+// variables are only logged, and the result is validated.
+func LoadConfig(defaults Config, path string, environ []string, flags *ConfigFlags) (*Config, error) {
+	config := defaults
+
+	if path != "" {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't read config file")
+		}
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "config file %s", path)
+		}
+	}
+
+	fields := configFields()
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, EnvPrefix) {
+			continue
+		}
+		field, ok := fields.byEnv[key]
+		if !ok {
+			// the environment can have stale or unrelated variables
+			log.Printf("warning: ignoring unknown config environment variable %s", key)
+			continue
+		}
+		err := config.set(field, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "environment variable %s", key)
+		}
+	}
+
+	if flags != nil {
+		for _, field := range fields.names {
+			value, ok := flags.values[field]
+			if !ok {
+				continue
+			}
+			err := config.set(field, value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "flag -%s", flagName(field))
+			}
+		}
+	}
+
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// ConfigFlags holds the values of config flags that were set on the command line.
+type ConfigFlags struct {
+	values map[string]string
+}
+
+// RegisterConfigFlags registers a flag for every config field on fs, for
+// example -host-port for HostPort.
+func RegisterConfigFlags(fs *flag.FlagSet) *ConfigFlags {
+	flags := &ConfigFlags{values: map[string]string{}}
+	for _, field := range configFields().names {
+		fs.Func(flagName(field), fmt.Sprintf("override the %s config value", field), func(value string) error {
+			flags.values[field] = value
+			return nil
+		})
+	}
+	return flags
+}
+
+// Validate checks that the config can be used to start the app.
+func (c *Config) Validate() error {
+	var errs []error
+
+	err := validateHostPort(c.HostPort)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "HostPort"))
+	}
+	if c.MetricsHostPort != "" {
+		err = validateHostPort(c.MetricsHostPort)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "MetricsHostPort"))
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLSCertFile, TLSKeyFile: must be set together"))
+	}
+	if c.HTTPRedirectHostPort != "" {
+		err = validateHostPort(c.HTTPRedirectHostPort)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "HTTPRedirectHostPort"))
+		} else if !c.TLSEnabled() {
+			errs = append(errs, errors.New("HTTPRedirectHostPort: needs TLS to be enabled"))
+		}
+	}
+
+	if c.DBPath == "" {
+		errs = append(errs, errors.New("DBPath: must be set"))
+	} else {
+		err = checkDirWritable(filepath.Dir(c.DBPath))
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "DBPath"))
+		}
+	}
+
+	if c.ShutdownDrain < 0 {
+		errs = append(errs, errors.New("ShutdownDrain: must not be negative"))
+	}
+	if c.BackupKeep < 0 {
+		errs = append(errs, errors.New("BackupKeep: must not be negative"))
+	}
+	if c.BackupInterval < 0 || c.BackupMaxAge < 0 {
+		errs = append(errs, errors.New("BackupInterval, BackupMaxAge: must not be negative"))
+	}
+
+	_, err = ParseLogLevel(c.LogLevel)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "LogLevel"))
+	}
+	if c.LoginMaxAttempts < 0 {
+		errs = append(errs, errors.New("LoginMaxAttempts: must not be negative"))
+	}
+	if c.LoginWindow < 0 || c.LoginBlockDuration < 0 {
+		errs = append(errs, errors.New("LoginWindow, LoginBlockDuration: must not be negative"))
+	}
+	if c.SessionDuration < 0 || c.SessionRotationInterval < 0 {
+		errs = append(errs, errors.New("SessionDuration, SessionRotationInterval: must not be negative"))
+	}
+	if c.VisitRetention < 0 || c.TrashRetention < 0 {
+		errs = append(errs, errors.New("VisitRetention, TrashRetention: must not be negative"))
+	}
+	err = validateDomains(c.Domains)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "Domains"))
+	}
+	err = validateTrustedDomains(c.TrustedDomains)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "TrustedDomains"))
+	}
+
+	return errors.Wrap(joinErrors(errs), "invalid config")
+}
+
+// ParseLogLevel parses a LogLevel config value. An empty value is info.
+func ParseLogLevel(level string) (slog.Level, error) {
+	switch level {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, errors.Errorf("unknown level %q, use debug, info, warn or error", level)
+}
+
+// Reload returns a copy of c with the reloadable fields taken from next.
+// It also returns the names of changed fields that were applied, and of
+// changed fields that only take effect after a restart.
+func (c *Config) Reload(next *Config) (reloaded *Config, applied, restart []string) {
+	copied := *c
+	cur := reflect.ValueOf(&copied).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+	t := cur.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("json") == "-" {
+			continue
+		}
+		if reflect.DeepEqual(cur.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			continue
+		}
+		if f.Tag.Get("reload") != "true" {
+			restart = append(restart, f.Name)
+			continue
+		}
+		cur.Field(i).Set(nextValue.Field(i))
+		applied = append(applied, f.Name)
+	}
+	return &copied, applied, restart
+}
+
+// Masked returns a copy of the config with all secret fields replaced.
+func (c *Config) Masked() *Config {
+	masked := *c
+	v := reflect.ValueOf(&masked).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("secret") != "true" {
+			continue
+		}
+		maskValue(v.Field(i))
+	}
+	return &masked
+}
+
+// maskValue replaces a secret value. Strings, and the strings in lists and
+// maps, are replaced with a placeholder, other values are cleared.
+func maskValue(v reflect.Value) {
+	if v.IsZero() {
+		return
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(maskedSecret)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		// a new slice, the copy must not change the original config
+		masked := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			masked.Index(i).SetString(maskedSecret)
+		}
+		v.Set(masked)
+	case v.Kind() == reflect.Map && v.Type().Elem().Kind() == reflect.String:
+		masked := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			masked.SetMapIndex(key, reflect.ValueOf(maskedSecret).Convert(v.Type().Elem()))
+		}
+		v.Set(masked)
+	default:
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
+
+func (c *Config) set(field, value string) error {
+	f := reflect.ValueOf(c).Elem().FieldByName(field)
+	if !f.IsValid() {
+		return errors.Errorf("unknown config field %s", field)
+	}
+
+	switch {
+	case f.Type() == reflect.TypeOf(Duration(0)):
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.String:
+		f.SetString(value)
+	case f.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case f.Kind() == reflect.Map || f.Kind() == reflect.Slice:
+		// maps and lists are given as JSON, like in the config file
+		m := reflect.New(f.Type())
+		err := json.Unmarshal([]byte(value), m.Interface())
+		if err != nil {
+			return err
+		}
+		f.Set(m.Elem())
+	default:
+		return errors.Errorf("unsupported type %s for %s", f.Type(), field)
+	}
+	return nil
+}
+
+type fieldIndex struct {
+	names []string
+	byEnv map[string]string
+}
+
+// configFields returns the names of all fields that are read from the config
+// file, which are the ones that can be overridden.
+func configFields() fieldIndex {
+	index := fieldIndex{byEnv: map[string]string{}}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("json") == "-" {
+			continue
+		}
+		index.names = append(index.names, f.Name)
+		index.byEnv[EnvPrefix+splitCamel(f.Name, '_', unicode.ToUpper)] = f.Name
+	}
+	return index
+}
+
+func flagName(field string) string {
+	return splitCamel(field, '-', unicode.ToLower)
+}
+
+// splitCamel turns "DBPath" into "DB_PATH" or "db-path", keeping acronyms together.
+func splitCamel(s string, sep rune, mapCase func(rune) rune) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1])
+			acronymEnd := unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || acronymEnd {
+				b.WriteRune(sep)
+			}
+		}
+		b.WriteRune(mapCase(r))
+	}
+	return b.String()
+}
+
+func validateHostPort(hostPort string) error {
+	_, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return errors.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// checkDirWritable checks that dir, or its closest existing parent if dir
+// doesn't exist yet, is a writable directory.
+func checkDirWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return errors.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".foundation-write-check-*")
+	if err != nil {
+		return errors.Errorf("directory %s is not writable", dir)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
@@ -0,0 +1,205 @@
+package foundation
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, `{
+		"HostPort": "localhost:4000",
+		"DBPath": "`+filepath.Join(dir, "file.db")+`",
+		"LitestreamYml": "file.yml",
+		"BackupInterval": "1h"
+	}`)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterConfigFlags(fs)
+	err := fs.Parse([]string{"-litestream-yml", "flag.yml", "-backup-keep", "3"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	environ := []string{
+		"FOUNDATION_DB_PATH=" + filepath.Join(dir, "env.db"),
+		"FOUNDATION_LITESTREAM_YML=env.yml",
+		`FOUNDATION_SECURITY_HEADERS={"X-Frame-Options": "SAMEORIGIN"}`,
+		"PATH=/usr/bin",
+	}
+	defaults := Config{HostPort: "localhost:3000", MetricsToken: "default"}
+
+	config, err := LoadConfig(defaults, path, environ, flags)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if config.MetricsToken != "default" {
+		t.Errorf("expected default MetricsToken, got %q", config.MetricsToken)
+	}
+	if config.HostPort != "localhost:4000" {
+		t.Errorf("expected HostPort from file, got %q", config.HostPort)
+	}
+	if config.DBPath != filepath.Join(dir, "env.db") {
+		t.Errorf("expected DBPath from env, got %q", config.DBPath)
+	}
+	if config.LitestreamYml != "flag.yml" {
+		t.Errorf("expected LitestreamYml from flag, got %q", config.LitestreamYml)
+	}
+	if config.BackupKeep != 3 {
+		t.Errorf("expected BackupKeep from flag, got %d", config.BackupKeep)
+	}
+	if config.SecurityHeaders["X-Frame-Options"] != "SAMEORIGIN" {
+		t.Errorf("expected SecurityHeaders from env, got %v", config.SecurityHeaders)
+	}
+	if config.BackupInterval.Duration() != time.Hour {
+		t.Errorf("expected BackupInterval 1h, got %s", config.BackupInterval)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "foundation.db")
+	defaults := Config{HostPort: "localhost:3000", DBPath: dbPath}
+
+	testCases := []struct {
+		name    string
+		json    string
+		environ []string
+		errPart string
+	}{
+		{"unknown JSON key", `{"HostPrt": "localhost:1"}`, nil, "unknown field"},
+		{"invalid env int", `{}`, []string{"FOUNDATION_BACKUP_KEEP=many"}, "FOUNDATION_BACKUP_KEEP"},
+		{"invalid HostPort", `{"HostPort": "localhost"}`, nil, "HostPort"},
+		{"invalid port", `{"HostPort": "localhost:http"}`, nil, "invalid port"},
+		{"missing DBPath", `{"DBPath": ""}`, nil, "DBPath"},
+		{"TLSCertFile without key", `{"TLSCertFile": "cert.pem"}`, nil, "TLSKeyFile"},
+		{"redirect without TLS", `{"HTTPRedirectHostPort": ":80"}`, nil, "HTTPRedirectHostPort"},
+		{"invalid LogLevel", `{"LogLevel": "verbose"}`, nil, "LogLevel"},
+		{"DBPath parent is a file", `{"DBPath": "` + filepath.Join(dir, "file", "x.db") + `"}`, nil, "not a directory"},
+	}
+
+	err := os.WriteFile(filepath.Join(dir, "file"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeConfigFile(t, t.TempDir(), tc.json)
+			_, err := LoadConfig(defaults, path, tc.environ, nil)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.errPart) {
+				t.Errorf("expected error containing %q, got %q", tc.errPart, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigUnknownEnv(t *testing.T) {
+	defaults := Config{HostPort: "localhost:3000", DBPath: filepath.Join(t.TempDir(), "foundation.db")}
+	config, err := LoadConfig(defaults, "", []string{"FOUNDATION_HOST_PRT=x", "FOUNDATION_BACKUP_KEEP=3"}, nil)
+	if err != nil {
+		t.Fatalf("expected unknown variables to be ignored, got %v", err)
+	}
+	if config.BackupKeep != 3 {
+		t.Errorf("expected BackupKeep 3, got %d", config.BackupKeep)
+	}
+}
+
+func TestConfigMasked(t *testing.T) {
+	config := &Config{HostPort: "localhost:3000", MetricsToken: "secret"}
+	masked := config.Masked()
+
+	if masked.MetricsToken == "secret" {
+		t.Error("MetricsToken should be masked")
+	}
+	if masked.HostPort != "localhost:3000" {
+		t.Errorf("HostPort should not be masked, got %q", masked.HostPort)
+	}
+	if config.MetricsToken != "secret" {
+		t.Error("Masked should not modify the original config")
+	}
+}
+
+func TestMaskValue(t *testing.T) {
+	headers := map[string]string{"Authorization": "Bearer secret"}
+	maskValue(reflect.ValueOf(&headers).Elem())
+	if headers["Authorization"] != maskedSecret {
+		t.Errorf("expected map values to be masked, got %v", headers)
+	}
+	tokens := []string{"a", "b"}
+	original := tokens
+	maskValue(reflect.ValueOf(&tokens).Elem())
+	if tokens[0] != maskedSecret || tokens[1] != maskedSecret || original[0] != "a" {
+		t.Errorf("expected a masked copy of the list, got %v and %v", tokens, original)
+	}
+	port := 1234
+	maskValue(reflect.ValueOf(&port).Elem())
+	if port != 0 {
+		t.Errorf("expected other values to be cleared, got %d", port)
+	}
+}
+
+func TestSplitCamel(t *testing.T) {
+	for in, expected := range map[string]string{
+		"HostPort":      "host-port",
+		"DBPath":        "db-path",
+		"LitestreamYml": "litestream-yml",
+		"MetricsToken":  "metrics-token",
+	} {
+		if got := flagName(in); got != expected {
+			t.Errorf("flagName(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "config.json")
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigReload(t *testing.T) {
+	current := &Config{HostPort: "localhost:3000", LoginMaxAttempts: 5}
+	next := &Config{
+		HostPort:         "localhost:4000",
+		LoginMaxAttempts: 3,
+		SecurityHeaders:  map[string]string{"X-Frame-Options": "SAMEORIGIN"},
+		DevFileServer:    true,
+	}
+
+	reloaded, applied, restart := current.Reload(next)
+
+	if reloaded.HostPort != "localhost:3000" {
+		t.Errorf("HostPort should not be reloaded, got %q", reloaded.HostPort)
+	}
+	if reloaded.LoginMaxAttempts != 3 {
+		t.Errorf("expected LoginMaxAttempts 3, got %d", reloaded.LoginMaxAttempts)
+	}
+	if reloaded.SecurityHeaders["X-Frame-Options"] != "SAMEORIGIN" {
+		t.Errorf("expected SecurityHeaders to be reloaded, got %v", reloaded.SecurityHeaders)
+	}
+	if reloaded.DevFileServer {
+		t.Error("fields that are not in the config file should not be reloaded")
+	}
+	if current.LoginMaxAttempts != 5 {
+		t.Error("Reload should not modify the current config")
+	}
+	if strings.Join(applied, ",") != "LoginMaxAttempts,SecurityHeaders" {
+		t.Errorf("unexpected applied fields %v", applied)
+	}
+	if strings.Join(restart, ",") != "HostPort" {
+		t.Errorf("unexpected restart fields %v", restart)
+	}
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// DefaultAuditPageSize is used if AuditQuery.Limit is not set.
+const DefaultAuditPageSize = 50
+
+type auditDB struct {
+	db *bun.DB
+}
+
+// Insert records event. Call it with the ctx of the transaction of the
+// change, see DB.InTx, so that the change is only committed with its event.
+func (a *auditDB) Insert(ctx context.Context, event *foundation.AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	_, err := conn(ctx, a.db).NewInsert().Model(event).Exec(ctx)
+	return errors.Wrap(err, "insert audit event")
+}
+
+// AuditQuery selects audit events, newest first.
+type AuditQuery struct {
+	Action string
+	Actor  string
+	// Target matches targets by prefix, so "link:" selects all link events.
+	Target string
+	// Before is the ID of the last event of the previous page.
+	Before int64
+	// Limit is the page size. Export ignores it and returns all events.
+	Limit int
+}
+
+type AuditPage struct {
+	Events []*foundation.AuditEvent
+	// Next is the Before value for the following page, or 0 if this is
+	// the last page.
+	Next int64
+}
+
+// Page returns a page of the events selected by q.
+func (a *auditDB) Page(ctx context.Context, q AuditQuery) (*AuditPage, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultAuditPageSize
+	}
+	var events []*foundation.AuditEvent
+	err := a.query(ctx, q, &events).Limit(q.Limit + 1).Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+
+	page := &AuditPage{}
+	if len(events) > q.Limit {
+		events = events[:q.Limit]
+		page.Next = events[len(events)-1].ID
+	}
+	page.Events = events
+	return page, nil
+}
+
+// Export returns all events selected by q.
+func (a *auditDB) Export(ctx context.Context, q AuditQuery) ([]*foundation.AuditEvent, error) {
+	var events []*foundation.AuditEvent
+	err := a.query(ctx, q, &events).Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+	return events, nil
+}
+
+// Actions returns all actions that were recorded, for filtering.
+func (a *auditDB) Actions(ctx context.Context) ([]string, error) {
+	var actions []string
+	err := conn(ctx, a.db).NewSelect().Model((*foundation.AuditEvent)(nil)).
+		Distinct().Column("action").Order("action ASC").Scan(ctx, &actions)
+	if err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+	return actions, nil
+}
+
+func (a *auditDB) query(ctx context.Context, q AuditQuery, events *[]*foundation.AuditEvent) *bun.SelectQuery {
+	query := conn(ctx, a.db).NewSelect().Model(events).OrderExpr("ae.id DESC")
+	if q.Action != "" {
+		query = query.Where("ae.action = ?", q.Action)
+	}
+	if q.Actor != "" {
+		query = query.Where("ae.actor_name = ?", q.Actor)
+	}
+	if q.Target != "" {
+		query = query.Where("substr(ae.target, 1, length(?)) = ?", q.Target, q.Target)
+	}
+	if q.Before > 0 {
+		query = query.Where("ae.id < ?", q.Before)
+	}
+	return query
+}
+
+// AuditDiff returns the fields that differ between before and after, which
+// are structs or nil and are compared by their JSON encoding.
+func AuditDiff(before, after any) map[string]foundation.AuditChange {
+	b, a := jsonFields(before), jsonFields(after)
+	changes := map[string]foundation.AuditChange{}
+	for name, value := range a {
+		if old, ok := b[name]; !ok || !reflect.DeepEqual(old, value) {
+			changes[name] = foundation.AuditChange{Before: b[name], After: value}
+		}
+	}
+	for name, old := range b {
+		if _, ok := a[name]; !ok {
+			changes[name] = foundation.AuditChange{Before: old}
+		}
+	}
+	return changes
+}
+
+func jsonFields(v any) map[string]any {
+	fields := map[string]any{}
+	if v == nil {
+		return fields
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return fields
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		panic(errors.Wrap(err, "audit values must be JSON encodable"))
+	}
+	err = json.Unmarshal(buf, &fields)
+	if err != nil {
+		panic(errors.Wrap(err, "audit values must be JSON objects"))
+	}
+	return fields
+}
+
+var auditColumns = []string{"id", "created_at", "actor_id", "actor_name", "action", "target", "changes", "ip"}
+
+// WriteAuditEvents encodes events in the given format. In CSV files the
+// changes are a JSON object.
+func WriteAuditEvents(w io.Writer, format FileFormat, events []*foundation.AuditEvent) error {
+	if format == FormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(events)
+	}
+
+	cw := csv.NewWriter(w)
+	err := cw.Write(auditColumns)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		changes, err := json.Marshal(e.Changes)
+		if err != nil {
+			return errors.Wrap(err, "Marshal changes")
+		}
+		actorID := ""
+		if e.ActorID.Valid {
+			actorID = strconv.FormatInt(e.ActorID.Int64, 10)
+		}
+		err = cw.Write([]string{strconv.FormatInt(e.ID, 10), e.CreatedAt.UTC().Format(time.RFC3339),
+			actorID, e.ActorName, e.Action, e.Target, string(changes), e.IP})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
+)
+
+func TestAuditDiff(t *testing.T) {
+	type state struct {
+		URL  string   `json:"url"`
+		Tags []string `json:"tags"`
+	}
+	changes := AuditDiff(&state{URL: "a", Tags: []string{"x"}}, &state{URL: "b", Tags: []string{"x"}})
+	if len(changes) != 1 || changes["url"].Before != "a" || changes["url"].After != "b" {
+		t.Errorf("expected only url to change, got %+v", changes)
+	}
+	changes = AuditDiff(nil, &state{URL: "a"})
+	if len(changes) != 2 || changes["url"].Before != nil {
+		t.Errorf("expected all fields of a new value, got %+v", changes)
+	}
+}
+
+func TestAuditEvents(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	// the event is rolled back together with the change
+	failed := errors.New("failed")
+	err := database.InTx(ctx, func(ctx context.Context) error {
+		err := database.Links.Delete(ctx, "docs")
+		if err != nil {
+			return err
+		}
+		err = database.Audit.Insert(ctx, &foundation.AuditEvent{Action: "link.delete", Target: "link:docs"})
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("expected the transaction to fail, got %v", err)
+	}
+	_, err = database.Links.ByShortLink(ctx, "docs")
+	if err != nil {
+		t.Errorf("expected docs to still exist: %v", err)
+	}
+
+	for _, target := range []string{"link:docs", "link:blog", "user:1"} {
+		err = database.Audit.Insert(ctx, &foundation.AuditEvent{
+			ActorName: "links",
+			Action:    "link.update",
+			Target:    target,
+			Changes:   map[string]foundation.AuditChange{"full_url": {Before: "a", After: "b"}},
+			IP:        "127.0.0.1",
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	page, err := database.Audit.Page(ctx, AuditQuery{Target: "link:", Limit: 1})
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].Target != "link:blog" || page.Next == 0 {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	if page.Events[0].Changes["full_url"].After != "b" {
+		t.Errorf("expected the changes to be stored, got %+v", page.Events[0].Changes)
+	}
+	page, err = database.Audit.Page(ctx, AuditQuery{Target: "link:", Limit: 1, Before: page.Next})
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].Target != "link:docs" || page.Next != 0 {
+		t.Fatalf("unexpected second page %+v", page)
+	}
+
+	events, err := database.Audit.Export(ctx, AuditQuery{Actor: "links"})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	var buf bytes.Buffer
+	err = WriteAuditEvents(&buf, FormatCSV, events)
+	if err != nil {
+		t.Fatalf("WriteAuditEvents failed: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Errorf("expected a header and 3 events, got %d lines:\n%s", lines, buf.String())
+	}
+}
@@ -0,0 +1,344 @@
+package db
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/metrics"
+	"github.com/pkg/errors"
+)
+
+const (
+	// backupTimeFormat has milliseconds, so that snapshots created in the
+	// same second don't replace each other.
+	backupTimeFormat = "20060102T150405.000Z"
+	// oldBackupTimeFormat is the format of snapshots before milliseconds.
+	oldBackupTimeFormat = "20060102T150405Z"
+	backupSuffix        = ".db.gz"
+)
+
+var (
+	ErrBackupsDisabled = errors.New("backups are disabled, set BackupDir in the config")
+
+	backupsTotal = metrics.NewCounterVec("foundation_backups_total",
+		"Number of database snapshots by result.", "result")
+)
+
+type Snapshot struct {
+	Name      string
+	Path      string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// backupsDB writes gzip compressed snapshots of the live database to a
+// local directory using VACUUM INTO, which is safe while the app is running.
+type backupsDB struct {
+	sqlDB  *sql.DB
+	config *foundation.Config
+	prefix string
+}
+
+func newBackupsDB(sqlDB *sql.DB, config *foundation.Config) *backupsDB {
+	base := filepath.Base(config.DBPath)
+	return &backupsDB{
+		sqlDB:  sqlDB,
+		config: config,
+		prefix: strings.TrimSuffix(base, filepath.Ext(base)) + "-",
+	}
+}
+
+func (b *backupsDB) Enabled() bool {
+	return b.config.BackupDir != ""
+}
+
+// Create writes a new snapshot and applies the retention rules afterwards.
+func (b *backupsDB) Create(ctx context.Context) (*Snapshot, error) {
+	snapshot, err := b.create(ctx)
+	if err != nil {
+		backupsTotal.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	backupsTotal.WithLabelValues("ok").Inc()
+
+	err = b.Prune()
+	if err != nil {
+		return snapshot, errors.Wrap(err, "Prune")
+	}
+	return snapshot, nil
+}
+
+func (b *backupsDB) create(ctx context.Context) (*Snapshot, error) {
+	if !b.Enabled() {
+		return nil, ErrBackupsDisabled
+	}
+	dir := b.config.BackupDir
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, errors.Wrapf(err, "MkdirAll %q", dir)
+	}
+
+	now := time.Now().UTC()
+	name := b.prefix + now.Format(backupTimeFormat) + backupSuffix
+	path := filepath.Join(dir, name)
+	for fileExists(path) {
+		now = now.Add(time.Millisecond)
+		name = b.prefix + now.Format(backupTimeFormat) + backupSuffix
+		path = filepath.Join(dir, name)
+	}
+
+	// VACUUM INTO refuses to overwrite files, so write to a fresh temp path
+	tmpPath := filepath.Join(dir, "."+name+".tmp")
+	_ = os.Remove(tmpPath)
+	defer os.Remove(tmpPath)
+
+	_, err = b.sqlDB.ExecContext(ctx, "VACUUM INTO ?", tmpPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "VACUUM INTO")
+	}
+
+	err = gzipFile(tmpPath, path)
+	if err != nil {
+		return nil, errors.Wrap(err, "gzipFile")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("created database snapshot %s", path)
+	return &Snapshot{
+		Name:      name,
+		Path:      path,
+		CreatedAt: now,
+		Size:      info.Size(),
+	}, nil
+}
+
+// List returns all snapshots in the backup directory, newest first.
+func (b *backupsDB) List() ([]*Snapshot, error) {
+	if !b.Enabled() {
+		return nil, ErrBackupsDisabled
+	}
+	entries, err := os.ReadDir(b.config.BackupDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*Snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, b.prefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, b.prefix), backupSuffix)
+		createdAt, err := time.Parse(backupTimeFormat, ts)
+		if err != nil {
+			createdAt, err = time.Parse(oldBackupTimeFormat, ts)
+		}
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, &Snapshot{
+			Name:      name,
+			Path:      filepath.Join(b.config.BackupDir, name),
+			CreatedAt: createdAt,
+			Size:      info.Size(),
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// ByName returns the snapshot with the given file name.
+func (b *backupsDB) ByName(name string) (*Snapshot, error) {
+	snapshots, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+// Prune deletes snapshots that are neither among the BackupKeep newest
+// nor younger than BackupMaxAge. The newest snapshot is always kept.
+func (b *backupsDB) Prune() error {
+	snapshots, err := b.List()
+	if err != nil {
+		return err
+	}
+	keep := b.config.BackupKeep
+	maxAge := b.config.BackupMaxAge.Duration()
+
+	for i, s := range snapshots {
+		if i == 0 {
+			continue
+		}
+		tooMany := keep > 0 && i >= keep
+		tooOld := maxAge > 0 && time.Since(s.CreatedAt) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		err = os.Remove(s.Path)
+		if err != nil {
+			return errors.Wrapf(err, "remove %q", s.Path)
+		}
+		log.Printf("pruned database snapshot %s", s.Path)
+	}
+	return nil
+}
+
+func (b *backupsDB) startSchedule(done <-chan struct{}) {
+	interval := b.config.BackupInterval.Duration()
+	if !b.Enabled() || interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, err := b.Create(context.Background())
+				if err != nil {
+					log.Println("scheduled backup error:", err)
+				}
+			}
+		}
+	}()
+}
+
+// ListSnapshots lists the snapshots in BackupDir without opening the
+// database, newest first.
+func ListSnapshots(config *foundation.Config) ([]*Snapshot, error) {
+	return newBackupsDB(nil, config).List()
+}
+
+// RestoreSnapshot replaces the database at dbPath with the contents of the
+// snapshot. The database must not be open while restoring. The previous
+// database file is kept next to it with a ".before-restore-<time>" suffix.
+func RestoreSnapshot(snapshotPath, dbPath string) error {
+	dir := filepath.Dir(dbPath)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "MkdirAll %q", dir)
+	}
+
+	tmpPath := dbPath + ".restore.tmp"
+	defer os.Remove(tmpPath)
+	err = gunzipFile(snapshotPath, tmpPath)
+	if err != nil {
+		return errors.Wrap(err, "gunzipFile")
+	}
+
+	if fileExists(dbPath) {
+		now := time.Now().UTC()
+		previous := dbPath + ".before-restore-" + now.Format(backupTimeFormat)
+		for fileExists(previous) {
+			now = now.Add(time.Millisecond)
+			previous = dbPath + ".before-restore-" + now.Format(backupTimeFormat)
+		}
+		err = os.Rename(dbPath, previous)
+		if err != nil {
+			return errors.Wrap(err, "move previous database")
+		}
+		log.Printf("moved previous database to %s", previous)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		err = os.Remove(dbPath + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "remove %s file", suffix)
+		}
+	}
+
+	return os.Rename(tmpPath, dbPath)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func gunzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, zr)
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := &foundation.Context{
+		Context: context.Background(),
+		Config: &foundation.Config{
+			DBPath:    filepath.Join(dir, "data", "foundation.db"),
+			BackupDir: filepath.Join(dir, "backups"),
+		},
+	}
+
+	database, err := StartDB(ctx)
+	if err != nil {
+		t.Fatalf("StartDB failed: %v", err)
+	}
+	defer database.Close()
+
+	user := &foundation.User{
+		DisplayName:    "Backup Test",
+		UserName:       "backup",
+		HashedPassword: "x",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	err = database.Users.Insert(ctx, user)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	snapshot, err := database.Backups.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// snapshots in the same second must not replace each other
+	second, err := database.Backups.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if second.Name == snapshot.Name {
+		t.Fatalf("expected a new snapshot name, got %q twice", snapshot.Name)
+	}
+	old := database.Backups.prefix + "20250101T000000Z" + backupSuffix
+	err = os.WriteFile(filepath.Join(ctx.Config.BackupDir, old), []byte("x"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := database.Backups.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(snapshots) != 3 || snapshots[1].Name != snapshot.Name || snapshots[2].Name != old {
+		t.Fatalf("expected snapshots %q and %q in list, got %v", snapshot.Name, old, snapshots)
+	}
+
+	restoredPath := filepath.Join(dir, "restored", "foundation.db")
+	for range 3 {
+		err = RestoreSnapshot(snapshot.Path, restoredPath)
+		if err != nil {
+			t.Fatalf("RestoreSnapshot failed: %v", err)
+		}
+	}
+	previous, err := filepath.Glob(restoredPath + ".before-restore-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previous) != 2 {
+		t.Errorf("expected 2 previous databases, got %v", previous)
+	}
+
+	restored, err := sql.Open(sqliteshim.ShimName, "file:"+restoredPath)
+	if err != nil {
+		t.Fatalf("open restored failed: %v", err)
+	}
+	defer restored.Close()
+
+	var name string
+	err = restored.QueryRow("SELECT user_name FROM users").Scan(&name)
+	if err != nil {
+		t.Fatalf("query restored failed: %v", err)
+	}
+	if name != "backup" {
+		t.Errorf("expected restored user %q, got %q", "backup", name)
+	}
+}
+
+func TestBackupPrune(t *testing.T) {
+	dir := t.TempDir()
+	b := newBackupsDB(nil, &foundation.Config{
+		DBPath:       "foundation.db",
+		BackupDir:    dir,
+		BackupKeep:   2,
+		BackupMaxAge: foundation.Duration(48 * time.Hour),
+	})
+
+	now := time.Now().UTC()
+	ages := []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour, 72 * time.Hour}
+	for _, age := range ages {
+		name := b.prefix + now.Add(-age).Format(backupTimeFormat) + backupSuffix
+		err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.WriteFile(filepath.Join(dir, "unrelated.txt"), []byte("x"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.Prune()
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	snapshots, err := b.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots after prune, got %d", len(snapshots))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unrelated.txt")); err != nil {
+		t.Errorf("unrelated file should be kept: %v", err)
+	}
+}
+
+func TestOpenDBClose(t *testing.T) {
+	ctx := &foundation.Context{
+		Context: context.Background(),
+		Config:  &foundation.Config{DBPath: filepath.Join(t.TempDir(), "foundation.db")},
+	}
+	database, err := OpenDB(ctx)
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	err = database.Close()
+	if err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	err = database.Close()
+	if err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+}
@@ -1,10 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/mbertschler/foundation"
 	"github.com/mbertschler/foundation/db/migrations"
@@ -16,23 +18,82 @@ import (
 )
 
 type DB struct {
-	Users    *usersDB
-	Sessions *sessionsDB
-	Links    *linksDB
-	Visits   *visitsDB
-
-	sqlDB *sql.DB
+	Users     *usersDB
+	Sessions  *sessionsDB
+	Links     *linksDB
+	Visits    *visitsDB
+	Tags      *tagsDB
+	Revisions *revisionsDB
+	Rules     *rulesDB
+	Redirects *RedirectCache
+	Audit     *auditDB
+	Trash     *trashDB
+	Backups   *backupsDB
+
+	done      chan struct{}
+	closeOnce sync.Once
+	sqlDB     *sql.DB
+	bun       *bun.DB
+	migrator  *migrations.Migrator
 }
 
 func (db *DB) SetSQLDB(sqlDB *sql.DB) {
 	db.sqlDB = sqlDB
 }
 
+// Close stops the background jobs and closes the database. Calling it
+// again has no effect.
 func (db *DB) Close() error {
-	return db.sqlDB.Close()
+	var err error
+	db.closeOnce.Do(func() {
+		close(db.done)
+		err = db.sqlDB.Close()
+	})
+	return err
+}
+
+// Checkpoint moves all WAL content into the database file, so that a
+// replicator sees the final state before the process exits.
+func (db *DB) Checkpoint(ctx context.Context) error {
+	_, err := db.sqlDB.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
+
+// Ping verifies that the database connection is usable.
+func (db *DB) Ping(ctx context.Context) error {
+	return db.sqlDB.PingContext(ctx)
+}
+
+// CheckMigrations returns an error if any known migration is not applied.
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	status, err := db.migrator.Status(ctx)
+	if err != nil {
+		return errors.Wrap(err, "migrator.Status")
+	}
+	unapplied := status.Unapplied()
+	if len(unapplied) > 0 {
+		return errors.Errorf("%d unapplied migrations: %s", len(unapplied), unapplied)
+	}
+	return nil
 }
 
+// StartDB opens the database for the app, with the background jobs that
+// create scheduled backups and clean up sessions, visits and the trash.
 func StartDB(context *foundation.Context) (*DB, error) {
+	fdb, err := OpenDB(context)
+	if err != nil {
+		return nil, err
+	}
+	fdb.Sessions.startCleanup()
+	fdb.Backups.startSchedule(fdb.done)
+	fdb.Visits.startCleanup(fdb.done)
+	fdb.Trash.startPurge(fdb.done)
+	return fdb, nil
+}
+
+// OpenDB opens and migrates the database without any background jobs, for
+// commands that only run briefly next to the app.
+func OpenDB(context *foundation.Context) (*DB, error) {
 	ctx := context.Context
 
 	path := context.Config.DBPath
@@ -58,6 +119,7 @@ func StartDB(context *foundation.Context) (*DB, error) {
 	db.AddQueryHook(bundebug.NewQueryHook(
 		bundebug.WithVerbose(true),
 	))
+	db.AddQueryHook(metricsQueryHook{})
 
 	// Initialize and run migrations
 	migrator := migrations.NewMigrator(db)
@@ -73,17 +135,26 @@ func StartDB(context *foundation.Context) (*DB, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "run migrations")
 	}
-	sessionDB := &sessionsDB{db: db}
-	sessionDB.startCleanup()
+	sessionDB := &sessionsDB{db: db, config: context.Current}
+	registerSessionMetrics(db)
 
 	fdb := &DB{
-		Users:    &usersDB{db: db},
-		Sessions: sessionDB,
-		Links:    &linksDB{db: db},
-		Visits:   &visitsDB{db: db},
+		Users:     &usersDB{db: db},
+		Sessions:  sessionDB,
+		Links:     &linksDB{db: db},
+		Visits:    newVisitsDB(db, context.Current),
+		Tags:      &tagsDB{db: db},
+		Revisions: &revisionsDB{db: db},
+		Rules:     &rulesDB{db: db},
+		Audit:     &auditDB{db: db},
+		Trash:     &trashDB{db: db, config: context.Current},
+		Backups:   newBackupsDB(sqldb, context.Config),
+		done:      make(chan struct{}),
+		bun:       db,
+		migrator:  migrator,
 	}
 
+	fdb.Redirects = NewRedirectCache(fdb, DefaultRedirectCacheSize)
 	fdb.SetSQLDB(sqldb)
-
 	return fdb, nil
 }
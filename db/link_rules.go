@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/form"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// MaxLinkRules is the maximum number of targeting rules of one link.
+const MaxLinkRules = 20
+
+var (
+	nilRule        *foundation.LinkRule
+	variantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+)
+
+type rulesDB struct {
+	db *bun.DB
+}
+
+// ByShortLink returns the rules of a link in the order they are evaluated.
+func (r *rulesDB) ByShortLink(ctx context.Context, shortLink string) ([]*foundation.LinkRule, error) {
+	var rules []*foundation.LinkRule
+	err := conn(ctx, r.db).NewSelect().Model(&rules).Where("short_link = ?", shortLink).
+		OrderExpr("position ASC").Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "select link rules")
+	}
+	return rules, nil
+}
+
+// Set replaces the rules of a link.
+func (r *rulesDB) Set(ctx context.Context, shortLink string, rules []*foundation.LinkRule) error {
+	return runInTx(ctx, r.db, func(ctx context.Context) error {
+		idb := conn(ctx, r.db)
+		_, err := idb.NewDelete().Model(nilRule).Where("short_link = ?", shortLink).Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "delete link rules")
+		}
+		if len(rules) == 0 {
+			return nil
+		}
+		for i, rule := range rules {
+			rule.ID = 0
+			rule.ShortLink = shortLink
+			rule.Position = i
+		}
+		_, err = idb.NewInsert().Model(&rules).Exec(ctx)
+		return errors.Wrap(err, "insert link rules")
+	})
+}
+
+// ruleURL is used to validate the URL of a rule like the URL of a link.
+type ruleURL struct {
+	FullURL string `form:"full_url" validate:"required,max=2048,url"`
+}
+
+// ParseLinkRules parses rules in the text format of the edit dialog, one
+// rule per line as "variant kind value url". Empty lines and lines
+// starting with # are skipped.
+func ParseLinkRules(s string) ([]*foundation.LinkRule, error) {
+	rules := []*foundation.LinkRule{}
+	variants := map[string]bool{}
+	split := 0
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, errors.Errorf("line %d: expected \"variant kind value url\"", i+1)
+		}
+		rule := &foundation.LinkRule{
+			Variant: fields[0],
+			Kind:    foundation.LinkRuleKind(strings.ToLower(fields[1])),
+			Value:   fields[2],
+			FullURL: fields[3],
+		}
+		if !variantPattern.MatchString(rule.Variant) {
+			return nil, errors.Errorf("line %d: use only letters, digits, - and _ in the variant", i+1)
+		}
+		if variants[rule.Variant] {
+			return nil, errors.Errorf("line %d: duplicate variant %q", i+1, rule.Variant)
+		}
+		variants[rule.Variant] = true
+		err := rule.Check()
+		if err != nil {
+			return nil, errors.Errorf("line %d: %v", i+1, err)
+		}
+		if message := form.Validate(&ruleURL{FullURL: rule.FullURL}).Get("full_url"); message != "" {
+			return nil, errors.Errorf("line %d: %s", i+1, message)
+		}
+		if rule.Kind == foundation.RuleSplit {
+			weight, _ := strconv.Atoi(rule.Value)
+			split += weight
+			if split > 100 {
+				return nil, errors.Errorf("line %d: the split rules add up to more than 100%%", i+1)
+			}
+		} else if split > 0 {
+			return nil, errors.Errorf("line %d: split rules have to come after all other rules", i+1)
+		}
+		rules = append(rules, rule)
+		if len(rules) > MaxLinkRules {
+			return nil, errors.Errorf("at most %d rules are allowed", MaxLinkRules)
+		}
+	}
+	return rules, nil
+}
+
+// FormatLinkRules returns rules in the format read by ParseLinkRules.
+func FormatLinkRules(rules []*foundation.LinkRule) string {
+	var b strings.Builder
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "%s %s %s %s\n", rule.Variant, rule.Kind, rule.Value, rule.FullURL)
+	}
+	return b.String()
+}
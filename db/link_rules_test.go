@@ -0,0 +1,89 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mbertschler/foundation"
+)
+
+func TestParseLinkRules(t *testing.T) {
+	text := "# campaigns\nde language de,fr-CH https://example.com/de\n\nb split 50 https://example.com/b\n"
+	rules, err := ParseLinkRules(text)
+	if err != nil {
+		t.Fatalf("ParseLinkRules failed: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Kind != foundation.RuleLanguage || rules[1].Variant != "b" {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+	formatted := FormatLinkRules(rules)
+	if formatted != "de language de,fr-CH https://example.com/de\nb split 50 https://example.com/b\n" {
+		t.Errorf("unexpected format %q", formatted)
+	}
+
+	invalid := map[string]string{
+		"a language de":                                        "line 1",
+		"a language de ftp://example.com":                      "http",
+		"a language de https://a.com\na query x https://b.com": "duplicate variant",
+		"a split 50 https://a.com\nb query x https://b.com":    "after all other rules",
+		"a split 60 https://a.com\nb split 50 https://b.com":   "more than 100",
+		"a/b query x https://a.com":                            "variant",
+		"a platform amiga https://a.com":                       "unknown platform",
+		"x":                                                    "line 1",
+	}
+	for text, expected := range invalid {
+		_, err := ParseLinkRules(text)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected an error containing %q for %q, got %v", expected, text, err)
+		}
+	}
+}
+
+func TestLinkRules(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	rules, err := ParseLinkRules("mobile platform mobile https://m.example.com\nb split 50 https://b.example.com")
+	if err != nil {
+		t.Fatalf("ParseLinkRules failed: %v", err)
+	}
+	err = database.Rules.Set(ctx, "docs", rules)
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	// setting the rules again replaces them
+	err = database.Rules.Set(ctx, "docs", rules[1:])
+	if err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	stored, err := database.Rules.ByShortLink(ctx, "docs")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Variant != "b" || stored[0].Position != 0 {
+		t.Fatalf("unexpected rules %+v", stored)
+	}
+
+	for _, variant := range []string{"b", "b", ""} {
+		err = database.Visits.Insert(ctx, &foundation.LinkVisit{ShortLink: "docs", Variant: variant})
+		if err != nil {
+			t.Fatalf("Visits.Insert failed: %v", err)
+		}
+	}
+	counts, err := database.Visits.Variants(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Variants failed: %v", err)
+	}
+	if len(counts) != 2 || counts[0].Variant != "" || counts[0].Visits != 1 || counts[1].Variant != "b" || counts[1].Visits != 2 {
+		t.Errorf("unexpected variant counts %+v", counts)
+	}
+
+	err = database.Links.Purge(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	stored, err = database.Rules.ByShortLink(ctx, "docs")
+	if err != nil || len(stored) != 0 {
+		t.Errorf("expected the rules of a purged link to be deleted, got %d: %v", len(stored), err)
+	}
+}
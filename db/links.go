@@ -1,9 +1,16 @@
 package db
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
 )
 
@@ -16,45 +23,341 @@ type linksDB struct {
 }
 
 func (l *linksDB) Insert(ctx context.Context, link *foundation.Link) error {
-	_, err := l.db.NewInsert().Model(link).Exec(ctx)
+	_, err := conn(ctx, l.db).NewInsert().Model(link).Exec(ctx)
 	return err
 }
 
+// Update writes all fields of link except the visit counters, which are
+// only changed by the trigger on link_visits and by purging visits.
 func (l *linksDB) Update(ctx context.Context, link *foundation.Link) error {
-	_, err := l.db.NewUpdate().Model(link).WherePK().Exec(ctx)
+	_, err := conn(ctx, l.db).NewUpdate().Model(link).
+		ExcludeColumn("visits_count", "last_visited_at", "visits_purged_at").
+		WherePK().Exec(ctx)
 	return err
 }
 
 func (l *linksDB) ByShortLink(ctx context.Context, shortLink string) (*foundation.Link, error) {
 	var link foundation.Link
-	err := l.db.NewSelect().Model(&link).Where("short_link = ?", shortLink).Scan(ctx)
+	err := conn(ctx, l.db).NewSelect().Model(&link).Where("short_link = ?", shortLink).Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return &link, nil
 }
 
-func (l *linksDB) All(ctx context.Context) ([]*foundation.Link, error) {
+// All returns all links with their tags, or only the links tagged with tag
+// if it is not empty.
+func (l *linksDB) All(ctx context.Context, tag string) ([]*foundation.Link, error) {
 	var links []*foundation.Link
-	err := l.db.NewSelect().Model(&links).Relation("User").Order("short_link ASC").Scan(ctx)
+	query := conn(ctx, l.db).NewSelect().Model(&links).Relation("User").Order("short_link ASC")
+	if tag != "" {
+		query = query.Where(linkTagFilter, tag)
+	}
+	err := query.Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = loadTags(ctx, conn(ctx, l.db), links)
 	if err != nil {
 		return nil, err
 	}
 	return links, nil
 }
 
-func (l *linksDB) AllWithVisitCounts(ctx context.Context) ([]*foundation.Link, error) {
+// LoadTags sets the Tags of links.
+func (l *linksDB) LoadTags(ctx context.Context, links ...*foundation.Link) error {
+	return loadTags(ctx, conn(ctx, l.db), links)
+}
+
+// linkTagFilter selects the links that have the tag named by its argument.
+const linkTagFilter = "l.short_link IN (SELECT lt.short_link FROM link_tags AS lt JOIN tags AS t ON t.id = lt.tag_id WHERE t.name = ?)"
+
+// LinkSort is a column that links can be sorted by.
+type LinkSort string
+
+const (
+	SortShortLink LinkSort = "short_link"
+	SortVisits    LinkSort = "visits"
+	SortCreated   LinkSort = "created"
+	SortUpdated   LinkSort = "updated"
+	SortOwner     LinkSort = "owner"
+)
+
+// DefaultLinkPageSize is used if LinkQuery.Limit is not set.
+const DefaultLinkPageSize = 50
+
+// linkSortExprs are the SQL expressions for each sort, formatted with the
+// alias of the links table and of the joined users table.
+var linkSortExprs = map[LinkSort]string{
+	SortShortLink: "%[1]s.short_link",
+	SortVisits:    "%[1]s.visits_count",
+	SortCreated:   "%[1]s.created_at",
+	SortUpdated:   "%[1]s.updated_at",
+	SortOwner:     "COALESCE(%[2]s.display_name, '')",
+}
+
+// ValidLinkSort reports whether links can be sorted by s.
+func ValidLinkSort(s LinkSort) bool {
+	_, ok := linkSortExprs[s]
+	return ok
+}
+
+// LinkQuery selects a page of links.
+type LinkQuery struct {
+	// Search matches words in the short link and URL by prefix.
+	Search string
+	// Tag only selects links with this tag.
+	Tag  string
+	Sort LinkSort
+	Desc bool
+	// After is the Next cursor of the previous page.
+	After string
+	Limit int
+}
+
+type LinkPage struct {
+	Links []*foundation.Link
+	// Total is the number of links that match the search.
+	Total int
+	// Next is the After value for the following page, or empty if this
+	// is the last page.
+	Next string
+}
+
+// linkCursor is the position after the last link of a page. It keeps the
+// sort value of the link, so that paging works even if the link is renamed,
+// deleted or changes its sort value in the meantime.
+type linkCursor struct {
+	Value     any    `json:"v"`
+	ShortLink string `json:"l"`
+}
+
+func (c linkCursor) encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "Marshal")
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeLinkCursor parses a cursor from LinkPage.Next. Numbers are kept as
+// integers, because SQLite compares them with integer columns.
+func decodeLinkCursor(token string) (linkCursor, bool) {
+	var c linkCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if dec.Decode(&c) != nil || c.ShortLink == "" {
+		return c, false
+	}
+	switch v := c.Value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return c, false
+		}
+		c.Value = n
+	case string:
+	default:
+		return c, false
+	}
+	return c, true
+}
+
+// Page returns the links selected by q. Pages are based on a cursor, so
+// links that are added or removed while paging don't cause duplicates or
+// gaps.
+func (l *linksDB) Page(ctx context.Context, q LinkQuery) (*LinkPage, error) {
+	if !ValidLinkSort(q.Sort) {
+		q.Sort = SortShortLink
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultLinkPageSize
+	}
+	sortExpr := fmt.Sprintf(linkSortExprs[q.Sort], "l", `"user"`)
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
 	var links []*foundation.Link
-	err := l.db.NewSelect().Model(&links).Relation("User").ColumnExpr("l.*").
-		ColumnExpr("(SELECT COUNT(*) FROM link_visits WHERE short_link = l.short_link) AS visits_count").
-		Order("l.short_link").Scan(ctx)
+	query := conn(ctx, l.db).NewSelect().Model(&links).Relation("User")
+	search := ftsQuery(q.Search)
+	if search != "" {
+		query = query.Where("l.rowid IN (SELECT rowid FROM links_fts WHERE links_fts MATCH ?)", search)
+	}
+	if q.Tag != "" {
+		query = query.Where(linkTagFilter, q.Tag)
+	}
+
+	page := &LinkPage{}
+	var err error
+	page.Total, err = query.Count(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Count")
+	}
+
+	// an invalid cursor starts again at the first page
+	if cursor, ok := decodeLinkCursor(q.After); ok {
+		// the short link is the tie breaker for equal sort values
+		query = query.Where(fmt.Sprintf("(%s, l.short_link) %s (?, ?)", sortExpr, cmp),
+			cursor.Value, cursor.ShortLink)
+	}
+
+	err = query.OrderExpr(fmt.Sprintf("%s %s, l.short_link %s", sortExpr, dir, dir)).
+		Limit(q.Limit + 1).Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+
+	if len(links) > q.Limit {
+		links = links[:q.Limit]
+		page.Next, err = l.cursor(ctx, sortExpr, links[len(links)-1].ShortLink)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = loadTags(ctx, conn(ctx, l.db), links)
 	if err != nil {
 		return nil, err
 	}
-	return links, nil
+	page.Links = links
+	return page, nil
+}
+
+// cursor returns the Next cursor after the link. The sort value is read
+// with SQL, so that it compares exactly like the stored value.
+func (l *linksDB) cursor(ctx context.Context, sortExpr, shortLink string) (string, error) {
+	var row struct {
+		Value any `bun:"value"`
+	}
+	err := conn(ctx, l.db).NewRaw(fmt.Sprintf(`SELECT %s AS value FROM links AS l LEFT JOIN users AS "user" ON "user".id = l.user_id WHERE l.short_link = ?`, sortExpr), shortLink).
+		Scan(ctx, &row)
+	if err != nil {
+		return "", errors.Wrap(err, "cursor")
+	}
+	if b, ok := row.Value.([]byte); ok {
+		row.Value = string(b)
+	}
+	return linkCursor{Value: row.Value, ShortLink: shortLink}.encode()
+}
+
+// ftsQuery turns user input into an FTS5 query that matches all words as
+// prefixes. Quoting makes sure that no input is interpreted as syntax.
+func ftsQuery(search string) string {
+	var terms []string
+	for _, word := range strings.Fields(search) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
 }
 
+// Delete moves a link to the trash. It keeps its tags and visits until it
+// is purged, and can be restored until then.
 func (l *linksDB) Delete(ctx context.Context, shortLink string) error {
-	_, err := l.db.NewDelete().Model(nilLink).Where("short_link = ?", shortLink).Exec(ctx)
+	_, err := conn(ctx, l.db).NewDelete().Model(nilLink).Where("short_link = ?", shortLink).Exec(ctx)
 	return err
 }
+
+// InTrash reports whether the short link is taken by a link in the trash.
+func (l *linksDB) InTrash(ctx context.Context, shortLink string) (bool, error) {
+	return conn(ctx, l.db).NewSelect().Model(nilLink).WhereDeleted().
+		Where("short_link = ?", shortLink).Exists(ctx)
+}
+
+// Trashed returns the links in the trash, most recently deleted first.
+func (l *linksDB) Trashed(ctx context.Context) ([]*foundation.Link, error) {
+	var links []*foundation.Link
+	err := conn(ctx, l.db).NewSelect().Model(&links).Relation("User").WhereDeleted().
+		OrderExpr("l.deleted_at DESC, l.short_link ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// Restore takes a link out of the trash. It returns sql.ErrNoRows if the
+// link is not in the trash.
+func (l *linksDB) Restore(ctx context.Context, shortLink string) error {
+	res, err := conn(ctx, l.db).NewUpdate().Model(nilLink).WhereDeleted().
+		Set("deleted_at = NULL").Where("short_link = ?", shortLink).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return expectRows(res)
+}
+
+// Rename replaces the link from with link, which has the new short link.
+// Its tags, rules, visits and daily rollups move to the new short link,
+// revisions are moved by Revisions.Move.
+func (l *linksDB) Rename(ctx context.Context, from string, link *foundation.Link) error {
+	return runInTx(ctx, l.db, func(ctx context.Context) error {
+		tx := conn(ctx, l.db)
+		_, err := tx.NewInsert().Model(link).Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "insert link")
+		}
+		for _, model := range []any{(*foundation.LinkTag)(nil), nilRule, nilVisit, (*foundation.LinkDailyVisits)(nil)} {
+			_, err = tx.NewUpdate().Model(model).Set("short_link = ?", link.ShortLink).
+				Where("short_link = ?", from).Exec(ctx)
+			if err != nil {
+				return errors.Wrapf(err, "move %T", model)
+			}
+		}
+		_, err = tx.NewDelete().Model(nilLink).WhereAllWithDeleted().ForceDelete().
+			Where("short_link = ?", from).Exec(ctx)
+		return errors.Wrap(err, "delete old link")
+	})
+}
+
+// Purge permanently deletes a link with its tags, visits and revisions,
+// whether it is in the trash or not.
+func (l *linksDB) Purge(ctx context.Context, shortLink string) error {
+	return runInTx(ctx, l.db, func(ctx context.Context) error {
+		return purgeLink(ctx, conn(ctx, l.db), shortLink)
+	})
+}
+
+func purgeLink(ctx context.Context, tx bun.IDB, shortLink string) error {
+	_, err := tx.NewDelete().Model((*foundation.LinkTag)(nil)).Where("short_link = ?", shortLink).Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "delete link_tags")
+	}
+	_, err = tx.NewDelete().Model(nilVisit).Where("short_link = ?", shortLink).Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "delete link_visits")
+	}
+	_, err = tx.NewDelete().Model((*foundation.LinkDailyVisits)(nil)).Where("short_link = ?", shortLink).Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "delete link_visits_daily")
+	}
+	_, err = tx.NewDelete().Model(nilRevision).Where("short_link = ?", shortLink).Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "delete link_revisions")
+	}
+	_, err = tx.NewDelete().Model(nilRule).Where("short_link = ?", shortLink).Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "delete link_rules")
+	}
+	_, err = tx.NewDelete().Model(nilLink).WhereAllWithDeleted().ForceDelete().
+		Where("short_link = ?", shortLink).Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "delete link")
+	}
+	return deleteUnusedTags(ctx, tx)
+}
+
+// expectRows returns sql.ErrNoRows if res didn't affect any rows.
+func expectRows(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "RowsAffected")
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
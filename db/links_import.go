@@ -0,0 +1,432 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/form"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// FileFormat is a file format for imports and exports.
+type FileFormat string
+
+const (
+	FormatCSV  FileFormat = "csv"
+	FormatJSON FileFormat = "json"
+)
+
+// FileFormatOf returns the format of a file name by its extension.
+func FileFormatOf(name string) (FileFormat, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".json":
+		return FormatJSON, nil
+	}
+	return "", errors.Errorf("unknown file format %q, expected .csv or .json", filepath.Ext(name))
+}
+
+// LinkRecord is a link as it is exported. Only ShortLink, FullURL, Owner,
+// NoTrack, Access and Tags are used for imports, the other fields are
+// informational.
+type LinkRecord struct {
+	// ShortLink is the name of the link, followed by @ and the host for
+	// links on other domains, see foundation.LinkKey.
+	ShortLink string `json:"short_link" form:"short_link" validate:"required,max=360" pattern:"^[A-Za-z0-9_-]+(@[a-z0-9.-]+)?$" message:"Use only letters, digits, - and _, followed by @ and the domain for other domains."`
+	FullURL   string `json:"full_url" form:"full_url" validate:"required,max=2048,url"`
+	// Owner is the user name of the owner. On import, new links without
+	// an owner belong to the importing user, existing ones keep theirs.
+	Owner   string `json:"owner,omitempty"`
+	NoTrack bool   `json:"no_track,omitempty"`
+	// Access is one of foundation.LinkAccessModes. Empty keeps the access
+	// of existing links and makes new links public. Passwords are not
+	// exported, so imports can't make links password protected.
+	Access foundation.LinkAccess `json:"access,omitempty"`
+	// Tags replace the tags of the link on import, unless they are nil.
+	Tags []string `json:"tags,omitempty"`
+
+	VisitsCount   int64      `json:"visits_count"`
+	LastVisitedAt *time.Time `json:"last_visited_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+var linkRecordColumns = []string{"short_link", "full_url", "owner", "no_track", "access", "tags",
+	"visits_count", "last_visited_at", "created_at", "updated_at"}
+
+// Export returns all links as records, ordered by short link. If tag is
+// not empty, only links with that tag are exported.
+func (l *linksDB) Export(ctx context.Context, tag string) ([]*LinkRecord, error) {
+	links, err := l.All(ctx, tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "All")
+	}
+
+	records := make([]*LinkRecord, 0, len(links))
+	for _, link := range links {
+		record := &LinkRecord{
+			ShortLink:   link.ShortLink,
+			FullURL:     link.FullURL,
+			NoTrack:     link.NoTrack,
+			Access:      link.Mode(),
+			Tags:        TagNames(link.Tags),
+			VisitsCount: link.VisitsCount,
+			CreatedAt:   link.CreatedAt.UTC(),
+			UpdatedAt:   link.UpdatedAt.UTC(),
+		}
+		if link.User != nil {
+			record.Owner = link.User.UserName
+		}
+		if !link.LastVisitedAt.IsZero() {
+			lastVisitedAt := link.LastVisitedAt.UTC()
+			record.LastVisitedAt = &lastVisitedAt
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// WriteLinkRecords encodes records in the given format.
+func WriteLinkRecords(w io.Writer, format FileFormat, records []*LinkRecord) error {
+	if format == FormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(records)
+	}
+
+	cw := csv.NewWriter(w)
+	err := cw.Write(linkRecordColumns)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		lastVisitedAt := ""
+		if r.LastVisitedAt != nil {
+			lastVisitedAt = r.LastVisitedAt.Format(time.RFC3339)
+		}
+		err = cw.Write([]string{r.ShortLink, r.FullURL, r.Owner, strconv.FormatBool(r.NoTrack), string(r.Access),
+			strings.Join(r.Tags, ", "), strconv.FormatInt(r.VisitsCount, 10), lastVisitedAt,
+			r.CreatedAt.Format(time.RFC3339), r.UpdatedAt.Format(time.RFC3339)})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadLinkRecords decodes records in the given format. CSV files need a
+// header row with at least the short_link and full_url columns, unknown
+// columns are ignored. Tags are separated by commas.
+func ReadLinkRecords(r io.Reader, format FileFormat) ([]*LinkRecord, error) {
+	if format == FormatJSON {
+		var records []*LinkRecord
+		err := json.NewDecoder(r).Decode(&records)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode JSON")
+		}
+		return records, nil
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "read CSV header")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"short_link", "full_url"} {
+		if _, ok := columns[required]; !ok {
+			return nil, errors.Errorf("CSV header is missing the %s column", required)
+		}
+	}
+
+	var records []*LinkRecord
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read CSV")
+		}
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		record := &LinkRecord{
+			ShortLink: get("short_link"),
+			FullURL:   get("full_url"),
+			Owner:     get("owner"),
+			Access:    foundation.LinkAccess(get("access")),
+		}
+		if _, ok := columns["tags"]; ok {
+			record.Tags = ParseTags(get("tags"))
+		}
+		if noTrack := get("no_track"); noTrack != "" {
+			record.NoTrack, err = strconv.ParseBool(noTrack)
+			if err != nil {
+				line, _ := cr.FieldPos(0)
+				return nil, errors.Errorf("line %d: invalid no_track value %q", line, noTrack)
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// LinkImportAction is what an import does with a record.
+type LinkImportAction string
+
+const (
+	ImportCreate    LinkImportAction = "create"
+	ImportUpdate    LinkImportAction = "update"
+	ImportUnchanged LinkImportAction = "unchanged"
+	ImportConflict  LinkImportAction = "conflict"
+)
+
+type LinkImportResult struct {
+	Record *LinkRecord
+	Action LinkImportAction
+	// Reason explains a conflict.
+	Reason string
+}
+
+// LinkImport is the plan or the outcome of an import.
+type LinkImport struct {
+	Results   []*LinkImportResult
+	Creates   int
+	Updates   int
+	Unchanged int
+	Conflicts int
+	// Applied is set if the changes were written to the database.
+	Applied bool
+}
+
+// Import creates and updates links from records in one transaction. If
+// dryRun is set or any record conflicts, nothing is written and the result
+// only shows what would happen. New links without an owner belong to
+// ownerID, which may be 0 if every new link has an owner.
+func (l *linksDB) Import(ctx context.Context, records []*LinkRecord, ownerID int64, dryRun bool) (*LinkImport, error) {
+	result := &LinkImport{}
+	err := runInTx(ctx, l.db, func(ctx context.Context) error {
+		tx := conn(ctx, l.db)
+		var users []*foundation.User
+		err := tx.NewSelect().Model(&users).Scan(ctx)
+		if err != nil {
+			return errors.Wrap(err, "select users")
+		}
+		userIDs := map[string]int64{}
+		actorName := ""
+		for _, u := range users {
+			userIDs[u.UserName] = u.ID
+			if u.ID == ownerID {
+				actorName = u.UserName
+			}
+		}
+
+		var changes []*linkChange
+		seen := map[string]bool{}
+		for _, record := range records {
+			change, action, reason, err := planLinkImport(ctx, tx, record, userIDs, ownerID, seen)
+			if err != nil {
+				return errors.Wrapf(err, "plan %q", record.ShortLink)
+			}
+			result.Results = append(result.Results, &LinkImportResult{Record: record, Action: action, Reason: reason})
+			switch action {
+			case ImportCreate:
+				result.Creates++
+			case ImportUpdate:
+				result.Updates++
+			case ImportUnchanged:
+				result.Unchanged++
+			case ImportConflict:
+				result.Conflicts++
+			}
+			if change != nil {
+				changes = append(changes, change)
+			}
+		}
+		if dryRun || result.Conflicts > 0 {
+			return nil
+		}
+
+		for _, change := range changes {
+			link := change.link
+			if change.create {
+				link.CreatedAt = link.UpdatedAt
+				_, err = tx.NewInsert().Model(link).Exec(ctx)
+			} else {
+				_, err = tx.NewUpdate().Model(link).
+					Column("full_url", "user_id", "no_track", "access", "password_hash", "updated_at").
+					WherePK().Exec(ctx)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "write %q", link.ShortLink)
+			}
+			tags := TagNames(link.Tags)
+			if change.tags != nil {
+				tags = change.tags
+				err = setTags(ctx, tx, link.ShortLink, change.tags)
+				if err != nil {
+					return errors.Wrapf(err, "tags of %q", link.ShortLink)
+				}
+			}
+			var rules []*foundation.LinkRule
+			if !change.create {
+				err = tx.NewSelect().Model(&rules).Where("short_link = ?", link.ShortLink).
+					OrderExpr("position ASC").Scan(ctx)
+				if err != nil {
+					return errors.Wrapf(err, "rules of %q", link.ShortLink)
+				}
+			}
+			rev := NewLinkRevision(link, tags, FormatLinkRules(rules), "import")
+			rev.ActorName = actorName
+			if ownerID != 0 {
+				rev.ActorID = sql.NullInt64{Int64: ownerID, Valid: true}
+			}
+			_, err = tx.NewInsert().Model(rev).Exec(ctx)
+			if err != nil {
+				return errors.Wrapf(err, "revision of %q", link.ShortLink)
+			}
+		}
+		result.Applied = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// linkChange is a link that is created or updated by an import.
+type linkChange struct {
+	link   *foundation.Link
+	create bool
+	// tags replace the tags of the link, unless they are nil
+	tags []string
+}
+
+// planLinkImport decides what to do with record. The returned change is
+// nil if the link is not created or updated.
+func planLinkImport(ctx context.Context, tx bun.IDB, record *LinkRecord, userIDs map[string]int64, ownerID int64, seen map[string]bool) (*linkChange, LinkImportAction, string, error) {
+	errs := form.Validate(record)
+	for _, field := range []string{"short_link", "full_url"} {
+		if message := errs.Get(field); message != "" {
+			return nil, ImportConflict, fmt.Sprintf("%s: %s", field, message), nil
+		}
+	}
+	if seen[record.ShortLink] {
+		return nil, ImportConflict, "duplicate short link in the file", nil
+	}
+	seen[record.ShortLink] = true
+	err := CheckTags(record.Tags)
+	if err != nil {
+		return nil, ImportConflict, err.Error(), nil
+	}
+	if record.Access != "" && !slices.Contains(foundation.LinkAccessModes, record.Access) {
+		return nil, ImportConflict, fmt.Sprintf("unknown access %q", record.Access), nil
+	}
+
+	var userID int64
+	if record.Owner != "" {
+		id, ok := userIDs[record.Owner]
+		if !ok {
+			return nil, ImportConflict, fmt.Sprintf("unknown owner %q", record.Owner), nil
+		}
+		userID = id
+	}
+
+	var existing foundation.Link
+	err = tx.NewSelect().Model(&existing).WhereAllWithDeleted().Where("short_link = ?", record.ShortLink).Scan(ctx)
+	if err == nil && !existing.DeletedAt.IsZero() {
+		return nil, ImportConflict, "link is in the trash", nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		if userID == 0 {
+			userID = ownerID
+		}
+		if userID == 0 {
+			return nil, ImportConflict, "owner is required for new links", nil
+		}
+		if record.Access == foundation.AccessPassword {
+			return nil, ImportConflict, "password protected links can't be imported, set the password in the link dialog", nil
+		}
+		link := &foundation.Link{
+			ShortLink: record.ShortLink,
+			FullURL:   record.FullURL,
+			UserID:    userID,
+			NoTrack:   record.NoTrack,
+			UpdatedAt: time.Now(),
+
+			RedirectOptions: foundation.RedirectOptions{RedirectStatus: http.StatusFound},
+			AccessOptions:   foundation.AccessOptions{Access: cmp.Or(record.Access, foundation.AccessPublic)},
+		}
+		return &linkChange{link: link, create: true, tags: record.Tags}, ImportCreate, "", nil
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+	err = loadTags(ctx, tx, []*foundation.Link{&existing})
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if userID == 0 {
+		// updates without an owner keep the current one
+		userID = existing.UserID
+	}
+	access := cmp.Or(record.Access, existing.Mode())
+	if access == foundation.AccessPassword && existing.PasswordHash == "" {
+		return nil, ImportConflict, "link has no password, set it in the link dialog", nil
+	}
+	sameTags := record.Tags == nil || sameTagNames(TagNames(existing.Tags), record.Tags)
+	if existing.FullURL == record.FullURL && existing.UserID == userID && existing.NoTrack == record.NoTrack && existing.Mode() == access && sameTags {
+		return nil, ImportUnchanged, "", nil
+	}
+	existing.FullURL = record.FullURL
+	existing.UserID = userID
+	existing.NoTrack = record.NoTrack
+	existing.Access = access
+	if access != foundation.AccessPassword {
+		existing.PasswordHash = ""
+	}
+	existing.UpdatedAt = time.Now()
+	return &linkChange{link: &existing, tags: record.Tags}, ImportUpdate, "", nil
+}
+
+// sameTagNames compares tag names regardless of order and case.
+func sameTagNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalize := func(names []string) []string {
+		lower := make([]string, len(names))
+		for i, name := range names {
+			lower[i] = strings.ToLower(name)
+		}
+		sort.Strings(lower)
+		return lower
+	}
+	return slices.Equal(normalize(a), normalize(b))
+}
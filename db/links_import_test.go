@@ -0,0 +1,169 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mbertschler/foundation"
+)
+
+func TestLinksExportRoundTrip(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	records, err := database.Links.Export(ctx, "")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	for _, format := range []FileFormat{FormatCSV, FormatJSON} {
+		var buf bytes.Buffer
+		err = WriteLinkRecords(&buf, format, records)
+		if err != nil {
+			t.Fatalf("WriteLinkRecords %s failed: %v", format, err)
+		}
+		read, err := ReadLinkRecords(&buf, format)
+		if err != nil {
+			t.Fatalf("ReadLinkRecords %s failed: %v", format, err)
+		}
+		if len(read) != 5 || read[0].ShortLink != "api" || read[0].Owner != "links" {
+			t.Fatalf("unexpected %s records %+v", format, read[0])
+		}
+
+		result, err := database.Links.Import(ctx, read, 0, false)
+		if err != nil {
+			t.Fatalf("Import %s failed: %v", format, err)
+		}
+		if result.Unchanged != 5 {
+			t.Errorf("expected re-importing an export to change nothing, got %+v", result)
+		}
+	}
+}
+
+func TestLinksImport(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+	user, err := database.Users.ByUsername(ctx, "links")
+	if err != nil {
+		t.Fatalf("ByUsername failed: %v", err)
+	}
+
+	csv := "short_link,full_url,owner\n" +
+		"new,https://new.example.com,\n" +
+		"docs,https://docs.example.com/v2,\n" +
+		"blog,https://blog.example.com/start,links\n"
+	records, err := ReadLinkRecords(strings.NewReader(csv), FormatCSV)
+	if err != nil {
+		t.Fatalf("ReadLinkRecords failed: %v", err)
+	}
+
+	result, err := database.Links.Import(ctx, records, user.ID, true)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Applied || result.Creates != 1 || result.Updates != 1 || result.Unchanged != 1 {
+		t.Errorf("unexpected dry run result %+v", result)
+	}
+	_, err = database.Links.ByShortLink(ctx, "new")
+	if err == nil {
+		t.Error("dry run should not create links")
+	}
+
+	conflicting := append(records, &LinkRecord{ShortLink: "bad link", FullURL: "https://example.com"},
+		&LinkRecord{ShortLink: "new", FullURL: "https://example.com"},
+		&LinkRecord{ShortLink: "other", FullURL: "https://example.com", Owner: "nobody"})
+	result, err = database.Links.Import(ctx, conflicting, user.ID, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Applied || result.Conflicts != 3 {
+		t.Errorf("expected 3 conflicts and no changes, got %+v", result)
+	}
+
+	result, err = database.Links.Import(ctx, records, user.ID, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !result.Applied {
+		t.Fatalf("expected the import to be applied, got %+v", result)
+	}
+	link, err := database.Links.ByShortLink(ctx, "docs")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+	if link.FullURL != "https://docs.example.com/v2" || link.UserID != user.ID {
+		t.Errorf("unexpected updated link %+v", link)
+	}
+	link, err = database.Links.ByShortLink(ctx, "new")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+	if link.UserID != user.ID {
+		t.Errorf("expected the importing user to own new links, got %d", link.UserID)
+	}
+}
+
+func TestLinksImportAccess(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+	_, err := database.bun.ExecContext(ctx, "UPDATE links SET access = 'password', password_hash = 'hash' WHERE short_link = 'docs'")
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	records, err := database.Links.Export(ctx, "")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	var buf bytes.Buffer
+	err = WriteLinkRecords(&buf, FormatCSV, records)
+	if err != nil {
+		t.Fatalf("WriteLinkRecords failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), ",password,") {
+		t.Errorf("expected the access in the export, got %s", buf.String())
+	}
+
+	csv := "short_link,full_url,access\n" +
+		"docs,https://docs.example.com/start,password\n" +
+		"blog,https://blog.example.com/start,login\n" +
+		"members,https://members.example.com,login\n" +
+		"shop,https://shop.example.com/start,\n"
+	records, err = ReadLinkRecords(strings.NewReader(csv), FormatCSV)
+	if err != nil {
+		t.Fatalf("ReadLinkRecords failed: %v", err)
+	}
+	conflicting := append(records, &LinkRecord{ShortLink: "secret", FullURL: "https://example.com", Access: "password"},
+		&LinkRecord{ShortLink: "api", FullURL: "https://api.example.com/start", Access: "password"},
+		&LinkRecord{ShortLink: "status", FullURL: "https://status.example.com/start", Access: "everyone"})
+	result, err := database.Links.Import(ctx, conflicting, 1, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Applied || result.Conflicts != 3 {
+		t.Errorf("expected 3 conflicts and no changes, got %+v", result)
+	}
+
+	result, err = database.Links.Import(ctx, records, 1, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !result.Applied || result.Creates != 1 || result.Updates != 1 || result.Unchanged != 2 {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+	for name, expected := range map[string]foundation.LinkAccess{
+		"docs":    foundation.AccessPassword,
+		"blog":    foundation.AccessLogin,
+		"members": foundation.AccessLogin,
+		"shop":    foundation.AccessPublic,
+	} {
+		link, err := database.Links.ByShortLink(ctx, name)
+		if err != nil {
+			t.Fatalf("ByShortLink failed: %v", err)
+		}
+		if link.Mode() != expected {
+			t.Errorf("expected %s to have access %s, got %s", name, expected, link.Mode())
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/foundation"
+)
+
+func startTestDB(t testing.TB) (*foundation.Context, *DB) {
+	ctx := &foundation.Context{
+		Context: context.Background(),
+		Config: &foundation.Config{
+			DBPath: filepath.Join(t.TempDir(), "foundation.db"),
+		},
+	}
+	database, err := StartDB(ctx)
+	if err != nil {
+		t.Fatalf("StartDB failed: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return ctx, database
+}
+
+func insertTestLinks(t testing.TB, ctx context.Context, database *DB) {
+	user := &foundation.User{
+		DisplayName:    "Links Test",
+		UserName:       "links",
+		HashedPassword: "x",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	err := database.Users.Insert(ctx, user)
+	if err != nil {
+		t.Fatalf("Users.Insert failed: %v", err)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"docs", "blog", "api", "status", "shop"} {
+		link := &foundation.Link{
+			ShortLink: name,
+			FullURL:   fmt.Sprintf("https://%s.example.com/start", name),
+			UserID:    user.ID,
+			CreatedAt: start.Add(time.Duration(i) * time.Hour),
+			UpdatedAt: start.Add(time.Duration(i) * time.Hour),
+		}
+		err = database.Links.Insert(ctx, link)
+		if err != nil {
+			t.Fatalf("Links.Insert failed: %v", err)
+		}
+		// the later a link was created, the more visits it has
+		for j := 0; j < i; j++ {
+			err = database.Visits.Insert(ctx, &foundation.LinkVisit{ShortLink: name})
+			if err != nil {
+				t.Fatalf("Visits.Insert failed: %v", err)
+			}
+		}
+	}
+}
+
+func shortLinks(links []*foundation.Link) string {
+	var names []string
+	for _, l := range links {
+		names = append(names, l.ShortLink)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLinksPagination(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	var pages []string
+	query := LinkQuery{Sort: SortVisits, Desc: true, Limit: 2}
+	for {
+		page, err := database.Links.Page(ctx, query)
+		if err != nil {
+			t.Fatalf("Page failed: %v", err)
+		}
+		if page.Total != 5 {
+			t.Errorf("expected total 5, got %d", page.Total)
+		}
+		pages = append(pages, shortLinks(page.Links))
+		if page.Next == "" {
+			break
+		}
+		query.After = page.Next
+	}
+
+	expected := "shop,status|api,blog|docs"
+	if got := strings.Join(pages, "|"); got != expected {
+		t.Errorf("expected pages %s, got %s", expected, got)
+	}
+}
+
+func TestLinksPaginationRenamedCursor(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	query := LinkQuery{Sort: SortVisits, Desc: true, Limit: 2}
+	page, err := database.Links.Page(ctx, query)
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	if got := shortLinks(page.Links); got != "shop,status" {
+		t.Fatalf("expected first page shop,status, got %s", got)
+	}
+
+	// the last link of the page is renamed before the next page is loaded
+	_, err = database.bun.ExecContext(ctx, "UPDATE links SET short_link = 'statuspage' WHERE short_link = 'status'")
+	if err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	query.After = page.Next
+	page, err = database.Links.Page(ctx, query)
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	if got := shortLinks(page.Links); got != "api,blog" {
+		t.Errorf("expected second page api,blog, got %s", got)
+	}
+
+	query.After = "invalid"
+	page, err = database.Links.Page(ctx, query)
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	if got := shortLinks(page.Links); got != "shop,statuspage" {
+		t.Errorf("expected invalid cursor to start at shop,statuspage, got %s", got)
+	}
+}
+
+func TestLinksSortAndSearch(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	testCases := []struct {
+		query    LinkQuery
+		expected string
+	}{
+		{LinkQuery{}, "api,blog,docs,shop,status"},
+		{LinkQuery{Sort: SortCreated}, "docs,blog,api,status,shop"},
+		{LinkQuery{Sort: SortUpdated, Desc: true}, "shop,status,api,blog,docs"},
+		{LinkQuery{Sort: SortOwner}, "api,blog,docs,shop,status"},
+		{LinkQuery{Sort: "invalid"}, "api,blog,docs,shop,status"},
+		{LinkQuery{Search: "sh"}, "shop"},
+		{LinkQuery{Search: "stat"}, "status"},
+		{LinkQuery{Search: "start example"}, "api,blog,docs,shop,status"},
+		{LinkQuery{Search: "blog.example"}, "blog"},
+		{LinkQuery{Search: `"unbalanced OR`}, ""},
+	}
+	for _, tc := range testCases {
+		page, err := database.Links.Page(ctx, tc.query)
+		if err != nil {
+			t.Fatalf("Page(%+v) failed: %v", tc.query, err)
+		}
+		if got := shortLinks(page.Links); got != tc.expected {
+			t.Errorf("Page(%+v) = %s, expected %s", tc.query, got, tc.expected)
+		}
+	}
+}
+
+func TestLinkVisitCounters(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	visitedAt := time.Date(2025, 2, 1, 23, 30, 0, 0, time.UTC)
+	err := database.Visits.Insert(ctx, &foundation.LinkVisit{ShortLink: "docs", VisitedAt: visitedAt})
+	if err != nil {
+		t.Fatalf("Visits.Insert failed: %v", err)
+	}
+
+	link, err := database.Links.ByShortLink(ctx, "shop")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+	if link.VisitsCount != 4 {
+		t.Errorf("expected 4 visits, got %d", link.VisitsCount)
+	}
+	if link.LastVisitedAt.IsZero() {
+		t.Error("expected LastVisitedAt to be set")
+	}
+
+	// updates must not overwrite the counter with a stale value
+	link.VisitsCount = 0
+	link.FullURL = "https://shop.example.com/new"
+	err = database.Links.Update(ctx, link)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	_, err = database.bun.NewDelete().Model((*foundation.LinkVisit)(nil)).Where("1 = 1").Exec(ctx)
+	if err != nil {
+		t.Fatalf("deleting visits failed: %v", err)
+	}
+
+	link, err = database.Links.ByShortLink(ctx, "shop")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+	if link.VisitsCount != 4 {
+		t.Errorf("expected 4 visits after pruning, got %d", link.VisitsCount)
+	}
+
+	days, err := database.Visits.Daily(ctx, "docs", visitedAt)
+	if err != nil {
+		t.Fatalf("Daily failed: %v", err)
+	}
+	if len(days) != 1 || days[0].Day != "2025-02-01" || days[0].Visits != 1 {
+		t.Errorf("unexpected daily visits %+v", days)
+	}
+}
+
+func TestLinkRenameKeepsVisits(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	visitedAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	err := database.Visits.Insert(ctx, &foundation.LinkVisit{ShortLink: "docs", Variant: "de", VisitedAt: visitedAt})
+	if err != nil {
+		t.Fatalf("Visits.Insert failed: %v", err)
+	}
+	link, err := database.Links.ByShortLink(ctx, "docs")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+
+	renamed := *link
+	renamed.ShortLink = "documentation"
+	err = database.Links.Rename(ctx, "docs", &renamed)
+	if err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	_, err = database.Links.ByShortLink(ctx, "docs")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the old link to be gone, got %v", err)
+	}
+
+	days, err := database.Visits.Daily(ctx, "documentation", visitedAt)
+	if err != nil {
+		t.Fatalf("Daily failed: %v", err)
+	}
+	if len(days) != 1 || days[0].Visits != 1 {
+		t.Errorf("expected the daily visits to move, got %+v", days)
+	}
+	variants, err := database.Visits.Variants(ctx, "documentation")
+	if err != nil {
+		t.Fatalf("Variants failed: %v", err)
+	}
+	if len(variants) != 1 || variants[0].Variant != "de" || variants[0].Visits != 1 {
+		t.Errorf("expected the variant visits to move, got %+v", variants)
+	}
+	count, err := database.Visits.CountByLink(ctx, "docs")
+	if err != nil || count != 0 {
+		t.Errorf("expected no visits left under the old short link, got %d: %v", count, err)
+	}
+}
+
+func TestVisitRetentionAndPurge(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	old := time.Now().Add(-48 * time.Hour)
+	err := database.Visits.Insert(ctx, &foundation.LinkVisit{ShortLink: "api", VisitedAt: old})
+	if err != nil {
+		t.Fatalf("Visits.Insert failed: %v", err)
+	}
+
+	config := *ctx.Config
+	config.VisitRetention = foundation.Duration(24 * time.Hour)
+	ctx.SetCurrent(&config)
+	_, err = database.Visits.deleteOld(ctx)
+	if err != nil {
+		t.Fatalf("deleteOld failed: %v", err)
+	}
+	count, err := database.Visits.CountByLink(ctx, "api")
+	if err != nil {
+		t.Fatalf("CountByLink failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 raw visits after retention, got %d", count)
+	}
+
+	err = database.Visits.Purge(ctx, "shop")
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	_, err = database.Visits.deleteOld(ctx)
+	if err != nil {
+		t.Fatalf("deleteOld failed: %v", err)
+	}
+
+	link, err := database.Links.ByShortLink(ctx, "shop")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+	if link.VisitsCount != 0 || !link.LastVisitedAt.IsZero() {
+		t.Errorf("expected reset counters, got %d visits at %v", link.VisitsCount, link.LastVisitedAt)
+	}
+	count, err = database.Visits.CountByLink(ctx, "shop")
+	if err != nil {
+		t.Fatalf("CountByLink failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected purged raw visits, got %d", count)
+	}
+	days, err := database.Visits.Daily(ctx, "shop", time.Time{})
+	if err != nil {
+		t.Fatalf("Daily failed: %v", err)
+	}
+	if len(days) != 0 {
+		t.Errorf("expected purged daily visits, got %+v", days)
+	}
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/mbertschler/foundation/metrics"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+var queryDuration = metrics.NewHistogramVec("foundation_db_query_duration_seconds",
+	"Duration of database queries by operation.", nil, "operation", "status")
+
+// metricsQueryHook records the latency of every query executed through bun.
+type metricsQueryHook struct{}
+
+func (metricsQueryHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (metricsQueryHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	status := "ok"
+	// a missing row is a normal result, like for unknown short links
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		status = "error"
+	}
+	queryDuration.WithLabelValues(event.Operation(), status).ObserveSince(event.StartTime)
+}
+
+func registerSessionMetrics(db *bun.DB) {
+	metrics.NewGaugeVecFunc("foundation_sessions",
+		"Number of unexpired sessions by kind.", "kind", func() map[string]float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var counts []struct {
+				Kind  string `bun:"kind"`
+				Count int64  `bun:"count"`
+			}
+			err := db.NewSelect().Model(nilSession).
+				ColumnExpr("CASE WHEN user_id IS NULL THEN 'anonymous' ELSE 'user' END AS kind").
+				ColumnExpr("COUNT(*) AS count").
+				Where("expires_at >= ?", time.Now()).
+				GroupExpr("kind").Scan(ctx, &counts)
+			if err != nil {
+				log.Println("session metrics error:", err)
+				return nil
+			}
+
+			values := map[string]float64{"anonymous": 0, "user": 0}
+			for _, c := range counts {
+				values[c.Kind] = float64(c.Count)
+			}
+			return values
+		})
+}
@@ -0,0 +1,21 @@
+DROP INDEX IF EXISTS links_updated_at_idx;
+
+--bun:split
+
+DROP INDEX IF EXISTS links_created_at_idx;
+
+--bun:split
+
+DROP TRIGGER IF EXISTS links_fts_update;
+
+--bun:split
+
+DROP TRIGGER IF EXISTS links_fts_delete;
+
+--bun:split
+
+DROP TRIGGER IF EXISTS links_fts_insert;
+
+--bun:split
+
+DROP TABLE IF EXISTS links_fts;
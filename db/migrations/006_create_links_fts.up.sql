@@ -0,0 +1,37 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS links_fts USING fts5(
+    short_link,
+    full_url,
+    content='links',
+    content_rowid='rowid'
+);
+
+--bun:split
+
+CREATE TRIGGER IF NOT EXISTS links_fts_insert AFTER INSERT ON links BEGIN
+    INSERT INTO links_fts(rowid, short_link, full_url) VALUES (new.rowid, new.short_link, new.full_url);
+END;
+
+--bun:split
+
+CREATE TRIGGER IF NOT EXISTS links_fts_delete AFTER DELETE ON links BEGIN
+    INSERT INTO links_fts(links_fts, rowid, short_link, full_url) VALUES ('delete', old.rowid, old.short_link, old.full_url);
+END;
+
+--bun:split
+
+CREATE TRIGGER IF NOT EXISTS links_fts_update AFTER UPDATE ON links BEGIN
+    INSERT INTO links_fts(links_fts, rowid, short_link, full_url) VALUES ('delete', old.rowid, old.short_link, old.full_url);
+    INSERT INTO links_fts(rowid, short_link, full_url) VALUES (new.rowid, new.short_link, new.full_url);
+END;
+
+--bun:split
+
+INSERT INTO links_fts(links_fts) VALUES ('rebuild');
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS links_created_at_idx ON links(created_at);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS links_updated_at_idx ON links(updated_at);
@@ -0,0 +1,40 @@
+DROP INDEX IF EXISTS links_last_visited_at_idx;
+
+--bun:split
+
+DROP INDEX IF EXISTS links_visits_count_idx;
+
+--bun:split
+
+DROP INDEX IF EXISTS link_visits_visited_at_idx;
+
+--bun:split
+
+DROP INDEX IF EXISTS link_visits_short_link_idx;
+
+--bun:split
+
+DROP TRIGGER IF EXISTS links_fts_update;
+
+--bun:split
+
+CREATE TRIGGER IF NOT EXISTS links_fts_update AFTER UPDATE ON links BEGIN
+    INSERT INTO links_fts(links_fts, rowid, short_link, full_url) VALUES ('delete', old.rowid, old.short_link, old.full_url);
+    INSERT INTO links_fts(rowid, short_link, full_url) VALUES (new.rowid, new.short_link, new.full_url);
+END;
+
+--bun:split
+
+DROP TRIGGER IF EXISTS link_visits_count_insert;
+
+--bun:split
+
+DROP TABLE IF EXISTS link_visits_daily;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN last_visited_at;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN visits_count;
@@ -0,0 +1,67 @@
+ALTER TABLE links ADD COLUMN visits_count INTEGER NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE links ADD COLUMN last_visited_at TEXT;
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS link_visits_daily (
+    short_link TEXT NOT NULL,
+    day TEXT NOT NULL,
+    visits INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY(short_link, day)
+) WITHOUT ROWID;
+
+--bun:split
+
+UPDATE links SET
+    visits_count = (SELECT COUNT(*) FROM link_visits WHERE short_link = links.short_link),
+    last_visited_at = (SELECT MAX(visited_at) FROM link_visits WHERE short_link = links.short_link);
+
+--bun:split
+
+INSERT INTO link_visits_daily(short_link, day, visits)
+    SELECT short_link, date(visited_at), COUNT(*) FROM link_visits GROUP BY short_link, date(visited_at);
+
+--bun:split
+
+-- the counters and the rollup are not decremented when raw visits are
+-- deleted, so that old visits can be pruned without losing the totals
+CREATE TRIGGER IF NOT EXISTS link_visits_count_insert AFTER INSERT ON link_visits BEGIN
+    UPDATE links SET
+        visits_count = visits_count + 1,
+        last_visited_at = MAX(COALESCE(last_visited_at, ''), new.visited_at)
+    WHERE short_link = new.short_link;
+    INSERT INTO link_visits_daily(short_link, day, visits) VALUES (new.short_link, date(new.visited_at), 1)
+        ON CONFLICT(short_link, day) DO UPDATE SET visits = visits + 1;
+END;
+
+--bun:split
+
+-- counter updates don't change the indexed text, so only rebuild the
+-- search index when the short link or URL change
+DROP TRIGGER IF EXISTS links_fts_update;
+
+--bun:split
+
+CREATE TRIGGER IF NOT EXISTS links_fts_update AFTER UPDATE OF short_link, full_url ON links BEGIN
+    INSERT INTO links_fts(links_fts, rowid, short_link, full_url) VALUES ('delete', old.rowid, old.short_link, old.full_url);
+    INSERT INTO links_fts(rowid, short_link, full_url) VALUES (new.rowid, new.short_link, new.full_url);
+END;
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS link_visits_short_link_idx ON link_visits(short_link, visited_at);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS link_visits_visited_at_idx ON link_visits(visited_at);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS links_visits_count_idx ON links(visits_count);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS links_last_visited_at_idx ON links(last_visited_at);
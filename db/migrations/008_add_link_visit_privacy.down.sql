@@ -0,0 +1,5 @@
+ALTER TABLE links DROP COLUMN visits_purged_at;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN no_track;
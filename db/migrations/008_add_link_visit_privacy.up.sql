@@ -0,0 +1,5 @@
+ALTER TABLE links ADD COLUMN no_track INTEGER NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE links ADD COLUMN visits_purged_at TEXT;
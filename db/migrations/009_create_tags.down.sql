@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS link_tags_tag_id_idx;
+
+--bun:split
+
+DROP TABLE IF EXISTS link_tags;
+
+--bun:split
+
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f','now'))
+);
+
+--bun:split
+
+CREATE TABLE IF NOT EXISTS link_tags (
+    short_link TEXT NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY(short_link, tag_id),
+    FOREIGN KEY(short_link) REFERENCES links(short_link),
+    FOREIGN KEY(tag_id) REFERENCES tags(id)
+) WITHOUT ROWID;
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS link_tags_tag_id_idx ON link_tags(tag_id);
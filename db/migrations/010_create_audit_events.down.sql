@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS audit_events_actor_name_idx;
+
+--bun:split
+
+DROP INDEX IF EXISTS audit_events_target_idx;
+
+--bun:split
+
+DROP INDEX IF EXISTS audit_events_action_idx;
+
+--bun:split
+
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f','now')),
+    actor_id INTEGER,
+    actor_name TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    target TEXT NOT NULL DEFAULT '',
+    changes TEXT,
+    ip TEXT NOT NULL DEFAULT ''
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS audit_events_action_idx ON audit_events(action, id);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS audit_events_target_idx ON audit_events(target, id);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS audit_events_actor_name_idx ON audit_events(actor_name, id);
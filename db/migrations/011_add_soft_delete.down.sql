@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS users_deleted_at_idx;
+
+--bun:split
+
+DROP INDEX IF EXISTS links_deleted_at_idx;
+
+--bun:split
+
+ALTER TABLE users DROP COLUMN deleted_at;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN deleted_at;
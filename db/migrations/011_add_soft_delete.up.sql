@@ -0,0 +1,13 @@
+ALTER TABLE links ADD COLUMN deleted_at TEXT;
+
+--bun:split
+
+ALTER TABLE users ADD COLUMN deleted_at TEXT;
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS links_deleted_at_idx ON links(deleted_at);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users(deleted_at);
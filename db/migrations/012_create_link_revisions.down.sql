@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS link_revisions_short_link_idx;
+
+--bun:split
+
+DROP TABLE IF EXISTS link_revisions;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS link_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    short_link TEXT NOT NULL,
+    full_url TEXT NOT NULL,
+    no_track INTEGER NOT NULL DEFAULT 0,
+    tags TEXT NOT NULL DEFAULT '[]',
+    action TEXT NOT NULL,
+    renamed_from TEXT NOT NULL DEFAULT '',
+    reverted_from INTEGER,
+    actor_id INTEGER,
+    actor_name TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f','now'))
+);
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS link_revisions_short_link_idx ON link_revisions(short_link, id);
+
+--bun:split
+
+-- the current state of existing links is their first revision
+INSERT INTO link_revisions (short_link, full_url, no_track, tags, action, actor_id, actor_name, created_at)
+SELECT l.short_link, l.full_url, l.no_track,
+    (SELECT json_group_array(name) FROM (
+        SELECT t.name FROM link_tags AS lt JOIN tags AS t ON t.id = lt.tag_id
+        WHERE lt.short_link = l.short_link ORDER BY t.name COLLATE NOCASE
+    )),
+    'create', l.user_id, COALESCE(u.user_name, ''), l.updated_at
+FROM links AS l LEFT JOIN users AS u ON u.id = l.user_id;
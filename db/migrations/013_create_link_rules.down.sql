@@ -0,0 +1,17 @@
+ALTER TABLE link_revisions DROP COLUMN rules;
+
+--bun:split
+
+DROP INDEX IF EXISTS link_visits_variant_idx;
+
+--bun:split
+
+ALTER TABLE link_visits DROP COLUMN variant;
+
+--bun:split
+
+DROP INDEX IF EXISTS link_rules_short_link_idx;
+
+--bun:split
+
+DROP TABLE IF EXISTS link_rules;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS link_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    short_link TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    variant TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    value TEXT NOT NULL,
+    full_url TEXT NOT NULL,
+    FOREIGN KEY(short_link) REFERENCES links(short_link)
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX IF NOT EXISTS link_rules_short_link_idx ON link_rules(short_link, position);
+
+--bun:split
+
+ALTER TABLE link_visits ADD COLUMN variant TEXT NOT NULL DEFAULT '';
+
+--bun:split
+
+CREATE INDEX IF NOT EXISTS link_visits_variant_idx ON link_visits(short_link, variant);
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN rules TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,37 @@
+ALTER TABLE link_revisions DROP COLUMN utm_campaign;
+
+--bun:split
+
+ALTER TABLE link_revisions DROP COLUMN utm_source;
+
+--bun:split
+
+ALTER TABLE link_revisions DROP COLUMN forward_path;
+
+--bun:split
+
+ALTER TABLE link_revisions DROP COLUMN forward_query;
+
+--bun:split
+
+ALTER TABLE link_revisions DROP COLUMN redirect_status;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN utm_campaign;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN utm_source;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN forward_path;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN forward_query;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN redirect_status;
@@ -0,0 +1,37 @@
+ALTER TABLE links ADD COLUMN redirect_status INTEGER NOT NULL DEFAULT 302;
+
+--bun:split
+
+ALTER TABLE links ADD COLUMN forward_query INTEGER NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE links ADD COLUMN forward_path INTEGER NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE links ADD COLUMN utm_source TEXT NOT NULL DEFAULT '';
+
+--bun:split
+
+ALTER TABLE links ADD COLUMN utm_campaign TEXT NOT NULL DEFAULT '';
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN redirect_status INTEGER NOT NULL DEFAULT 302;
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN forward_query INTEGER NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN forward_path INTEGER NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN utm_source TEXT NOT NULL DEFAULT '';
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN utm_campaign TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+ALTER TABLE link_revisions DROP COLUMN preview;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN preview;
@@ -0,0 +1,5 @@
+ALTER TABLE links ADD COLUMN preview INTEGER NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN preview INTEGER NOT NULL DEFAULT 0;
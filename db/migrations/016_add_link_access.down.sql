@@ -0,0 +1,13 @@
+ALTER TABLE link_revisions DROP COLUMN password_hash;
+
+--bun:split
+
+ALTER TABLE link_revisions DROP COLUMN access;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN password_hash;
+
+--bun:split
+
+ALTER TABLE links DROP COLUMN access;
@@ -0,0 +1,13 @@
+ALTER TABLE links ADD COLUMN access TEXT NOT NULL DEFAULT 'public';
+
+--bun:split
+
+ALTER TABLE links ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN access TEXT NOT NULL DEFAULT 'public';
+
+--bun:split
+
+ALTER TABLE link_revisions ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
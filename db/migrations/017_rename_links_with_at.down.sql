@@ -0,0 +1,3 @@
+-- The renamed links keep their new short links, links created since then
+-- could already use the old ones.
+SELECT 1;
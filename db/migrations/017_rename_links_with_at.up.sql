@@ -0,0 +1,60 @@
+-- Links on other domains are stored as "name@host". Links from before
+-- domains existed that have an @ in their short link would be read as
+-- links on a domain and couldn't be visited anymore, so they are renamed
+-- by replacing the @ with -at-. Names that are taken get the rowid of the
+-- link added.
+CREATE TABLE link_at_renames (
+    old_short_link TEXT PRIMARY KEY,
+    new_short_link TEXT NOT NULL,
+    link_rowid INTEGER NOT NULL
+);
+
+--bun:split
+
+INSERT INTO link_at_renames (old_short_link, new_short_link, link_rowid)
+SELECT short_link, replace(short_link, '@', '-at-'), rowid FROM links WHERE short_link LIKE '%@%';
+
+--bun:split
+
+UPDATE link_at_renames SET new_short_link = new_short_link || '-' || link_rowid
+WHERE new_short_link IN (SELECT short_link FROM links)
+    OR new_short_link IN (SELECT r.new_short_link FROM link_at_renames AS r WHERE r.rowid < link_at_renames.rowid);
+
+--bun:split
+
+UPDATE links SET short_link = (SELECT new_short_link FROM link_at_renames WHERE old_short_link = links.short_link)
+WHERE short_link IN (SELECT old_short_link FROM link_at_renames);
+
+--bun:split
+
+UPDATE link_visits SET short_link = (SELECT new_short_link FROM link_at_renames WHERE old_short_link = link_visits.short_link)
+WHERE short_link IN (SELECT old_short_link FROM link_at_renames);
+
+--bun:split
+
+UPDATE link_visits_daily SET short_link = (SELECT new_short_link FROM link_at_renames WHERE old_short_link = link_visits_daily.short_link)
+WHERE short_link IN (SELECT old_short_link FROM link_at_renames);
+
+--bun:split
+
+UPDATE link_tags SET short_link = (SELECT new_short_link FROM link_at_renames WHERE old_short_link = link_tags.short_link)
+WHERE short_link IN (SELECT old_short_link FROM link_at_renames);
+
+--bun:split
+
+UPDATE link_rules SET short_link = (SELECT new_short_link FROM link_at_renames WHERE old_short_link = link_rules.short_link)
+WHERE short_link IN (SELECT old_short_link FROM link_at_renames);
+
+--bun:split
+
+UPDATE link_revisions SET short_link = (SELECT new_short_link FROM link_at_renames WHERE old_short_link = link_revisions.short_link)
+WHERE short_link IN (SELECT old_short_link FROM link_at_renames);
+
+--bun:split
+
+UPDATE link_revisions SET renamed_from = (SELECT new_short_link FROM link_at_renames WHERE old_short_link = link_revisions.renamed_from)
+WHERE renamed_from IN (SELECT old_short_link FROM link_at_renames);
+
+--bun:split
+
+DROP TABLE link_at_renames;
@@ -0,0 +1 @@
+ALTER TABLE link_revisions ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Revisions only keep the access mode, so that reverting can't bring back
+-- an old link password.
+ALTER TABLE link_revisions DROP COLUMN password_hash;
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/foundation"
+)
+
+// runMigrationFile runs an up migration again on an already migrated
+// database, after the test added the data that it changes.
+func runMigrationFile(t *testing.T, database *DB, name string) {
+	data, err := os.ReadFile("migrations/" + name)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	for _, query := range strings.Split(string(data), "--bun:split") {
+		_, err = database.bun.Exec(query)
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+	}
+}
+
+func TestMigrationRenameLinksWithAt(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	visitedAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	err := database.Visits.Insert(ctx, &foundation.LinkVisit{ShortLink: "docs", VisitedAt: visitedAt})
+	if err != nil {
+		t.Fatalf("Visits.Insert failed: %v", err)
+	}
+	for _, query := range []string{
+		"UPDATE links SET short_link = 'team-at-work' WHERE short_link = 'blog'",
+		"UPDATE links SET short_link = 'team@work' WHERE short_link = 'docs'",
+		"UPDATE link_visits SET short_link = 'team@work' WHERE short_link = 'docs'",
+		"UPDATE link_visits_daily SET short_link = 'team@work' WHERE short_link = 'docs'",
+		"UPDATE links SET short_link = 'a@b@c' WHERE short_link = 'api'",
+	} {
+		_, err = database.bun.ExecContext(ctx, query)
+		if err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+	}
+
+	runMigrationFile(t, database, "017_rename_links_with_at.up.sql")
+
+	page, err := database.Links.Page(ctx, LinkQuery{})
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	// the taken name gets the rowid of the link, docs was inserted first
+	expected := "a-at-b-at-c,shop,status,team-at-work,team-at-work-1"
+	if got := shortLinks(page.Links); got != expected {
+		t.Errorf("expected links %s, got %s", expected, got)
+	}
+	count, err := database.Visits.CountByLink(ctx, "team-at-work-1")
+	if err != nil || count != 1 {
+		t.Errorf("expected the visit to move, got %d: %v", count, err)
+	}
+	days, err := database.Visits.Daily(ctx, "team-at-work-1", visitedAt)
+	if err != nil {
+		t.Fatalf("Daily failed: %v", err)
+	}
+	if len(days) != 1 || days[0].Visits != 1 {
+		t.Errorf("expected the daily visits to move, got %+v", days)
+	}
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/metrics"
+	"github.com/pkg/errors"
+)
+
+// DefaultRedirectCacheSize is the number of links that the redirect cache
+// holds. Up to a quarter as many unknown short links are remembered too.
+const DefaultRedirectCacheSize = 10000
+
+// Cached entries expire, so that changes by other processes like the links
+// import command are seen eventually. Missing short links expire sooner,
+// so that new links work right away.
+const (
+	redirectCacheTTL = time.Minute
+	missingCacheTTL  = 10 * time.Second
+)
+
+var redirectCacheTotal = metrics.NewCounterVec("foundation_redirect_cache_total",
+	"Number of redirect lookups by cache result.", "result")
+
+// Redirect is what ShortLinkHandler needs to redirect a visit of a link.
+// The values are shared by all visits and must not be modified.
+type Redirect struct {
+	Link  *foundation.Link
+	Rules []*foundation.LinkRule
+}
+
+// RedirectCache keeps the most recently visited links in memory, so that
+// redirects don't need to query the database. Short links that don't
+// exist are cached as well, so that scanners guessing short links don't
+// reach the database either. The cache has to be cleared whenever a link
+// changes, see InvalidateOn. Changes from other processes are only seen
+// when the entries expire.
+type RedirectCache struct {
+	db *DB
+
+	mutex   sync.Mutex
+	found   *lru
+	missing *lru
+	// generation is increased by Clear, so that lookups that started
+	// before don't add stale results.
+	generation uint64
+	now        func() time.Time
+}
+
+func NewRedirectCache(database *DB, size int) *RedirectCache {
+	return &RedirectCache{
+		db:      database,
+		found:   newLRU(size, redirectCacheTTL),
+		missing: newLRU(max(size/4, 1), missingCacheTTL),
+		now:     time.Now,
+	}
+}
+
+// Lookup returns the redirect of a short link. It returns sql.ErrNoRows if
+// the link doesn't exist or is in the trash.
+func (c *RedirectCache) Lookup(ctx context.Context, shortLink string) (*Redirect, error) {
+	c.mutex.Lock()
+	now := c.now()
+	if value, ok := c.found.get(shortLink, now); ok {
+		c.mutex.Unlock()
+		redirectCacheTotal.WithLabelValues("hit").Inc()
+		return value.(*Redirect), nil
+	}
+	if _, ok := c.missing.get(shortLink, now); ok {
+		c.mutex.Unlock()
+		redirectCacheTotal.WithLabelValues("missing").Inc()
+		return nil, sql.ErrNoRows
+	}
+	generation := c.generation
+	c.mutex.Unlock()
+	redirectCacheTotal.WithLabelValues("miss").Inc()
+
+	redirect, err := c.load(ctx, shortLink)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.generation == generation {
+		if redirect != nil {
+			c.found.add(shortLink, redirect, now)
+		} else {
+			c.missing.add(shortLink, nil, now)
+		}
+	}
+	return redirect, err
+}
+
+func (c *RedirectCache) load(ctx context.Context, shortLink string) (*Redirect, error) {
+	link, err := c.db.Links.ByShortLink(ctx, shortLink)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := c.db.Rules.ByShortLink(ctx, shortLink)
+	if err != nil {
+		return nil, err
+	}
+	return &Redirect{Link: link, Rules: rules}, nil
+}
+
+// Clear removes all cached links.
+func (c *RedirectCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	c.found.clear()
+	c.missing.clear()
+}
+
+// InvalidateOn clears the cache for every message on changed, until it is
+// closed. It is meant to run in its own goroutine.
+func (c *RedirectCache) InvalidateOn(changed <-chan struct{}) {
+	for range changed {
+		c.Clear()
+	}
+}
+
+// lru is a map that evicts the least recently used entry when it is full,
+// and entries that were added longer than ttl ago.
+type lru struct {
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) get(key string, now time.Time) (any, bool) {
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !now.Before(entry.expires) {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (l *lru) add(key string, value any, now time.Time) {
+	expires := now.Add(l.ttl)
+	if elem, ok := l.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) clear() {
+	l.order.Init()
+	clear(l.entries)
+}
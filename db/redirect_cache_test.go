@@ -0,0 +1,160 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mbertschler/foundation"
+)
+
+func TestRedirectCache(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+	cache := NewRedirectCache(database, 2)
+
+	redirect, err := cache.Lookup(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if redirect.Link.FullURL != "https://docs.example.com/start" {
+		t.Errorf("unexpected link %+v", redirect.Link)
+	}
+	_, err = cache.Lookup(ctx, "missing")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	// changes in the database are not seen until the cache is cleared
+	_, err = database.bun.NewUpdate().Model(nilLink).Set("full_url = ?", "https://docs.example.com/v2").
+		Where("short_link = ?", "docs").Exec(ctx)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	err = database.Links.Insert(ctx, &foundation.Link{ShortLink: "missing", FullURL: "https://missing.example.com", UserID: redirect.Link.UserID, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	redirect, err = cache.Lookup(ctx, "docs")
+	if err != nil || redirect.Link.FullURL != "https://docs.example.com/start" {
+		t.Errorf("expected the cached link, got %+v: %v", redirect, err)
+	}
+	_, err = cache.Lookup(ctx, "missing")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the cached miss, got %v", err)
+	}
+
+	changed := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		cache.InvalidateOn(changed)
+		close(done)
+	}()
+	changed <- struct{}{}
+	close(changed)
+	<-done
+	redirect, err = cache.Lookup(ctx, "docs")
+	if err != nil || redirect.Link.FullURL != "https://docs.example.com/v2" {
+		t.Errorf("expected the changed link, got %+v: %v", redirect, err)
+	}
+	_, err = cache.Lookup(ctx, "missing")
+	if err != nil {
+		t.Errorf("expected the new link, got %v", err)
+	}
+
+	// the least recently used link is evicted
+	_, err = cache.Lookup(ctx, "blog")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if _, ok := cache.found.get("docs", time.Now()); ok || cache.found.order.Len() != 2 {
+		t.Errorf("expected docs to be evicted, got %d entries", cache.found.order.Len())
+	}
+}
+
+func TestRedirectCacheExpiry(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+	cache := NewRedirectCache(database, DefaultRedirectCacheSize)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	_, err := cache.Lookup(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	_, err = cache.Lookup(ctx, "missing")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	// another process changes the links without clearing the cache
+	_, err = database.bun.NewUpdate().Model(nilLink).Set("full_url = ?", "https://docs.example.com/v2").
+		Where("short_link = ?", "docs").Exec(ctx)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	err = database.Links.Insert(ctx, &foundation.Link{ShortLink: "missing", FullURL: "https://missing.example.com", UserID: 1, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	now = now.Add(missingCacheTTL)
+	_, err = cache.Lookup(ctx, "missing")
+	if err != nil {
+		t.Errorf("expected the new link after the miss expired, got %v", err)
+	}
+	redirect, err := cache.Lookup(ctx, "docs")
+	if err != nil || redirect.Link.FullURL != "https://docs.example.com/start" {
+		t.Errorf("expected the cached link, got %+v: %v", redirect, err)
+	}
+
+	now = now.Add(redirectCacheTTL)
+	redirect, err = cache.Lookup(ctx, "docs")
+	if err != nil || redirect.Link.FullURL != "https://docs.example.com/v2" {
+		t.Errorf("expected the changed link after it expired, got %+v: %v", redirect, err)
+	}
+}
+
+// The benchmarks compare redirect lookups from the database to lookups
+// from the cache, run them with go test -bench Redirect ./db
+func BenchmarkRedirectLookup(b *testing.B) {
+	ctx, database := startTestDB(b)
+	insertTestLinks(b, ctx, database)
+	names := []string{"docs", "blog", "api", "status", "shop"}
+
+	b.Run("database", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				_, err := database.Redirects.load(ctx, names[i%len(names)])
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+	b.Run("cache", func(b *testing.B) {
+		cache := NewRedirectCache(database, DefaultRedirectCacheSize)
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				_, err := cache.Lookup(ctx, names[i%len(names)])
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+	b.Run("cache-missing", func(b *testing.B) {
+		cache := NewRedirectCache(database, DefaultRedirectCacheSize)
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				_, err := cache.Lookup(ctx, fmt.Sprintf("scan%d", i%100))
+				if !errors.Is(err, sql.ErrNoRows) {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+// MaxLinkRevisions is the number of revisions shown in the history of a
+// link. Older revisions are kept.
+const MaxLinkRevisions = 50
+
+var nilRevision *foundation.LinkRevision
+
+type revisionsDB struct {
+	db *bun.DB
+}
+
+// NewLinkRevision returns a revision of the current state of link with
+// tags and rules, formatted by FormatLinkRules, which has to be completed
+// with the actor.
+func NewLinkRevision(link *foundation.Link, tags []string, rules string, action string) *foundation.LinkRevision {
+	if tags == nil {
+		tags = []string{}
+	}
+	return &foundation.LinkRevision{
+		ShortLink: link.ShortLink,
+		FullURL:   link.FullURL,
+		NoTrack:   link.NoTrack,
+		Tags:      tags,
+		Rules:     rules,
+
+		RedirectOptions: link.RedirectOptions,
+		Access:          link.Access,
+		Action:          action,
+		CreatedAt:       time.Now(),
+	}
+}
+
+// Insert records rev. Call it with the ctx of the transaction of the change.
+func (r *revisionsDB) Insert(ctx context.Context, rev *foundation.LinkRevision) error {
+	_, err := conn(ctx, r.db).NewInsert().Model(rev).Exec(ctx)
+	return errors.Wrap(err, "insert link revision")
+}
+
+// ByShortLink returns the newest revisions of a link, newest first.
+func (r *revisionsDB) ByShortLink(ctx context.Context, shortLink string) ([]*foundation.LinkRevision, error) {
+	var revisions []*foundation.LinkRevision
+	err := conn(ctx, r.db).NewSelect().Model(&revisions).Where("short_link = ?", shortLink).
+		OrderExpr("id DESC").Limit(MaxLinkRevisions).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *revisionsDB) ByID(ctx context.Context, id int64) (*foundation.LinkRevision, error) {
+	var rev foundation.LinkRevision
+	err := conn(ctx, r.db).NewSelect().Model(&rev).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// Move moves the history of a link to its new short link after a rename.
+func (r *revisionsDB) Move(ctx context.Context, from, to string) error {
+	_, err := conn(ctx, r.db).NewUpdate().Model(nilRevision).
+		Set("short_link = ?", to).Where("short_link = ?", from).Exec(ctx)
+	return errors.Wrap(err, "move link revisions")
+}
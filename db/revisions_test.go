@@ -0,0 +1,53 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinkRevisions(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+	user, err := database.Users.ByUsername(ctx, "links")
+	if err != nil {
+		t.Fatalf("ByUsername failed: %v", err)
+	}
+
+	records := []*LinkRecord{
+		{ShortLink: "docs", FullURL: "https://docs.example.com/v2", Tags: []string{"team-a"}},
+		{ShortLink: "new", FullURL: "https://new.example.com"},
+	}
+	_, err = database.Links.Import(ctx, records, user.ID, false)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	revisions, err := database.Revisions.ByShortLink(ctx, "docs")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("expected one revision of docs, got %d", len(revisions))
+	}
+	rev := revisions[0]
+	if rev.Action != "import" || rev.FullURL != "https://docs.example.com/v2" || strings.Join(rev.Tags, ",") != "team-a" || rev.ActorName != "links" {
+		t.Errorf("unexpected revision %+v", rev)
+	}
+
+	err = database.Revisions.Move(ctx, "docs", "documentation")
+	if err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	revisions, err = database.Revisions.ByShortLink(ctx, "documentation")
+	if err != nil || len(revisions) != 1 {
+		t.Fatalf("expected the revision to move, got %d: %v", len(revisions), err)
+	}
+
+	err = database.Links.Purge(ctx, "new")
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	revisions, err = database.Revisions.ByShortLink(ctx, "new")
+	if err != nil || len(revisions) != 0 {
+		t.Errorf("expected the revisions of a purged link to be deleted, got %d: %v", len(revisions), err)
+	}
+}
@@ -14,8 +14,10 @@ import (
 )
 
 var (
-	SessionLength           = 32
-	CSRFTokenLength         = 32
+	SessionLength   = 32
+	CSRFTokenLength = 32
+	// SessionDuration and SessionRotationInterval are the defaults for the
+	// config values with the same name.
 	SessionDuration         = 90 * 24 * time.Hour
 	SessionRotationInterval = 30 * time.Minute
 )
@@ -23,7 +25,20 @@ var (
 var nilSession *foundation.Session
 
 type sessionsDB struct {
-	db *bun.DB
+	db     *bun.DB
+	config func() *foundation.Config
+}
+
+func (s *sessionsDB) durations() (duration, rotation time.Duration) {
+	duration, rotation = SessionDuration, SessionRotationInterval
+	config := s.config()
+	if config.SessionDuration > 0 {
+		duration = config.SessionDuration.Duration()
+	}
+	if config.SessionRotationInterval > 0 {
+		rotation = config.SessionRotationInterval.Duration()
+	}
+	return duration, rotation
 }
 
 // InsertUserSession creates a new session for a user
@@ -47,11 +62,12 @@ func (s *sessionsDB) insertSession(ctx context.Context, userID sql.NullInt64) (*
 		return nil, err
 	}
 
+	duration, _ := s.durations()
 	session := &foundation.Session{
 		ID:        sessionID,
 		UserID:    userID,
 		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(SessionDuration),
+		ExpiresAt: time.Now().Add(duration),
 		CSRFToken: csrfToken,
 	}
 
@@ -94,8 +110,9 @@ func (s *sessionsDB) RotateSessionIfNeeded(ctx context.Context, sessionID string
 		return currentSession, nil
 	}
 
-	// Check if rotation is needed (more than 30 minutes since creation)
-	if time.Since(currentSession.CreatedAt) <= SessionRotationInterval {
+	// Check if rotation is needed
+	duration, rotation := s.durations()
+	if time.Since(currentSession.CreatedAt) <= rotation {
 		return currentSession, nil
 	}
 
@@ -115,7 +132,7 @@ func (s *sessionsDB) RotateSessionIfNeeded(ctx context.Context, sessionID string
 		ID:        newSessionID,
 		UserID:    currentSession.UserID,
 		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(SessionDuration),
+		ExpiresAt: time.Now().Add(duration),
 		CSRFToken: newCSRFToken,
 	}
 
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// MaxTagLength is the maximum number of characters of a tag name.
+	MaxTagLength = 50
+	// MaxLinkTags is the maximum number of tags of one link.
+	MaxLinkTags = 20
+)
+
+type tagsDB struct {
+	db *bun.DB
+}
+
+// activeLinkTags selects the link_tags of links that are not in the trash.
+const activeLinkTags = "SELECT lt.tag_id FROM link_tags AS lt JOIN links AS tl ON tl.short_link = lt.short_link WHERE tl.deleted_at IS NULL"
+
+// All returns the tags of links that are not in the trash by name, with
+// the number of links of each tag.
+func (t *tagsDB) All(ctx context.Context) ([]*foundation.Tag, error) {
+	var tags []*foundation.Tag
+	err := conn(ctx, t.db).NewSelect().Model(&tags).ColumnExpr("t.*").
+		ColumnExpr("(SELECT COUNT(*) FROM (" + activeLinkTags + ") WHERE tag_id = t.id) AS links_count").
+		Where("t.id IN (" + activeLinkTags + ")").
+		OrderExpr("t.name COLLATE NOCASE ASC").Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+	return tags, nil
+}
+
+// Set replaces the tags of a link with the tags named names, which are
+// created if they don't exist yet.
+func (t *tagsDB) Set(ctx context.Context, shortLink string, names []string) error {
+	return runInTx(ctx, t.db, func(ctx context.Context) error {
+		return setTags(ctx, conn(ctx, t.db), shortLink, names)
+	})
+}
+
+// ParseTags splits a comma separated list of tag names. Names are trimmed
+// and duplicates are removed regardless of case.
+func ParseTags(s string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.Join(strings.Fields(name), " ")
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// CheckTags returns an error if names can't be the tags of a link.
+func CheckTags(names []string) error {
+	if len(names) > MaxLinkTags {
+		return errors.Errorf("at most %d tags are allowed", MaxLinkTags)
+	}
+	for _, name := range names {
+		if utf8.RuneCountInString(name) > MaxTagLength {
+			return errors.Errorf("tag %q is longer than %d characters", name, MaxTagLength)
+		}
+		if strings.Contains(name, ",") {
+			return errors.Errorf("tag %q contains a comma", name)
+		}
+	}
+	return nil
+}
+
+// TagNames returns the names of tags.
+func TagNames(tags []*foundation.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func setTags(ctx context.Context, idb bun.IDB, shortLink string, names []string) error {
+	_, err := idb.NewDelete().Model((*foundation.LinkTag)(nil)).Where("short_link = ?", shortLink).Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, "delete link_tags")
+	}
+
+	for _, name := range names {
+		tag := &foundation.Tag{Name: name}
+		// a conflicting insert keeps the existing spelling of the name
+		_, err = idb.NewInsert().Model(tag).On("CONFLICT (name) DO NOTHING").Exec(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "insert tag %q", name)
+		}
+		err = idb.NewSelect().Model(tag).Column("id").Where("name = ?", name).Scan(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "select tag %q", name)
+		}
+		_, err = idb.NewInsert().Model(&foundation.LinkTag{ShortLink: shortLink, TagID: tag.ID}).
+			On("CONFLICT DO NOTHING").Exec(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "insert link tag %q", name)
+		}
+	}
+	return deleteUnusedTags(ctx, idb)
+}
+
+func deleteUnusedTags(ctx context.Context, idb bun.IDB) error {
+	_, err := idb.NewDelete().Model((*foundation.Tag)(nil)).
+		Where("id NOT IN (SELECT tag_id FROM link_tags)").Exec(ctx)
+	return errors.Wrap(err, "delete unused tags")
+}
+
+// loadTags sets the Tags of links with one query.
+func loadTags(ctx context.Context, idb bun.IDB, links []*foundation.Link) error {
+	if len(links) == 0 {
+		return nil
+	}
+	byShortLink := map[string]*foundation.Link{}
+	shortLinks := make([]string, 0, len(links))
+	for _, link := range links {
+		link.Tags = nil
+		byShortLink[link.ShortLink] = link
+		shortLinks = append(shortLinks, link.ShortLink)
+	}
+
+	var rows []struct {
+		ShortLink string `bun:"short_link"`
+		ID        int64  `bun:"id"`
+		Name      string `bun:"name"`
+	}
+	err := idb.NewSelect().TableExpr("link_tags AS lt").
+		Join("JOIN tags AS t ON t.id = lt.tag_id").
+		ColumnExpr("lt.short_link, t.id, t.name").
+		Where("lt.short_link IN (?)", bun.In(shortLinks)).
+		OrderExpr("t.name COLLATE NOCASE ASC").
+		Scan(ctx, &rows)
+	if err != nil {
+		return errors.Wrap(err, "select link tags")
+	}
+	for _, row := range rows {
+		link := byShortLink[row.ShortLink]
+		link.Tags = append(link.Tags, &foundation.Tag{ID: row.ID, Name: row.Name})
+	}
+	return nil
+}
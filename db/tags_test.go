@@ -0,0 +1,53 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tags := ParseTags(" Spring  Sale, team-a,, spring sale ,")
+	if strings.Join(tags, "|") != "Spring Sale|team-a" {
+		t.Errorf("unexpected tags %q", tags)
+	}
+	if err := CheckTags([]string{strings.Repeat("x", MaxTagLength+1)}); err == nil {
+		t.Error("expected an error for a long tag")
+	}
+}
+
+func TestLinkTags(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	err := database.Tags.Set(ctx, "docs", []string{"team-a", "Launch"})
+	if err != nil {
+		t.Fatalf("Tags.Set failed: %v", err)
+	}
+	err = database.Tags.Set(ctx, "blog", []string{"launch"})
+	if err != nil {
+		t.Fatalf("Tags.Set failed: %v", err)
+	}
+
+	page, err := database.Links.Page(ctx, LinkQuery{Tag: "LAUNCH"})
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	if shortLinks(page.Links) != "blog,docs" {
+		t.Errorf("expected blog,docs with tag launch, got %s", shortLinks(page.Links))
+	}
+	if names := strings.Join(TagNames(page.Links[1].Tags), ","); names != "Launch,team-a" {
+		t.Errorf("expected the tags of docs to be loaded, got %s", names)
+	}
+
+	err = database.Links.Delete(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	tags, err := database.Tags.All(ctx)
+	if err != nil {
+		t.Fatalf("Tags.All failed: %v", err)
+	}
+	if len(tags) != 1 || tags[0].Name != "Launch" || tags[0].LinksCount != 1 {
+		t.Errorf("expected only the tag Launch with one link to remain, got %+v", tags)
+	}
+}
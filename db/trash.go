@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+)
+
+var (
+	// TrashRetention is the default for the config value with the same
+	// name.
+	TrashRetention = 30 * 24 * time.Hour
+	// TrashPurgeInterval is the time between runs of the job that purges
+	// expired links and users from the trash.
+	TrashPurgeInterval = time.Hour
+)
+
+type trashDB struct {
+	db     *bun.DB
+	config func() *foundation.Config
+}
+
+func (t *trashDB) retention() time.Duration {
+	if retention := t.config().TrashRetention; retention > 0 {
+		return retention.Duration()
+	}
+	return TrashRetention
+}
+
+func (t *trashDB) startPurge(done <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(TrashPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			links, users, err := t.PurgeExpired(context.Background())
+			if err != nil {
+				log.Println("trash purge error:", err)
+			} else if links+users > 0 {
+				log.Printf("purged %d links and %d users from the trash", links, users)
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PurgeExpired permanently deletes the links and users that are in the
+// trash for longer than the retention. Each purge is recorded in the audit
+// log without an actor.
+func (t *trashDB) PurgeExpired(ctx context.Context) (links, users int, err error) {
+	cutoff := time.Now().UTC().Add(-t.retention())
+
+	var shortLinks []string
+	err = t.db.NewSelect().Model(nilLink).WhereDeleted().Column("short_link").
+		Where("deleted_at < ?", cutoff).Scan(ctx, &shortLinks)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "select links")
+	}
+	for _, shortLink := range shortLinks {
+		err = runInTx(ctx, t.db, func(ctx context.Context) error {
+			tx := conn(ctx, t.db)
+			err := purgeLink(ctx, tx, shortLink)
+			if err != nil {
+				return err
+			}
+			return t.audit(ctx, tx, "link.purge", "link:"+shortLink)
+		})
+		if err != nil {
+			return links, 0, errors.Wrapf(err, "purge link %q", shortLink)
+		}
+		links++
+	}
+
+	var userIDs []int64
+	err = t.db.NewSelect().Model(nilUser).WhereDeleted().Column("id").
+		Where("deleted_at < ?", cutoff).Scan(ctx, &userIDs)
+	if err != nil {
+		return links, 0, errors.Wrap(err, "select users")
+	}
+	for _, id := range userIDs {
+		err = runInTx(ctx, t.db, func(ctx context.Context) error {
+			tx := conn(ctx, t.db)
+			_, err := tx.NewDelete().Model(nilUser).WhereDeleted().ForceDelete().Where("id = ?", id).Exec(ctx)
+			if err != nil {
+				return err
+			}
+			return t.audit(ctx, tx, "user.purge", fmt.Sprintf("user:%d", id))
+		})
+		if err != nil {
+			return links, users, errors.Wrapf(err, "purge user %d", id)
+		}
+		users++
+	}
+	return links, users, nil
+}
+
+func (t *trashDB) audit(ctx context.Context, tx bun.IDB, action, target string) error {
+	event := &foundation.AuditEvent{
+		CreatedAt: time.Now(),
+		Action:    action,
+		Target:    target,
+	}
+	_, err := tx.NewInsert().Model(event).Exec(ctx)
+	return errors.Wrap(err, "insert audit event")
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mbertschler/foundation"
+)
+
+func TestLinkTrash(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+
+	err := database.Links.Delete(ctx, "shop")
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	page, err := database.Links.Page(ctx, LinkQuery{})
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	if page.Total != 4 || shortLinks(page.Links) != "api,blog,docs,status" {
+		t.Errorf("expected deleted links to be hidden, got %s", shortLinks(page.Links))
+	}
+	inTrash, err := database.Links.InTrash(ctx, "shop")
+	if err != nil || !inTrash {
+		t.Errorf("expected shop to be in the trash: %v", err)
+	}
+
+	err = database.Links.Restore(ctx, "shop")
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	link, err := database.Links.ByShortLink(ctx, "shop")
+	if err != nil {
+		t.Fatalf("ByShortLink failed: %v", err)
+	}
+	if link.VisitsCount != 4 {
+		t.Errorf("expected the restored link to keep its visits, got %d", link.VisitsCount)
+	}
+
+	err = database.Links.Delete(ctx, "shop")
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	// move the deletion back in time past the retention
+	_, err = database.bun.NewUpdate().Model(nilLink).WhereDeleted().
+		Set("deleted_at = ?", time.Now().UTC().Add(-TrashRetention-time.Hour)).
+		Where("short_link = ?", "shop").Exec(ctx)
+	if err != nil {
+		t.Fatalf("update deleted_at failed: %v", err)
+	}
+	links, users, err := database.Trash.PurgeExpired(ctx)
+	if err != nil {
+		t.Fatalf("PurgeExpired failed: %v", err)
+	}
+	if links != 1 || users != 0 {
+		t.Errorf("expected one purged link, got %d links and %d users", links, users)
+	}
+	inTrash, err = database.Links.InTrash(ctx, "shop")
+	if err != nil || inTrash {
+		t.Errorf("expected shop to be purged: %v", err)
+	}
+	visits, err := database.Visits.CountByLink(ctx, "shop")
+	if err != nil {
+		t.Fatalf("CountByLink failed: %v", err)
+	}
+	if visits != 0 {
+		t.Errorf("expected the visits of shop to be purged, got %d", visits)
+	}
+}
+
+func TestUserTrash(t *testing.T) {
+	ctx, database := startTestDB(t)
+	insertTestLinks(t, ctx, database)
+	owner, err := database.Users.ByUsername(ctx, "links")
+	if err != nil {
+		t.Fatalf("ByUsername failed: %v", err)
+	}
+	admin := &foundation.User{DisplayName: "Admin", UserName: "admin", HashedPassword: "x"}
+	err = database.Users.Insert(ctx, admin)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	_, err = database.Sessions.InsertUserSession(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("InsertUserSession failed: %v", err)
+	}
+	err = database.Links.Delete(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	err = database.Users.Delete(ctx, owner.ID, admin.ID)
+	if err != nil {
+		t.Fatalf("Users.Delete failed: %v", err)
+	}
+	_, err = database.Users.ByUsername(ctx, "links")
+	if err == nil {
+		t.Error("expected the deleted user to be hidden")
+	}
+	exists, err := database.Users.ExistsByUsername(ctx, "links")
+	if err != nil || !exists {
+		t.Errorf("expected the username of a deleted user to stay taken: %v", err)
+	}
+	sessions, err := database.bun.NewSelect().Model(nilSession).Where("user_id = ?", owner.ID).Count(ctx)
+	if err != nil || sessions != 0 {
+		t.Errorf("expected the sessions of the user to be deleted, got %d: %v", sessions, err)
+	}
+
+	err = database.Links.Restore(ctx, "docs")
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	links, err := database.Links.All(ctx, "")
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	for _, link := range links {
+		if link.UserID != admin.ID {
+			t.Errorf("expected %s to be transferred to admin, got user %d", link.ShortLink, link.UserID)
+		}
+	}
+
+	trashed, err := database.Users.Trashed(ctx)
+	if err != nil || len(trashed) != 1 || trashed[0].ID != owner.ID {
+		t.Fatalf("expected the user in the trash, got %v: %v", trashed, err)
+	}
+	err = database.Users.Purge(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	exists, err = database.Users.ExistsByUsername(ctx, "links")
+	if err != nil || exists {
+		t.Errorf("expected the purged user to be gone: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+type txKey struct{}
+
+// InTx runs fn in a transaction. Methods of DB that are called with the
+// ctx passed to fn use the transaction, so that several changes are
+// committed together. If ctx already belongs to a transaction, fn joins it.
+func (db *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return runInTx(ctx, db.bun, fn)
+}
+
+func runInTx(ctx context.Context, db *bun.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(bun.Tx); ok {
+		return fn(ctx)
+	}
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction that ctx belongs to, or db. Queries inside
+// of a transaction must use it, because SQLite only allows one writer.
+func conn(ctx context.Context, db *bun.DB) bun.IDB {
+	if tx, ok := ctx.Value(txKey{}).(bun.Tx); ok {
+		return tx
+	}
+	return db
+}
@@ -2,10 +2,9 @@ package db
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 
 	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
 )
 
@@ -19,7 +18,7 @@ type usersDB struct {
 
 func (u *usersDB) ByID(ctx context.Context, id int64) (*foundation.User, error) {
 	var user foundation.User
-	err := u.db.NewSelect().Model(&user).Where("id = ?", id).Scan(ctx)
+	err := conn(ctx, u.db).NewSelect().Model(&user).Where("id = ?", id).Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -28,45 +27,89 @@ func (u *usersDB) ByID(ctx context.Context, id int64) (*foundation.User, error)
 
 func (u *usersDB) ByUsername(ctx context.Context, username string) (*foundation.User, error) {
 	var user foundation.User
-	err := u.db.NewSelect().Model(&user).Where("user_name = ?", username).Scan(ctx)
+	err := conn(ctx, u.db).NewSelect().Model(&user).Where("user_name = ?", username).Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// ExistsByUsername also reports users in the trash, because they keep
+// their username until they are purged.
 func (u *usersDB) ExistsByUsername(ctx context.Context, username string) (bool, error) {
-	user, err := u.ByUsername(ctx, username)
-	if user != nil && err == nil {
-		return true, nil
-	}
-	if errors.Is(err, sql.ErrNoRows) {
-		return false, nil
-	}
-	if user == nil {
-		return false, errors.New("user is nil")
-	}
-	return false, err
+	return conn(ctx, u.db).NewSelect().Model(nilUser).WhereAllWithDeleted().
+		Where("user_name = ?", username).Exists(ctx)
 }
 
 func (u *usersDB) Insert(ctx context.Context, user *foundation.User) error {
-	_, err := u.db.NewInsert().Model(user).Exec(ctx)
+	_, err := conn(ctx, u.db).NewInsert().Model(user).Exec(ctx)
 	return err
 }
 
 func (u *usersDB) Update(ctx context.Context, user *foundation.User) error {
-	_, err := u.db.NewUpdate().Model(user).WherePK().Exec(ctx)
+	_, err := conn(ctx, u.db).NewUpdate().Model(user).WherePK().Exec(ctx)
 	return err
 }
 
-func (u *usersDB) Delete(ctx context.Context, userID int64) error {
-	_, err := u.db.NewDelete().Model(nilUser).Where("id = ?", userID).Exec(ctx)
-	return err
+// Delete moves a user to the trash and logs them out. Their links, also
+// the ones in the trash, are transferred to the user newOwnerID.
+func (u *usersDB) Delete(ctx context.Context, userID, newOwnerID int64) error {
+	if userID == newOwnerID {
+		return errors.New("can't transfer the links of a user to themselves")
+	}
+	return runInTx(ctx, u.db, func(ctx context.Context) error {
+		tx := conn(ctx, u.db)
+		_, err := tx.NewUpdate().Model(nilLink).WhereAllWithDeleted().
+			Set("user_id = ?", newOwnerID).Where("user_id = ?", userID).Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "transfer links")
+		}
+		_, err = tx.NewDelete().Model(nilSession).Where("user_id = ?", userID).Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "delete sessions")
+		}
+		_, err = tx.NewDelete().Model(nilUser).Where("id = ?", userID).Exec(ctx)
+		return errors.Wrap(err, "delete user")
+	})
+}
+
+// Trashed returns the users in the trash, most recently deleted first.
+func (u *usersDB) Trashed(ctx context.Context) ([]*foundation.User, error) {
+	var users []*foundation.User
+	err := conn(ctx, u.db).NewSelect().Model(&users).WhereDeleted().
+		OrderExpr("u.deleted_at DESC, u.id ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Restore takes a user out of the trash. Their links stay with the user
+// they were transferred to. It returns sql.ErrNoRows if the user is not
+// in the trash.
+func (u *usersDB) Restore(ctx context.Context, userID int64) error {
+	res, err := conn(ctx, u.db).NewUpdate().Model(nilUser).WhereDeleted().
+		Set("deleted_at = NULL").Where("id = ?", userID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return expectRows(res)
+}
+
+// Purge permanently deletes a user from the trash. It returns
+// sql.ErrNoRows if the user is not in the trash.
+func (u *usersDB) Purge(ctx context.Context, userID int64) error {
+	res, err := conn(ctx, u.db).NewDelete().Model(nilUser).WhereDeleted().ForceDelete().
+		Where("id = ?", userID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return expectRows(res)
 }
 
 func (u *usersDB) All(ctx context.Context) ([]*foundation.User, error) {
 	var users []*foundation.User
-	err := u.db.NewSelect().Model(&users).Scan(ctx)
+	err := conn(ctx, u.db).NewSelect().Model(&users).Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -2,22 +2,173 @@ package db
 
 import (
 	"context"
+	"log"
+	"time"
 
 	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
 	"github.com/uptrace/bun"
 )
 
 
+var (
+	nilVisit *foundation.LinkVisit
+)
+
+// VisitCleanupInterval is the time between runs of the job that deletes
+// old and purged raw visits.
+var VisitCleanupInterval = time.Hour
+
+// visitDeleteBatch limits the number of visits deleted per statement, so
+// that the cleanup doesn't block recording new visits for long.
+const visitDeleteBatch = 1000
+
 type visitsDB struct {
-	db *bun.DB
+	db     *bun.DB
+	config func() *foundation.Config
+	// cleanup starts the cleanup job before the next interval
+	cleanup chan struct{}
+}
+
+func newVisitsDB(db *bun.DB, config func() *foundation.Config) *visitsDB {
+	return &visitsDB{
+		db:      db,
+		config:  config,
+		cleanup: make(chan struct{}, 1),
+	}
 }
 
 func (v *visitsDB) Insert(ctx context.Context, visit *foundation.LinkVisit) error {
-	_, err := v.db.NewInsert().Model(visit).Exec(ctx)
+	_, err := conn(ctx, v.db).NewInsert().Model(visit).Exec(ctx)
 	return err
 }
 
+// Daily returns the daily visit counts of a link from since on, oldest
+// first. Unlike the raw visits they are kept when old visits are pruned.
+func (v *visitsDB) Daily(ctx context.Context, shortLink string, since time.Time) ([]*foundation.LinkDailyVisits, error) {
+	var days []*foundation.LinkDailyVisits
+	err := conn(ctx, v.db).NewSelect().Model(&days).
+		Where("short_link = ?", shortLink).
+		Where("day >= ?", since.UTC().Format(time.DateOnly)).
+		Order("day ASC").Scan(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+	return days, nil
+}
+
 func (v *visitsDB) CountByLink(ctx context.Context, shortLink string) (int64, error) {
-	count, err := v.db.NewSelect().Table("link_visits").Where("short_link = ?", shortLink).Count(ctx)
+	count, err := conn(ctx, v.db).NewSelect().Table("link_visits").Where("short_link = ?", shortLink).Count(ctx)
 	return int64(count), err
 }
+
+// VariantCount is the number of recorded visits of a link that were sent
+// to the destination of one targeting rule.
+type VariantCount struct {
+	// Variant is empty for visits that were sent to the FullURL of the link.
+	Variant string `bun:"variant"`
+	Visits  int64  `bun:"visits"`
+}
+
+// Variants counts the raw visits of a link that weren't purged by variant.
+func (v *visitsDB) Variants(ctx context.Context, shortLink string) ([]*VariantCount, error) {
+	var counts []*VariantCount
+	err := conn(ctx, v.db).NewSelect().Model(nilVisit).
+		ColumnExpr("variant, COUNT(*) AS visits").
+		Where("short_link = ?", shortLink).
+		Where("visited_at > COALESCE((SELECT visits_purged_at FROM links WHERE links.short_link = lv.short_link), 0)").
+		Group("variant").OrderExpr("variant ASC").Scan(ctx, &counts)
+	if err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+	return counts, nil
+}
+
+// Purge deletes all visit data of a link. The counters and daily rollups
+// are reset right away, the raw visits are deleted by the cleanup job.
+func (v *visitsDB) Purge(ctx context.Context, shortLink string) error {
+	err := runInTx(ctx, v.db, func(ctx context.Context) error {
+		tx := conn(ctx, v.db)
+		_, err := tx.NewUpdate().Table("links").
+			Set("visits_count = 0").
+			Set("last_visited_at = NULL").
+			Set("visits_purged_at = ?", time.Now().UTC()).
+			Where("short_link = ?", shortLink).Exec(ctx)
+		if err != nil {
+			return errors.Wrap(err, "reset links")
+		}
+		_, err = tx.NewDelete().Model((*foundation.LinkDailyVisits)(nil)).
+			Where("short_link = ?", shortLink).Exec(ctx)
+		return errors.Wrap(err, "delete link_visits_daily")
+	})
+	if err != nil {
+		return err
+	}
+
+	v.startCleanupNow()
+	return nil
+}
+
+func (v *visitsDB) startCleanupNow() {
+	select {
+	case v.cleanup <- struct{}{}:
+	default:
+		// a cleanup is already pending
+	}
+}
+
+func (v *visitsDB) startCleanup(done <-chan struct{}) {
+	v.startCleanupNow()
+
+	go func() {
+		ticker := time.NewTicker(VisitCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			case <-v.cleanup:
+			}
+			deleted, err := v.deleteOld(context.Background())
+			if err != nil {
+				log.Println("visit cleanup error:", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("deleted %d old link visits", deleted)
+			}
+		}
+	}()
+}
+
+// deleteOld deletes raw visits that are older than VisitRetention or than
+// the last purge of their link.
+func (v *visitsDB) deleteOld(ctx context.Context) (int64, error) {
+	where := "visited_at <= (SELECT visits_purged_at FROM links WHERE links.short_link = link_visits.short_link)"
+	var args []any
+	if retention := v.config().VisitRetention.Duration(); retention > 0 {
+		where += " OR visited_at < ?"
+		args = append(args, time.Now().UTC().Add(-retention))
+	}
+	args = append(args, visitDeleteBatch)
+
+	var total int64
+	for {
+		res, err := conn(ctx, v.db).NewDelete().Model(nilVisit).
+			Where("id IN (SELECT id FROM link_visits WHERE "+where+" LIMIT ?)", args...).
+			Exec(ctx)
+		if err != nil {
+			return total, errors.Wrap(err, "delete link_visits")
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return total, errors.Wrap(err, "RowsAffected")
+		}
+		total += deleted
+		if deleted < visitDeleteBatch {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package foundation
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Links on the default domain are stored by their name, like "docs". Links
+// on one of the configured Domains are stored as "name@host", like
+// "docs@go.example.com", so that every domain has its own namespace while
+// the short link stays the key of a link everywhere else.
+const domainSeparator = "@"
+
+var hostPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// LinkKey returns the short link that the link name on domain is stored
+// as. An empty domain is the default domain.
+func LinkKey(domain, name string) string {
+	if domain == "" {
+		return name
+	}
+	return name + domainSeparator + domain
+}
+
+// SplitLinkKey returns the name and domain of a short link.
+func SplitLinkKey(key string) (name, domain string) {
+	name, domain, _ = strings.Cut(key, domainSeparator)
+	return name, domain
+}
+
+// Name is the short link without its domain, as it is visited.
+func (l *Link) Name() string {
+	name, _ := SplitLinkKey(l.ShortLink)
+	return name
+}
+
+// Domain is the host that the link is served on, or empty for the default
+// domain.
+func (l *Link) Domain() string {
+	_, domain := SplitLinkKey(l.ShortLink)
+	return domain
+}
+
+// ShortLinkHref returns the URL of a short link for links in the admin UI.
+// Links on other domains use the scheme of the admin UI.
+func ShortLinkHref(key string) string {
+	name, domain := SplitLinkKey(key)
+	if domain == "" {
+		return "/" + name
+	}
+	return "//" + domain + "/" + name
+}
+
+// NormalizeHost returns the lowercase host of a Host header without port.
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// RequestDomain returns the configured domain of a request to host, or an
+// empty string if the request is for the default domain.
+func (c *Config) RequestDomain(host string) string {
+	host = NormalizeHost(host)
+	if _, ok := c.Domains[host]; ok {
+		return host
+	}
+	return ""
+}
+
+// validateDomains checks that the Domains are lowercase hosts without port
+// and that their fallback URLs are absolute.
+func validateDomains(domains map[string]string) error {
+	for host, fallback := range domains {
+		if !hostPattern.MatchString(host) {
+			return errors.Errorf("%q is not a lowercase host name without port", host)
+		}
+		if fallback == "" {
+			continue
+		}
+		u, err := url.Parse(fallback)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.Errorf("fallback URL %q of %s must start with http:// or https://", fallback, host)
+		}
+	}
+	return nil
+}
+
+// validateTrustedDomains checks that the TrustedDomains are lowercase
+// hosts without port.
+func validateTrustedDomains(domains []string) error {
+	for _, host := range domains {
+		if !hostPattern.MatchString(host) {
+			return errors.Errorf("%q is not a lowercase host name without port", host)
+		}
+	}
+	return nil
+}
+
+// ExternalURL reports whether destination leaves the trusted domains, if
+// TrustedDomains are configured. Subdomains of the TrustedDomains, the
+// Domains and host, the host of the request, are trusted as well.
+func (c *Config) ExternalURL(destination, host string) bool {
+	if len(c.TrustedDomains) == 0 {
+		return false
+	}
+	u, err := url.Parse(destination)
+	if err != nil {
+		return true
+	}
+	destHost := NormalizeHost(u.Host)
+	if destHost == NormalizeHost(host) {
+		return false
+	}
+	if _, ok := c.Domains[destHost]; ok {
+		return false
+	}
+	for _, trusted := range c.TrustedDomains {
+		if destHost == trusted || strings.HasSuffix(destHost, "."+trusted) {
+			return false
+		}
+	}
+	return true
+}
+
+// DomainNames returns the configured domains in sorted order.
+func (c *Config) DomainNames() []string {
+	names := make([]string, 0, len(c.Domains))
+	for name := range c.Domains {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
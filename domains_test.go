@@ -0,0 +1,73 @@
+package foundation
+
+import "testing"
+
+func TestLinkKey(t *testing.T) {
+	key := LinkKey("go.example.com", "docs")
+	if key != "docs@go.example.com" {
+		t.Errorf("unexpected key %q", key)
+	}
+	name, domain := SplitLinkKey(key)
+	if name != "docs" || domain != "go.example.com" {
+		t.Errorf("unexpected split %q %q", name, domain)
+	}
+	if LinkKey("", "docs") != "docs" || ShortLinkHref("docs") != "/docs" {
+		t.Error("expected links on the default domain to be stored by name")
+	}
+	if ShortLinkHref(key) != "//go.example.com/docs" {
+		t.Errorf("unexpected href %q", ShortLinkHref(key))
+	}
+}
+
+func TestRequestDomain(t *testing.T) {
+	config := &Config{Domains: map[string]string{"go.example.com": ""}}
+	for host, expected := range map[string]string{
+		"go.example.com":      "go.example.com",
+		"GO.example.com:8080": "go.example.com",
+		"go.example.com.":     "go.example.com",
+		"example.com":         "",
+		"localhost:3000":      "",
+	} {
+		if domain := config.RequestDomain(host); domain != expected {
+			t.Errorf("expected domain %q for %s, got %q", expected, host, domain)
+		}
+	}
+}
+
+func TestValidateDomains(t *testing.T) {
+	valid := map[string]string{"go.example.com": "", "links.example.com": "https://example.com/"}
+	if err := validateDomains(valid); err != nil {
+		t.Errorf("expected domains to be valid: %v", err)
+	}
+	for _, invalid := range []map[string]string{
+		{"Go.Example.com": ""},
+		{"go.example.com:80": ""},
+		{"go.example.com": "example.com"},
+	} {
+		if validateDomains(invalid) == nil {
+			t.Errorf("expected %v to be invalid", invalid)
+		}
+	}
+}
+
+func TestExternalURL(t *testing.T) {
+	config := &Config{Domains: map[string]string{"go.example.com": ""}}
+	if config.ExternalURL("https://evil.example.net/", "localhost:3000") {
+		t.Error("expected no warnings without TrustedDomains")
+	}
+	config.TrustedDomains = []string{"example.com"}
+	for destination, expected := range map[string]bool{
+		"https://example.com/docs":        false,
+		"https://docs.EXAMPLE.com:8443/":  false,
+		"https://go.example.com/x":        false,
+		"http://localhost:3000/admin":     false,
+		"https://notexample.com/":         true,
+		"https://example.com.evil.net/":   true,
+		"mailto:someone@example.net":      true,
+		"https://evil.example.net/?a=b.c": true,
+	} {
+		if external := config.ExternalURL(destination, "localhost:3000"); external != expected {
+			t.Errorf("expected external=%v for %s", expected, destination)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package foundation
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPError is an error with an HTTP status code and a message that is safe
+// to show to users. The wrapped cause is only logged.
+type HTTPError struct {
+	Status  int
+	Message string
+	Cause   error
+}
+
+// NewHTTPError returns an HTTPError. If message is empty, the standard
+// status text is shown to users.
+func NewHTTPError(status int, message string, cause error) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Message: message, Cause: cause}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("%d %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("%d %s: %v", e.Status, e.Message, e.Cause)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// AsHTTPError returns the HTTPError in the chain of err. Other errors are
+// internal server errors with a generic message.
+func AsHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return NewHTTPError(http.StatusInternalServerError, "", err)
+}
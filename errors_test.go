@@ -0,0 +1,29 @@
+package foundation
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAsHTTPError(t *testing.T) {
+	cause := errors.New("sql: no rows in result set")
+	err := errors.Wrap(NewHTTPError(http.StatusNotFound, "Link not found", cause), "patchLink")
+
+	httpErr := AsHTTPError(err)
+	if httpErr.Status != http.StatusNotFound || httpErr.Message != "Link not found" {
+		t.Errorf("unexpected HTTPError %d %q", httpErr.Status, httpErr.Message)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected the cause to be in the error chain")
+	}
+
+	internal := AsHTTPError(errors.New("Users.ByID: database is locked"))
+	if internal.Status != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", internal.Status)
+	}
+	if internal.Message != http.StatusText(http.StatusInternalServerError) {
+		t.Errorf("internal details should not be public, got %q", internal.Message)
+	}
+}
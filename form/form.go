@@ -0,0 +1,170 @@
+// Package form decodes submitted HTML forms into structs and validates them.
+//
+// Struct fields are matched by their form tag and validated with the rules
+// in their validate tag, separated by commas:
+//
+//	required  the value must not be empty or only whitespace
+//	max=N     the value must be at most N characters long
+//	url       the value must be an absolute http or https URL
+//
+// A pattern tag holds a regular expression that non-empty values must
+// match, and an optional message tag replaces the generic message shown
+// when the pattern doesn't match.
+package form
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Errors maps form field names to messages that can be shown next to the
+// field. It is empty if the form is valid.
+type Errors map[string]string
+
+// Add sets the message for a field, unless it already has one.
+func (e Errors) Add(field, message string) {
+	if _, ok := e[field]; !ok {
+		e[field] = message
+	}
+}
+
+// Get returns the message for a field, or an empty string.
+func (e Errors) Get(field string) string {
+	return e[field]
+}
+
+// Decode parses the form of r into the struct that dst points to and
+// validates it. Invalid values are reported in Errors, the returned error
+// is only set if the request can't be parsed at all.
+func Decode(r *http.Request, dst any) (Errors, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseForm")
+	}
+
+	errs := Errors{}
+	v, t := structOf(dst)
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("form")
+		if name == "" {
+			continue
+		}
+		value := r.Form.Get(name)
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(value)
+		case reflect.Bool:
+			// unchecked checkboxes are not submitted
+			f.SetBool(value != "" && value != "false" && value != "off")
+		case reflect.Int, reflect.Int64:
+			if value == "" {
+				f.SetInt(0)
+				continue
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				errs.Add(name, "Must be a whole number.")
+				continue
+			}
+			f.SetInt(n)
+		default:
+			panic(fmt.Sprintf("form: unsupported type %s of field %s", f.Type(), t.Field(i).Name))
+		}
+	}
+
+	for field, message := range Validate(dst) {
+		errs.Add(field, message)
+	}
+	return errs, nil
+}
+
+// Validate checks the string fields of the struct that src points to
+// against their validate and pattern tags.
+func Validate(src any) Errors {
+	errs := Errors{}
+	v, t := structOf(src)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get("form")
+		if name == "" || sf.Type.Kind() != reflect.String {
+			continue
+		}
+		message := validateValue(v.Field(i).String(), sf.Tag)
+		if message != "" {
+			errs.Add(name, message)
+		}
+	}
+	return errs
+}
+
+func validateValue(value string, tag reflect.StructTag) string {
+	rules := strings.Split(tag.Get("validate"), ",")
+	empty := strings.TrimSpace(value) == ""
+	for _, rule := range rules {
+		if rule == "required" && empty {
+			return "Please fill in this field."
+		}
+	}
+	if empty {
+		return ""
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "", "required":
+		case "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("form: invalid rule %q", rule))
+			}
+			if utf8.RuneCountInString(value) > n {
+				return fmt.Sprintf("Must be at most %d characters long.", n)
+			}
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return "Must be a valid URL starting with http:// or https://."
+			}
+		default:
+			panic(fmt.Sprintf("form: unknown rule %q", rule))
+		}
+	}
+
+	if pattern := tag.Get("pattern"); pattern != "" && !compile(pattern).MatchString(value) {
+		if message := tag.Get("message"); message != "" {
+			return message
+		}
+		return "Has an invalid format."
+	}
+	return ""
+}
+
+var patterns sync.Map // string -> *regexp.Regexp
+
+func compile(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patterns.Store(pattern, re)
+	return re
+}
+
+func structOf(ptr any) (reflect.Value, reflect.Type) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("form: expected a pointer to a struct, got %T", ptr))
+	}
+	return v.Elem(), v.Elem().Type()
+}
@@ -0,0 +1,76 @@
+package form
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testForm struct {
+	Name    string `form:"name" validate:"required,max=5" pattern:"^[a-z]+$" message:"Only lowercase letters."`
+	Link    string `form:"link" validate:"url"`
+	Count   int    `form:"count"`
+	Enabled bool   `form:"enabled"`
+	Ignored string
+}
+
+func decode(t *testing.T, values url.Values) (*testForm, Errors) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var f testForm
+	errs, err := Decode(req, &f)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	return &f, errs
+}
+
+func TestDecodeValid(t *testing.T) {
+	f, errs := decode(t, url.Values{
+		"name":    {"abc"},
+		"link":    {"https://example.com/x"},
+		"count":   {"42"},
+		"enabled": {"on"},
+		"Ignored": {"x"},
+	})
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if f.Name != "abc" || f.Link != "https://example.com/x" || f.Count != 42 || !f.Enabled {
+		t.Errorf("unexpected values %+v", f)
+	}
+	if f.Ignored != "" {
+		t.Error("fields without form tag should not be decoded")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		values url.Values
+		field  string
+		errMsg string
+	}{
+		{"required", url.Values{"name": {"  "}}, "name", "Please fill in this field."},
+		{"max length", url.Values{"name": {"abcdef"}}, "name", "Must be at most 5 characters long."},
+		{"pattern", url.Values{"name": {"AB"}}, "name", "Only lowercase letters."},
+		{"url scheme", url.Values{"name": {"a"}, "link": {"ftp://example.com"}}, "link", "Must be a valid URL starting with http:// or https://."},
+		{"url host", url.Values{"name": {"a"}, "link": {"https://"}}, "link", "Must be a valid URL starting with http:// or https://."},
+		{"number", url.Values{"name": {"a"}, "count": {"many"}}, "count", "Must be a whole number."},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, errs := decode(t, tc.values)
+			if got := errs.Get(tc.field); got != tc.errMsg {
+				t.Errorf("expected error %q for %s, got %q", tc.errMsg, tc.field, got)
+			}
+			if len(errs) != 1 {
+				t.Errorf("expected exactly one error, got %v", errs)
+			}
+			if tc.field == "name" && f.Name != tc.values.Get("name") {
+				t.Errorf("submitted value should be kept, got %q", f.Name)
+			}
+		})
+	}
+}
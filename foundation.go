@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -12,7 +13,24 @@ import (
 
 type Context struct {
 	context.Context
+	// Config is the config the app was started with.
 	Config *Config
+
+	current atomic.Pointer[Config]
+}
+
+// Current returns the latest config snapshot, which differs from Config in
+// the reloadable settings after a config reload. It must not be modified.
+func (c *Context) Current() *Config {
+	if config := c.current.Load(); config != nil {
+		return config
+	}
+	return c.Config
+}
+
+// SetCurrent atomically replaces the snapshot returned by Current.
+func (c *Context) SetCurrent(config *Config) {
+	c.current.Store(config)
 }
 
 type Request struct {
@@ -51,6 +69,9 @@ type User struct {
 	HashedPassword string    `bun:"hashed_password,notnull"`
 	CreatedAt      time.Time `bun:"created_at,nullzero,notnull"`
 	UpdatedAt      time.Time `bun:"updated_at,nullzero,notnull"`
+	// DeletedAt is set while the user is in the trash. Queries skip
+	// deleted users unless they ask for them.
+	DeletedAt time.Time `bun:"deleted_at,soft_delete,nullzero"`
 }
 
 type Session struct {
@@ -66,14 +87,33 @@ type Session struct {
 type Link struct {
 	bun.BaseModel `bun:"table:links,alias:l"`
 
-	ShortLink   string       `bun:"short_link,pk"`
-	FullURL     string       `bun:"full_url,notnull"`
-	UserID      int64        `bun:"user_id,notnull"`
-	CreatedAt   time.Time    `bun:"created_at,nullzero,notnull"`
-	UpdatedAt   time.Time    `bun:"updated_at,nullzero,notnull"`
-	User        *User        `bun:"rel:has-one,join:user_id=id"`
-	Visits      []*LinkVisit `bun:"rel:has-many,join:short_link=short_link"`
-	VisitsCount int64        `bun:"visits_count,scanonly"`
+	ShortLink string       `bun:"short_link,pk"`
+	FullURL   string       `bun:"full_url,notnull"`
+	UserID    int64        `bun:"user_id,notnull"`
+	CreatedAt time.Time    `bun:"created_at,nullzero,notnull"`
+	UpdatedAt time.Time    `bun:"updated_at,nullzero,notnull"`
+	User      *User        `bun:"rel:has-one,join:user_id=id"`
+	Visits    []*LinkVisit `bun:"rel:has-many,join:short_link=short_link"`
+
+	// VisitsCount and LastVisitedAt are maintained by a trigger on
+	// link_visits and keep their values when old visits are pruned.
+	VisitsCount   int64        `bun:"visits_count,notnull"`
+	LastVisitedAt bun.NullTime `bun:"last_visited_at"`
+
+	// NoTrack disables recording visits of this link.
+	NoTrack bool `bun:"no_track,notnull"`
+	RedirectOptions
+	AccessOptions
+	// VisitsPurgedAt is set when all visit data of the link was purged.
+	// Older raw visits are deleted by a background job.
+	VisitsPurgedAt bun.NullTime `bun:"visits_purged_at"`
+
+	// DeletedAt is set while the link is in the trash. Queries skip
+	// deleted links unless they ask for them.
+	DeletedAt time.Time `bun:"deleted_at,soft_delete,nullzero"`
+
+	// Tags are sorted by name and loaded separately from link_tags.
+	Tags []*Tag `bun:"-"`
 }
 
 type LinkVisit struct {
@@ -83,4 +123,103 @@ type LinkVisit struct {
 	ShortLink string        `bun:"short_link,notnull"`
 	UserID    sql.NullInt64 `bun:"user_id"`
 	VisitedAt time.Time     `bun:"visited_at,nullzero,notnull"`
+	// Variant is the LinkRule that chose the destination, or empty for
+	// the FullURL of the link.
+	Variant string `bun:"variant,notnull"`
+}
+
+// Tag groups links, for example by campaign or team. Names are unique
+// regardless of case.
+type Tag struct {
+	bun.BaseModel `bun:"table:tags,alias:t"`
+
+	ID        int64     `bun:"id,pk,autoincrement"`
+	Name      string    `bun:"name,notnull"`
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull"`
+	// LinksCount is only set when listing all tags.
+	LinksCount int64 `bun:"links_count,scanonly"`
+}
+
+type LinkTag struct {
+	bun.BaseModel `bun:"table:link_tags,alias:lt"`
+
+	ShortLink string `bun:"short_link,pk"`
+	TagID     int64  `bun:"tag_id,pk"`
+}
+
+// LinkDailyVisits is the number of visits of a link on one UTC day.
+type LinkDailyVisits struct {
+	bun.BaseModel `bun:"table:link_visits_daily,alias:lvd"`
+
+	ShortLink string `bun:"short_link,pk"`
+	Day       string `bun:"day,pk"`
+	Visits    int64  `bun:"visits,notnull"`
+}
+
+// LinkRule sends the visitors of a link that match it to another URL. See
+// SelectLinkRule for how the rules of a link are evaluated.
+type LinkRule struct {
+	bun.BaseModel `bun:"table:link_rules,alias:lru"`
+
+	ID        int64  `bun:"id,pk,autoincrement"`
+	ShortLink string `bun:"short_link,notnull"`
+	Position  int    `bun:"position,notnull"`
+	// Variant names the rule in the recorded visits.
+	Variant string       `bun:"variant,notnull"`
+	Kind    LinkRuleKind `bun:"kind,notnull"`
+	// Value is the condition of the rule, its format depends on Kind.
+	Value   string `bun:"value,notnull"`
+	FullURL string `bun:"full_url,notnull"`
+}
+
+// LinkRevision is the state of a link after a change. The revisions of a
+// link are its history, and a link can be reverted to an earlier one.
+type LinkRevision struct {
+	bun.BaseModel `bun:"table:link_revisions,alias:lr"`
+
+	ID        int64    `bun:"id,pk,autoincrement"`
+	ShortLink string   `bun:"short_link,notnull"`
+	FullURL   string   `bun:"full_url,notnull"`
+	NoTrack   bool     `bun:"no_track,notnull"`
+	Tags      []string `bun:"tags,notnull"`
+	RedirectOptions
+	// Access is the access mode of the link. The password hash is not
+	// kept, so that reverting can't bring back an old password.
+	Access LinkAccess `bun:"access,notnull"`
+	// Rules are the targeting rules in the text format of the edit dialog.
+	Rules string `bun:"rules,notnull"`
+	// Action is how the revision was made: create, update, rename, revert
+	// or import.
+	Action string `bun:"action,notnull"`
+	// RenamedFrom is the previous short link of a rename. The revisions of
+	// a link move with it when it is renamed.
+	RenamedFrom string `bun:"renamed_from,notnull"`
+	// RevertedFrom is the ID of the revision that a revert restored.
+	RevertedFrom sql.NullInt64 `bun:"reverted_from"`
+	ActorID      sql.NullInt64 `bun:"actor_id"`
+	ActorName    string        `bun:"actor_name,notnull"`
+	CreatedAt    time.Time     `bun:"created_at,nullzero,notnull"`
+}
+
+// AuditEvent records an administrative change. The actor is kept by name,
+// so events stay readable after the user is deleted.
+type AuditEvent struct {
+	bun.BaseModel `bun:"table:audit_events,alias:ae"`
+
+	ID        int64         `bun:"id,pk,autoincrement"`
+	CreatedAt time.Time     `bun:"created_at,nullzero,notnull"`
+	ActorID   sql.NullInt64 `bun:"actor_id"`
+	ActorName string        `bun:"actor_name,notnull"`
+	// Action is the kind of change, for example "link.update".
+	Action string `bun:"action,notnull"`
+	// Target identifies the changed object, for example "link:docs".
+	Target  string                 `bun:"target,notnull"`
+	Changes map[string]AuditChange `bun:"changes"`
+	IP      string                 `bun:"ip,notnull"`
+}
+
+// AuditChange is the value of a field before and after a change.
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
 }
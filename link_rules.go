@@ -0,0 +1,193 @@
+package foundation
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkRuleKind is the condition that a LinkRule matches on.
+type LinkRuleKind string
+
+const (
+	// RuleLanguage matches a comma separated list of languages, like
+	// "de,fr-CH", against the Accept-Language header. A language without
+	// a region matches all of its regions.
+	RuleLanguage LinkRuleKind = "language"
+	// RulePlatform matches a comma separated list of platforms against
+	// the User-Agent header, see Platforms.
+	RulePlatform LinkRuleKind = "platform"
+	// RuleQuery matches "key=value" if the query parameter key has the
+	// value, or "key" if the parameter is set at all.
+	RuleQuery LinkRuleKind = "query"
+	// RuleTime matches the time window "start/end" of two RFC 3339 times.
+	// Either one can be left out for an open window.
+	RuleTime LinkRuleKind = "time"
+	// RuleSplit is chosen randomly for the percentage of visitors given by
+	// its value, for A/B tests.
+	RuleSplit LinkRuleKind = "split"
+)
+
+// LinkRuleKinds are all valid kinds, in the order they are documented.
+var LinkRuleKinds = []LinkRuleKind{RuleLanguage, RulePlatform, RuleQuery, RuleTime, RuleSplit}
+
+// Platforms are the values of platform rules.
+var Platforms = []string{"ios", "android", "windows", "macos", "linux", "mobile", "desktop"}
+
+// Check validates the value of the rule for its kind.
+func (r *LinkRule) Check() error {
+	switch r.Kind {
+	case RuleLanguage:
+		if r.Value == "" {
+			return fmt.Errorf("language rules need a language")
+		}
+	case RulePlatform:
+		for _, platform := range strings.Split(r.Value, ",") {
+			if !containsFold(Platforms, platform) {
+				return fmt.Errorf("unknown platform %q, use one of %s", platform, strings.Join(Platforms, ", "))
+			}
+		}
+	case RuleQuery:
+		key, _, _ := strings.Cut(r.Value, "=")
+		if key == "" {
+			return fmt.Errorf("query rules need a parameter name")
+		}
+	case RuleTime:
+		_, _, err := parseTimeWindow(r.Value)
+		return err
+	case RuleSplit:
+		weight, err := strconv.Atoi(r.Value)
+		if err != nil || weight < 1 || weight > 100 {
+			return fmt.Errorf("split rules need a percentage from 1 to 100")
+		}
+	default:
+		return fmt.Errorf("unknown kind %q", r.Kind)
+	}
+	return nil
+}
+
+// Matches reports whether a request at now matches the rule. Split rules
+// never match on their own, they are chosen by SelectLinkRule.
+func (r *LinkRule) Matches(req *http.Request, now time.Time) bool {
+	switch r.Kind {
+	case RuleLanguage:
+		return matchLanguage(strings.Split(r.Value, ","), req.Header.Get("Accept-Language"))
+	case RulePlatform:
+		platforms := requestPlatforms(req.UserAgent())
+		for _, platform := range strings.Split(r.Value, ",") {
+			if containsFold(platforms, platform) {
+				return true
+			}
+		}
+	case RuleQuery:
+		key, value, hasValue := strings.Cut(r.Value, "=")
+		values, ok := req.URL.Query()[key]
+		if !ok {
+			return false
+		}
+		return !hasValue || containsFold(values, value)
+	case RuleTime:
+		start, end, err := parseTimeWindow(r.Value)
+		if err != nil {
+			return false
+		}
+		return (start.IsZero() || !now.Before(start)) && (end.IsZero() || now.Before(end))
+	}
+	return false
+}
+
+// SelectLinkRule returns the first of rules that matches req. Split rules
+// come last: if no other rule matched, one of them is chosen by random, a
+// number in [0, 1), according to their percentages. It returns nil if the
+// FullURL of the link should be used, which also gets the percentage that
+// is left over by the split rules.
+func SelectLinkRule(rules []*LinkRule, req *http.Request, now time.Time, random float64) *LinkRule {
+	var sum float64
+	for _, rule := range rules {
+		if rule.Kind != RuleSplit {
+			if rule.Matches(req, now) {
+				return rule
+			}
+			continue
+		}
+		weight, _ := strconv.Atoi(rule.Value)
+		sum += float64(weight) / 100
+		if random < sum {
+			return rule
+		}
+	}
+	return nil
+}
+
+func parseTimeWindow(value string) (start, end time.Time, err error) {
+	from, to, ok := strings.Cut(value, "/")
+	if !ok || (from == "" && to == "") {
+		return start, end, fmt.Errorf("time rules need a window like 2025-01-01T00:00:00Z/2025-02-01T00:00:00Z")
+	}
+	if from != "" {
+		start, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid start time %q", from)
+		}
+	}
+	if to != "" {
+		end, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid end time %q", to)
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return start, end, fmt.Errorf("the start time has to be before the end time")
+	}
+	return start, end, nil
+}
+
+// matchLanguage reports whether the Accept-Language header accepts one of
+// languages.
+func matchLanguage(languages []string, header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(strings.TrimSpace(params), " ", "") == "q=0" {
+			continue
+		}
+		primary, _, _ := strings.Cut(tag, "-")
+		for _, language := range languages {
+			if strings.EqualFold(tag, language) || strings.EqualFold(primary, language) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestPlatforms returns the platforms of a user agent.
+func requestPlatforms(userAgent string) []string {
+	var platforms []string
+	switch {
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "iPod"):
+		platforms = append(platforms, "ios", "mobile")
+	case strings.Contains(userAgent, "Android"):
+		platforms = append(platforms, "android", "mobile")
+	case strings.Contains(userAgent, "Windows"):
+		platforms = append(platforms, "windows", "desktop")
+	case strings.Contains(userAgent, "Macintosh"):
+		platforms = append(platforms, "macos", "desktop")
+	case strings.Contains(userAgent, "Linux"):
+		platforms = append(platforms, "linux", "desktop")
+	}
+	if strings.Contains(userAgent, "Mobile") && !containsFold(platforms, "mobile") {
+		platforms = append(platforms, "mobile")
+	}
+	return platforms
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package foundation
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSelectLinkRule(t *testing.T) {
+	rules := []*LinkRule{
+		{Variant: "de", Kind: RuleLanguage, Value: "de", FullURL: "https://example.com/de"},
+		{Variant: "app", Kind: RulePlatform, Value: "ios,android", FullURL: "https://example.com/app"},
+		{Variant: "promo", Kind: RuleQuery, Value: "ref=promo", FullURL: "https://example.com/promo"},
+		{Variant: "sale", Kind: RuleTime, Value: "2025-11-28T00:00:00Z/2025-12-01T00:00:00Z", FullURL: "https://example.com/sale"},
+		{Variant: "a", Kind: RuleSplit, Value: "20", FullURL: "https://example.com/a"},
+		{Variant: "b", Kind: RuleSplit, Value: "30", FullURL: "https://example.com/b"},
+	}
+	for _, rule := range rules {
+		err := rule.Check()
+		if err != nil {
+			t.Fatalf("Check %s failed: %v", rule.Variant, err)
+		}
+	}
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		target    string
+		header    map[string]string
+		now       time.Time
+		random    float64
+		expectVar string
+	}{
+		{name: "language region", target: "/x", header: map[string]string{"Accept-Language": "en-US,de-AT;q=0.8"}, random: 0.9, expectVar: "de"},
+		{name: "refused language", target: "/x", header: map[string]string{"Accept-Language": "en, de;q=0"}, random: 0.9},
+		{name: "platform", target: "/x", header: map[string]string{"User-Agent": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile"}, random: 0.9, expectVar: "app"},
+		{name: "query", target: "/x?ref=promo", random: 0.9, expectVar: "promo"},
+		{name: "other query value", target: "/x?ref=mail", random: 0.9},
+		{name: "time window", target: "/x", now: time.Date(2025, 11, 29, 12, 0, 0, 0, time.UTC), random: 0.9, expectVar: "sale"},
+		{name: "first split", target: "/x", random: 0.1, expectVar: "a"},
+		{name: "second split", target: "/x", random: 0.45, expectVar: "b"},
+		{name: "rest of split", target: "/x", random: 0.5},
+		{name: "rule order", target: "/x?ref=promo", header: map[string]string{"Accept-Language": "de"}, random: 0.1, expectVar: "de"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.target, nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		at := now
+		if !c.now.IsZero() {
+			at = c.now
+		}
+		var variant string
+		if rule := SelectLinkRule(rules, req, at, c.random); rule != nil {
+			variant = rule.Variant
+		}
+		if variant != c.expectVar {
+			t.Errorf("%s: expected variant %q, got %q", c.name, c.expectVar, variant)
+		}
+	}
+}
+
+func TestLinkRuleCheck(t *testing.T) {
+	invalid := []*LinkRule{
+		{Kind: "country", Value: "de"},
+		{Kind: RulePlatform, Value: "ios,amiga"},
+		{Kind: RuleQuery, Value: "=x"},
+		{Kind: RuleTime, Value: "2025-12-01T00:00:00Z/2025-11-01T00:00:00Z"},
+		{Kind: RuleTime, Value: "/"},
+		{Kind: RuleSplit, Value: "0"},
+		{Kind: RuleSplit, Value: "101"},
+	}
+	for _, rule := range invalid {
+		if rule.Check() == nil {
+			t.Errorf("expected %s %q to be invalid", rule.Kind, rule.Value)
+		}
+	}
+	open := &LinkRule{Kind: RuleTime, Value: "2025-11-01T00:00:00Z/"}
+	if err := open.Check(); err != nil {
+		t.Errorf("expected an open time window to be valid: %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	bits atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	addFloat(&c.bits, v)
+}
+
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Add(v float64) {
+	addFloat(&g.bits, v)
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + v)
+		if bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	mutex   sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// ObserveSince observes the seconds that passed since start.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+type vec[T any] struct {
+	d        desc
+	mutex    sync.RWMutex
+	children map[string]*vecChild[T]
+	create   func() *T
+	writeFn  func(w *bufio.Writer, d *desc, values []string, m *T)
+}
+
+type vecChild[T any] struct {
+	values []string
+	metric *T
+}
+
+func (v *vec[T]) desc() *desc {
+	return &v.d
+}
+
+func (v *vec[T]) with(values ...string) *T {
+	if len(values) != len(v.d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.d.name, len(v.d.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mutex.RLock()
+	c := v.children[key]
+	v.mutex.RUnlock()
+	if c != nil {
+		return c.metric
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	c = v.children[key]
+	if c == nil {
+		c = &vecChild[T]{
+			values: append([]string(nil), values...),
+			metric: v.create(),
+		}
+		v.children[key] = c
+	}
+	return c.metric
+}
+
+func (v *vec[T]) write(w *bufio.Writer) {
+	v.mutex.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	children := make([]*vecChild[T], 0, len(keys))
+	for _, key := range keys {
+		children = append(children, v.children[key])
+	}
+	v.mutex.RUnlock()
+
+	for _, c := range children {
+		v.writeFn(w, &v.d, c.values, c.metric)
+	}
+}
+
+func newVec[T any](r *Registry, d desc, create func() *T, writeFn func(*bufio.Writer, *desc, []string, *T)) *vec[T] {
+	v := &vec[T]{
+		d:        d,
+		children: make(map[string]*vecChild[T]),
+		create:   create,
+		writeFn:  writeFn,
+	}
+	r.register(v, false)
+	return v
+}
+
+func writeCounter(w *bufio.Writer, d *desc, values []string, c *Counter) {
+	writeSample(w, d.name, d.labels, values, "", "", c.Value())
+}
+
+func writeGauge(w *bufio.Writer, d *desc, values []string, g *Gauge) {
+	writeSample(w, d.name, d.labels, values, "", "", g.Value())
+}
+
+func writeHistogram(w *bufio.Writer, d *desc, values []string, h *Histogram) {
+	h.mutex.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	count, sum := h.count, h.sum
+	h.mutex.Unlock()
+
+	for i, upper := range h.buckets {
+		writeSample(w, d.name+"_bucket", d.labels, values, "le", formatFloat(upper), float64(counts[i]))
+	}
+	writeSample(w, d.name+"_bucket", d.labels, values, "le", "+Inf", float64(count))
+	writeSample(w, d.name+"_sum", d.labels, values, "", "", sum)
+	writeSample(w, d.name+"_count", d.labels, values, "", "", float64(count))
+}
+
+type CounterVec struct {
+	v *vec[Counter]
+}
+
+func (c *CounterVec) WithLabelValues(values ...string) *Counter {
+	return c.v.with(values...)
+}
+
+type GaugeVec struct {
+	v *vec[Gauge]
+}
+
+func (g *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return g.v.with(values...)
+}
+
+type HistogramVec struct {
+	v *vec[Histogram]
+}
+
+func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return h.v.with(values...)
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	d := desc{name: name, help: help, typ: "counter", labels: labels}
+	return &CounterVec{v: newVec(r, d, func() *Counter { return &Counter{} }, writeCounter)}
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	d := desc{name: name, help: help, typ: "gauge", labels: labels}
+	return &GaugeVec{v: newVec(r, d, func() *Gauge { return &Gauge{} }, writeGauge)}
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	d := desc{name: name, help: help, typ: "histogram", labels: labels}
+	return &HistogramVec{v: newVec(r, d, func() *Histogram { return newHistogram(buckets) }, writeHistogram)}
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).WithLabelValues()
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).WithLabelValues()
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	return r.NewHistogramVec(name, help, buckets).WithLabelValues()
+}
+
+type gaugeFunc struct {
+	d  desc
+	fn func() map[string]float64
+}
+
+func (g *gaugeFunc) desc() *desc {
+	return &g.d
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	values := g.fn()
+	if len(g.d.labels) == 0 {
+		writeSample(w, g.d.name, nil, nil, "", "", values[""])
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeSample(w, g.d.name, g.d.labels, []string{key}, "", "", values[key])
+	}
+}
+
+// NewGaugeFunc registers a gauge whose value is computed by fn at scrape time.
+// Registering a func under an existing name replaces the previous one, so
+// funcs that close over resources like a database can be registered again.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{
+		d:  desc{name: name, help: help, typ: "gauge"},
+		fn: func() map[string]float64 { return map[string]float64{"": fn()} },
+	}, true)
+}
+
+// NewGaugeVecFunc registers a gauge with a single label whose values are
+// computed by fn at scrape time. The keys of the returned map are the label values.
+func (r *Registry) NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) {
+	r.register(&gaugeFunc{
+		d:  desc{name: name, help: help, typ: "gauge", labels: []string{label}},
+		fn: fn,
+	}, true)
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGaugeVec(name, help, labels...)
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+func NewCounter(name, help string) *Counter {
+	return Default.NewCounter(name, help)
+}
+
+func NewGauge(name, help string) *Gauge {
+	return Default.NewGauge(name, help)
+}
+
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return Default.NewHistogram(name, help, buckets)
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) {
+	Default.NewGaugeFunc(name, help, fn)
+}
+
+func NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) {
+	Default.NewGaugeVecFunc(name, help, label, fn)
+}
@@ -0,0 +1,146 @@
+// Package metrics is a small, dependency free implementation of counters,
+// gauges and histograms that can be scraped in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets in seconds, suitable for
+// HTTP and database latencies.
+var DefBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry that all package level constructors register with.
+var Default = NewRegistry()
+
+type collector interface {
+	desc() *desc
+	write(w *bufio.Writer)
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+type Registry struct {
+	mutex      sync.RWMutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+func (r *Registry) register(c collector, replace bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	name := c.desc().name
+	if _, ok := r.collectors[name]; ok && !replace {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", name))
+	}
+	r.collectors[name] = c
+}
+
+// Write writes all registered metrics sorted by name in the Prometheus
+// text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mutex.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		d := c.desc()
+		fmt.Fprintf(bw, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", d.name, d.typ)
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler returns an http.Handler that serves the registry contents.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		err := r.Write(w)
+		if err != nil {
+			http.Error(w, "failed to write metrics", http.StatusInternalServerError)
+		}
+	})
+}
+
+func writeSample(w *bufio.Writer, name string, labels []string, values []string, extraKey, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraKey != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l)
+			w.WriteString(`="`)
+			w.WriteString(escapeLabel(values[i]))
+			w.WriteByte('"')
+		}
+		if extraKey != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraKey)
+			w.WriteString(`="`)
+			w.WriteString(extraValue)
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounterVec("test_requests_total", "Requests handled.", "route", "code")
+	requests.WithLabelValues("/b", "200").Add(2)
+	requests.WithLabelValues("/a", "404").Inc()
+
+	gauge := r.NewGauge("test_in_flight", "In flight requests.")
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+
+	r.NewGaugeVecFunc("test_listeners", "Listeners per channel.", "channel", func() map[string]float64 {
+		return map[string]float64{"links": 3}
+	})
+
+	var buf bytes.Buffer
+	err := r.Write(&buf)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	expected := `# HELP test_in_flight In flight requests.
+# TYPE test_in_flight gauge
+test_in_flight 1
+# HELP test_listeners Listeners per channel.
+# TYPE test_listeners gauge
+test_listeners{channel="links"} 3
+# HELP test_requests_total Requests handled.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",code="404"} 1
+test_requests_total{route="/b",code="200"} 2
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+
+	h := r.NewHistogram("test_duration_seconds", "Durations.", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var buf bytes.Buffer
+	err := r.Write(&buf)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	for _, line := range []string{
+		`test_duration_seconds_bucket{le="0.1"} 1`,
+		`test_duration_seconds_bucket{le="1"} 2`,
+		`test_duration_seconds_bucket{le="+Inf"} 3`,
+		`test_duration_seconds_sum 5.55`,
+		`test_duration_seconds_count 3`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("missing line %q in output:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Help with \\ and\nnewline.", "value").WithLabelValues("a\"b\\c\nd").Inc()
+
+	var buf bytes.Buffer
+	err := r.Write(&buf)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), `# HELP test_total Help with \\ and\nnewline.`) {
+		t.Errorf("help not escaped:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `test_total{value="a\"b\\c\nd"} 1`) {
+		t.Errorf("label not escaped:\n%s", buf.String())
+	}
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	r.NewCounter("test_total", "")
+}
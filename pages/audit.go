@@ -0,0 +1,347 @@
+package pages
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/auth"
+	"github.com/mbertschler/foundation/db"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+	"github.com/pkg/errors"
+)
+
+// linkAudit is the state of a link that is recorded in the audit log.
+type linkAudit struct {
+	ShortLink string   `json:"short_link"`
+	FullURL   string   `json:"full_url"`
+	UserID    int64    `json:"user_id"`
+	NoTrack   bool     `json:"no_track"`
+	Tags      []string `json:"tags"`
+	Rules     string   `json:"rules"`
+	foundation.RedirectOptions
+	foundation.AccessOptions
+	// Password is only set when the link password was changed.
+	Password string `json:"password,omitempty"`
+}
+
+func linkAuditOf(link *foundation.Link, tags []string, rules []*foundation.LinkRule) *linkAudit {
+	return &linkAudit{
+		ShortLink: link.ShortLink,
+		FullURL:   link.FullURL,
+		UserID:    link.UserID,
+		NoTrack:   link.NoTrack,
+		Tags:      tags,
+		Rules:     db.FormatLinkRules(rules),
+
+		RedirectOptions: link.RedirectOptions,
+		AccessOptions:   link.AccessOptions,
+	}
+}
+
+// userAudit is the state of a user that is recorded in the audit log.
+// Passwords are never recorded, only that they were changed.
+type userAudit struct {
+	DisplayName string `json:"display_name"`
+	UserName    string `json:"user_name"`
+	Password    string `json:"password,omitempty"`
+}
+
+func userAuditOf(user *foundation.User) *userAudit {
+	return &userAudit{
+		DisplayName: user.DisplayName,
+		UserName:    user.UserName,
+	}
+}
+
+// audit records a change by the user of req. before and after are nil for
+// created and deleted targets. It has to be called with the ctx of the
+// transaction that writes the change.
+func (h *Handler) audit(ctx context.Context, req *foundation.Request, action, target string, before, after any) error {
+	return h.auditChanges(ctx, req, action, target, db.AuditDiff(before, after))
+}
+
+func (h *Handler) auditChanges(ctx context.Context, req *foundation.Request, action, target string, changes map[string]foundation.AuditChange) error {
+	event := &foundation.AuditEvent{
+		Action:  action,
+		Target:  target,
+		Changes: changes,
+		IP:      auth.ClientIP(req.Request),
+	}
+	if req.User != nil {
+		event.ActorID = sql.NullInt64{Int64: req.User.ID, Valid: true}
+		event.ActorName = req.User.UserName
+	}
+	return h.DB.Audit.Insert(ctx, event)
+}
+
+func (h *Handler) AuditPage(req *foundation.Request) (*Page, error) {
+	auditFrame, err := h.AuditFrame(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "AuditFrame")
+	}
+
+	page := &Page{
+		Title:   "Quick Links - Audit Log",
+		Sidebar: Sidebar{},
+		Header: Header{
+			Title: "Audit Log",
+		},
+		Body: auditFrame,
+	}
+
+	return page, nil
+}
+
+func auditQuery(r *http.Request) db.AuditQuery {
+	values := r.URL.Query()
+	before, _ := strconv.ParseInt(values.Get("before"), 10, 64)
+	return db.AuditQuery{
+		Action: values.Get("action"),
+		Actor:  values.Get("actor"),
+		Target: values.Get("target"),
+		Before: before,
+	}
+}
+
+func auditURL(path string, q db.AuditQuery) string {
+	values := url.Values{}
+	if q.Action != "" {
+		values.Set("action", q.Action)
+	}
+	if q.Actor != "" {
+		values.Set("actor", q.Actor)
+	}
+	if q.Target != "" {
+		values.Set("target", q.Target)
+	}
+	if q.Before > 0 {
+		values.Set("before", strconv.FormatInt(q.Before, 10))
+	}
+	if len(values) == 0 {
+		return path
+	}
+	return path + "?" + values.Encode()
+}
+
+// AuditFrame renders a page of the audit log, filtered by the query
+// parameters action, actor and target.
+func (h *Handler) AuditFrame(req *foundation.Request) (html.Block, error) {
+	query := auditQuery(req.Request)
+	page, err := h.DB.Audit.Page(req.Context.Context, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "Audit.Page")
+	}
+	actions, err := h.DB.Audit.Actions(req.Context.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "Audit.Actions")
+	}
+
+	filter := query
+	filter.Before = 0
+	return html.Elem("turbo-frame", attr.Id("audit-frame"),
+		html.Div(attr.Class("p-4 md:p-6 xl:p-12"),
+			html.Main(attr.Class("mx-auto relative w-full max-w-screen-lg gap-10"),
+				html.Div(attr.Class("flex justify-between items-center mb-4"),
+					html.H2(attr.Class("text-2xl font-bold"), html.Text("Audit Log")),
+					html.Div(attr.Class("flex gap-2"),
+						html.A(attr.Href(auditURL("/admin/export/audit.csv", filter)).Class("btn-ghost").Attr("data-turbo", "false"),
+							html.Text("Export CSV"),
+						),
+						html.A(attr.Href(auditURL("/admin/export/audit.json", filter)).Class("btn-ghost").Attr("data-turbo", "false"),
+							html.Text("Export JSON"),
+						),
+					),
+				),
+				auditFilterForm(query, actions),
+				auditTable(page.Events),
+				auditPagination(page, query),
+			),
+		),
+	), nil
+}
+
+func auditFrameLink(href string) attr.Attributes {
+	return attr.Href(href).Attr("data-turbo-frame", "audit-frame").Attr("data-turbo-action", "advance")
+}
+
+func auditFilterForm(q db.AuditQuery, actions []string) html.Block {
+	options := html.Blocks{
+		html.Option(attr.Value(""), html.Text("All actions")),
+	}
+	for _, action := range actions {
+		option := attr.Value(action)
+		if action == q.Action {
+			option = option.Attr("selected", "")
+		}
+		options.Add(html.Option(option, html.Text(action)))
+	}
+	return html.Form(attr.Method("GET").Action("/admin/audit").Class("flex gap-2 mb-4").Attr("role", "search").Attr("data-turbo-frame", "audit-frame").Attr("data-turbo-action", "advance"),
+		html.Select(attr.Name("action").Class("select").Attr("aria-label", "Filter by action"),
+			options,
+		),
+		html.Input(attr.Type("text").Name("actor").Value(q.Actor).Attr("placeholder", "Username").Class("input").Attr("aria-label", "Filter by actor")),
+		html.Input(attr.Type("text").Name("target").Value(q.Target).Attr("placeholder", "Target, for example link:docs").Class("input").Attr("aria-label", "Filter by target")),
+		html.Button(attr.Type("submit").Class("btn-outline"),
+			html.Text("Filter"),
+		),
+	)
+}
+
+func auditTable(events []*foundation.AuditEvent) html.Block {
+	var rows html.Blocks
+	for _, e := range events {
+		rows.Add(auditTableRow(e))
+	}
+	if len(rows) == 0 {
+		rows.Add(html.Tr(nil,
+			html.Td(attr.Attr("colspan", "6").Class("text-center text-muted-foreground"),
+				html.Text("No events found."),
+			),
+		))
+	}
+
+	return html.Div(attr.Class("overflow-x-auto w-full"),
+		html.Table(attr.Class("table"),
+			html.Thead(nil,
+				html.Tr(nil,
+					html.Th(nil, html.Text("Time")),
+					html.Th(nil, html.Text("Actor")),
+					html.Th(nil, html.Text("Action")),
+					html.Th(nil, html.Text("Target")),
+					html.Th(nil, html.Text("Changes")),
+					html.Th(nil, html.Text("IP")),
+				),
+			),
+			html.Tbody(nil,
+				rows,
+			),
+		),
+	)
+}
+
+func auditTableRow(e *foundation.AuditEvent) html.Block {
+	actor := e.ActorName
+	if actor == "" {
+		actor = "system"
+	}
+	return html.Tr(nil,
+		html.Td(attr.Class("whitespace-nowrap"),
+			html.Text(e.CreatedAt.Local().Format("2006-01-02 15:04:05")),
+		),
+		html.Td(nil,
+			html.Text(actor),
+		),
+		html.Td(nil,
+			html.Span(attr.Class("badge-secondary"), html.Text(e.Action)),
+		),
+		html.Td(attr.Class("font-mono"),
+			html.A(auditFrameLink(auditURL("/admin/audit", db.AuditQuery{Target: e.Target})),
+				html.Text(e.Target),
+			),
+		),
+		html.Td(nil,
+			auditChanges(e.Changes),
+		),
+		html.Td(attr.Class("font-mono text-sm"),
+			html.Text(e.IP),
+		),
+	)
+}
+
+// auditChanges lists the changed fields with their old and new values.
+func auditChanges(changes map[string]foundation.AuditChange) html.Block {
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var items html.Blocks
+	for _, name := range names {
+		change := changes[name]
+		items.Add(html.Li(nil,
+			html.Span(attr.Class("font-medium"), html.Text(name+": ")),
+			html.Span(attr.Class("text-muted-foreground line-through"), html.Text(auditValue(change.Before))),
+			html.Text(" → "),
+			html.Span(nil, html.Text(auditValue(change.After))),
+		))
+	}
+	return html.Ul(attr.Class("text-sm"), items)
+}
+
+func auditValue(v any) string {
+	if v == nil {
+		return "–"
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(buf)
+}
+
+func auditPagination(page *db.AuditPage, q db.AuditQuery) html.Block {
+	first := q
+	first.Before = 0
+	next := q
+	next.Before = page.Next
+
+	var links html.Blocks
+	if q.Before > 0 {
+		links.Add(html.A(auditFrameLink(auditURL("/admin/audit", first)).Class("btn-sm-outline"),
+			html.Text("First page"),
+		))
+	}
+	if page.Next > 0 {
+		links.Add(html.A(auditFrameLink(auditURL("/admin/audit", next)).Class("btn-sm-outline"),
+			html.Text("Next page"),
+		))
+	}
+	return html.Div(attr.Class("flex justify-end gap-2 mt-4"),
+		links,
+	)
+}
+
+// AuditExport downloads the audit events selected by the same filters as
+// the audit page, as CSV or JSON depending on the extension of the path.
+func (h *Handler) AuditExport(req *foundation.Request) (html.Block, error) {
+	format, err := db.FileFormatOf(req.Request.URL.Path)
+	if err != nil {
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "", err)
+	}
+
+	query := auditQuery(req.Request)
+	query.Before = 0
+	events, err := h.DB.Audit.Export(req.Context.Context, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "Audit.Export")
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == db.FormatJSON {
+		contentType = "application/json"
+	}
+	name := fmt.Sprintf("audit-%s%s", time.Now().UTC().Format("20060102T150405Z"), path.Ext(req.Request.URL.Path))
+	req.Writer.Header().Set("Content-Type", contentType)
+	req.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	err = db.WriteAuditEvents(req.Writer, format, events)
+	if err != nil {
+		// the headers are already sent, so only log the error
+		log.Println("AuditExport WriteAuditEvents error:", err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,152 @@
+package pages
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/db"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+	"github.com/pkg/errors"
+)
+
+func (h *Handler) BackupsPage(req *foundation.Request) (*Page, error) {
+	backupsFrame, err := h.BackupsFrame(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "backupsFrame")
+	}
+
+	page := &Page{
+		Title:   "Quick Links - Backups",
+		Sidebar: Sidebar{},
+		Header: Header{
+			Title: "Backups",
+		},
+		Body: backupsFrame,
+	}
+
+	return page, nil
+}
+
+func (h *Handler) BackupsFrame(req *foundation.Request) (html.Block, error) {
+	if !h.DB.Backups.Enabled() {
+		return html.Elem("turbo-frame", attr.Id("backups-frame"),
+			html.Div(attr.Class("p-4 md:p-6 xl:p-12"),
+				html.P(attr.Class("text-muted-foreground"),
+					html.Text("Built-in backups are disabled. Set BackupDir in the config to enable them."),
+				),
+			),
+		), nil
+	}
+
+	if req.Request.Method == http.MethodPost {
+		snapshot, err := h.DB.Backups.Create(req.Context)
+		if err != nil {
+			return nil, errors.Wrap(err, "Backups.Create")
+		}
+		log.Printf("user %d created backup %s", req.User.ID, snapshot.Name)
+	}
+
+	snapshots, err := h.DB.Backups.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "Backups.List")
+	}
+
+	return html.Elem("turbo-frame", attr.Id("backups-frame"),
+		html.Div(attr.Class("p-4 md:p-6 xl:p-12"),
+			html.Main(attr.Class("mx-auto relative w-full max-w-screen-lg gap-10"),
+				html.Div(attr.Class("flex justify-between items-center mb-4"),
+					html.H2(attr.Class("text-2xl font-bold"), html.Text("Database Snapshots")),
+					html.Form(attr.Method("POST").Action("/admin/backups").Attr("data-turbo-frame", "backups-frame"),
+						html.Button(attr.Type("submit").Class("btn-outline"),
+							html.Text("Create Backup"),
+						),
+					),
+				),
+				snapshotsTable(snapshots),
+			),
+		),
+	), nil
+}
+
+// BackupDownload streams a snapshot file to the client.
+func (h *Handler) BackupDownload(req *foundation.Request) (html.Block, error) {
+	snapshot, err := h.DB.Backups.ByName(req.Params.ByName("name"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "Snapshot not found", err)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Backups.ByName")
+	}
+
+	req.Writer.Header().Set("Content-Type", "application/gzip")
+	req.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", snapshot.Name))
+	http.ServeFile(req.Writer, req.Request, snapshot.Path)
+	return nil, nil
+}
+
+func snapshotsTable(snapshots []*db.Snapshot) html.Block {
+	var rows html.Blocks
+	for _, s := range snapshots {
+		rows.Add(snapshotTableRow(s))
+	}
+
+	return html.Div(attr.Class("overflow-x-auto w-full"),
+		html.Table(attr.Class("table"),
+			html.Thead(nil,
+				html.Tr(nil,
+					html.Th(nil,
+						html.Text("Snapshot"),
+					),
+					html.Th(nil,
+						html.Text("Created"),
+					),
+					html.Th(nil,
+						html.Text("Size"),
+					),
+					html.Th(nil,
+						html.Text("Actions"),
+					),
+				),
+			),
+			html.Tbody(nil,
+				rows,
+			),
+		),
+	)
+}
+
+func snapshotTableRow(s *db.Snapshot) html.Block {
+	return html.Tr(nil,
+		html.Td(attr.Class("font-medium font-mono"),
+			html.Text(s.Name),
+		),
+		html.Td(attr.Class("text-right"),
+			html.Text(s.CreatedAt.Local().Format("2006-01-02 15:04")),
+		),
+		html.Td(attr.Class("text-right"),
+			html.Text(formatBytes(s.Size)),
+		),
+		html.Td(nil,
+			html.A(attr.Href(fmt.Sprintf("/admin/backups/%s", s.Name)).Class("btn-ghost").Attr("data-turbo", "false"),
+				html.Text("Download"),
+			),
+		),
+	)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
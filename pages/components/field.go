@@ -0,0 +1,37 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+)
+
+// Field is a labeled form input with an optional error message below it.
+type Field struct {
+	Id    string
+	Label string
+	// Input holds the attributes of the input element, the id is set from Id.
+	Input attr.Attributes
+	Error string
+}
+
+func (f Field) RenderHTML() html.Block {
+	input := f.Input.Id(f.Id)
+	var errBlock html.Block
+	if f.Error != "" {
+		errId := fmt.Sprintf("%s-error", f.Id)
+		input = input.Attr("aria-invalid", "true").Attr("aria-describedby", errId)
+		errBlock = html.P(attr.Id(errId).Class("text-destructive text-sm"),
+			html.Text(f.Error),
+		)
+	}
+
+	return html.Div(attr.Class("grid gap-3"),
+		html.Label(attr.For(f.Id),
+			html.Text(f.Label),
+		),
+		html.Input(input),
+		errBlock,
+	)
+}
@@ -0,0 +1,97 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+)
+
+type errorText struct {
+	title   string
+	message string
+}
+
+// errorTexts are shown for common status codes, other ones get the standard
+// status text.
+var errorTexts = map[int]errorText{
+	http.StatusForbidden: {
+		title:   "Access denied",
+		message: "You don't have permission to do this.",
+	},
+	http.StatusNotFound: {
+		title:   "Not found",
+		message: "The page or short link you are looking for doesn't exist.",
+	},
+	http.StatusGone: {
+		title:   "Link removed",
+		message: "This short link existed once, but it has been removed.",
+	},
+	http.StatusInternalServerError: {
+		title:   "Something went wrong",
+		message: "An unexpected error happened on our side. Please try again later.",
+	},
+}
+
+func errorTextFor(status int, message string) errorText {
+	text, ok := errorTexts[status]
+	if !ok {
+		text = errorText{title: http.StatusText(status)}
+	}
+	// specific messages replace the generic ones, but not the other way around
+	if message != "" && message != http.StatusText(status) {
+		text.message = message
+	}
+	return text
+}
+
+// ErrorPage is a standalone page for error responses that doesn't need a
+// logged in user.
+func ErrorPage(status int, message string) *Page {
+	text := errorTextFor(status, message)
+	return &Page{
+		Title: fmt.Sprintf("Quick Links - %s", text.title),
+		Body: html.Div(attr.Class("min-h-screen grid place-items-center bg-gray-100"),
+			html.Div(attr.Class("card max-w-md w-full"),
+				html.Header(nil,
+					html.P(attr.Class("text-sm text-muted-foreground"),
+						html.Text(fmt.Sprintf("Error %d", status)),
+					),
+					html.H2(nil,
+						html.Text(text.title),
+					),
+					html.P(nil,
+						html.Text(text.message),
+					),
+				),
+				html.Footer(nil,
+					html.A(attr.Href("/admin").Class("btn-outline"),
+						html.Text("Back to Quick Links"),
+					),
+				),
+			),
+		),
+	}
+}
+
+// ErrorFrame shows an error inside the turbo frame with the given id, so
+// that failed frame requests don't replace the frame with an empty one.
+func ErrorFrame(frameID string, status int, message string) html.Block {
+	text := errorTextFor(status, message)
+	return html.Elem("turbo-frame", attr.Id(frameID),
+		html.Div(attr.Class("alert-destructive").Attr("role", "alert"),
+			html.Elem("svg", attr.Attr("xmlns", "http://www.w3.org/2000/svg").Width("24").Height("24").Attr("viewbox", "0 0 24 24").Attr("fill", "none").Attr("stroke", "currentColor").Attr("stroke-width", "2").Attr("stroke-linecap", "round").Attr("stroke-linejoin", "round"),
+				html.Elem("circle", attr.Attr("cx", "12").Attr("cy", "12").Attr("r", "10")),
+				html.Elem("line", attr.Attr("x1", "12").Attr("x2", "12").Attr("y1", "8").Attr("y2", "12")),
+				html.Elem("line", attr.Attr("x1", "12").Attr("x2", "12.01").Attr("y1", "16").Attr("y2", "16")),
+			),
+			html.H2(nil,
+				html.Text(text.title),
+			),
+			html.Section(nil,
+				html.Text(text.message),
+			),
+		),
+	)
+}
@@ -4,18 +4,34 @@ import (
 	"github.com/mbertschler/foundation/auth"
 	"github.com/mbertschler/foundation/db"
 	"github.com/mbertschler/foundation/server/broadcast"
+	"github.com/mbertschler/foundation/server/health"
 )
 
 type Handler struct {
 	DB        *db.DB
 	Auth      *auth.Handler
 	Broadcast *broadcast.Broadcaster
+	Health    *health.Checker
 }
 
-func NewHandler(database *db.DB, broadcaster *broadcast.Broadcaster) *Handler {
+// RedirectsChannel is the broadcast channel that clears the redirect cache.
+// Unlike "links", it is not sent for visits.
+const RedirectsChannel = "redirects"
+
+func NewHandler(database *db.DB, broadcaster *broadcast.Broadcaster, checker *health.Checker) *Handler {
+	go database.Redirects.InvalidateOn(broadcaster.Listen(RedirectsChannel).C)
 	return &Handler{
 		DB:        database,
 		Auth:      auth.NewHandler(database),
 		Broadcast: broadcaster,
+		Health:    checker,
 	}
 }
+
+// linksChanged updates the live views and clears the redirect cache after
+// links were changed.
+func (h *Handler) linksChanged() error {
+	// if the message is dropped, the cache still has one to handle
+	_ = h.Broadcast.Send(RedirectsChannel)
+	return h.Broadcast.Send("links")
+}
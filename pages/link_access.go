@@ -0,0 +1,109 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/auth"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+	"github.com/pkg/errors"
+)
+
+// canVisit reports whether the visitor of req may visit link, see
+// foundation.LinkAccessModes.
+func canVisit(req *foundation.Request, link *foundation.Link) bool {
+	switch link.Mode() {
+	case foundation.AccessLogin:
+		return req.User != nil
+	case foundation.AccessPassword:
+		return auth.LinkUnlocked(req, link)
+	default:
+		return true
+	}
+}
+
+// lockedLink asks the visitor of a link that they can't visit yet to log
+// in, or to enter the link password. A correct password unlocks the link
+// and visits it again.
+func (h *Handler) lockedLink(req *foundation.Request, link *foundation.Link) (html.Block, error) {
+	visit := req.Request.URL.RequestURI()
+	if link.Mode() == foundation.AccessLogin {
+		http.Redirect(req.Writer, req.Request, "/admin/login?next="+url.QueryEscape(visit), http.StatusFound)
+		return nil, nil
+	}
+
+	message := ""
+	if req.Request.Method == http.MethodPost {
+		err := auth.UnlockLink(req, link, req.Request.PostFormValue("password"))
+		switch {
+		case err == nil:
+			http.Redirect(req.Writer, req.Request, visit, http.StatusSeeOther)
+			return nil, nil
+		case errors.Is(err, auth.ErrWrongLinkPassword):
+			message = "The password is not correct."
+		case errors.Is(err, auth.ErrTooManyAttempts):
+			message = "Too many failed attempts, please try again later."
+		default:
+			return nil, errors.Wrap(err, "UnlockLink")
+		}
+	}
+
+	var errBlock html.Block
+	if message != "" {
+		errBlock = html.Div(attr.Class("alert-destructive").Attr("role", "alert"),
+			html.Elem("svg", attr.Attr("xmlns", "http://www.w3.org/2000/svg").Width("24").Height("24").Attr("viewbox", "0 0 24 24").Attr("fill", "none").Attr("stroke", "currentColor").Attr("stroke-width", "2").Attr("stroke-linecap", "round").Attr("stroke-linejoin", "round"),
+				html.Elem("circle", attr.Attr("cx", "12").Attr("cy", "12").Attr("r", "10")),
+				html.Elem("line", attr.Attr("x1", "12").Attr("x2", "12").Attr("y1", "8").Attr("y2", "12")),
+				html.Elem("line", attr.Attr("x1", "12").Attr("x2", "12.01").Attr("y1", "16").Attr("y2", "16")),
+			),
+			html.H2(nil,
+				html.Text("Wrong password"),
+			),
+			html.Section(nil,
+				html.Text(message),
+			),
+		)
+	}
+
+	page := &Page{
+		Title: "Quick Links - " + link.Name(),
+		Body: html.Div(attr.Class("min-h-screen grid place-items-center bg-gray-100"),
+			html.Div(attr.Class("card max-w-md w-full"),
+				html.Header(nil,
+					html.P(attr.Class("text-sm text-muted-foreground"),
+						html.Text("Short link "+link.Name()),
+					),
+					html.H2(nil,
+						html.Text("This link is password protected"),
+					),
+					html.P(nil,
+						html.Text("Enter the password of the link to continue."),
+					),
+					errBlock,
+				),
+				html.Section(nil,
+					// submitted without Turbo, which can't follow the
+					// redirect to another site
+					html.Form(attr.Id("unlock-form").Class("form grid gap-6").Method("POST").Action(visit).Attr("data-turbo", "false"),
+						csrfField(req),
+						html.Div(attr.Class("grid gap-2"),
+							html.Label(attr.For("unlock-form-password"),
+								html.Text("Password"),
+							),
+							html.Input(attr.Type("password").Name("password").Id("unlock-form-password").Required("").Autofocus("")),
+						),
+					),
+				),
+				html.Footer(nil,
+					html.Button(attr.Form("unlock-form").Type("submit").Class("btn w-full"),
+						html.Text("Unlock"),
+					),
+				),
+			),
+		),
+	}
+	req.Writer.WriteHeader(http.StatusUnauthorized)
+	return page.RenderHTML(req), nil
+}
@@ -0,0 +1,97 @@
+package pages
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+	"github.com/pkg/errors"
+)
+
+// linkPreview renders the preview page of a link, which shows where the
+// link goes instead of redirecting. external adds a warning that the
+// destination is not on one of the TrustedDomains.
+func (h *Handler) linkPreview(req *foundation.Request, shortLink, destination string, external bool) (html.Block, error) {
+	// the cached link doesn't have a current visit count
+	link, err := h.DB.Links.ByShortLink(req.Context.Context, shortLink)
+	if err != nil {
+		return nil, errors.Wrap(err, "Links.ByShortLink")
+	}
+	owner := "Unknown"
+	user, err := h.DB.Users.ByID(req.Context.Context, link.UserID)
+	if err == nil {
+		owner = user.DisplayName
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.Wrap(err, "Users.ByID")
+	}
+
+	host := destination
+	if u, err := url.Parse(destination); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	var warning html.Block
+	if external {
+		warning = html.Div(attr.Class("alert-destructive").Attr("role", "alert"),
+			html.Elem("svg", attr.Attr("xmlns", "http://www.w3.org/2000/svg").Width("24").Height("24").Attr("viewbox", "0 0 24 24").Attr("fill", "none").Attr("stroke", "currentColor").Attr("stroke-width", "2").Attr("stroke-linecap", "round").Attr("stroke-linejoin", "round"),
+				html.Elem("path", attr.Attr("d", "m21.73 18-8-14a2 2 0 0 0-3.48 0l-8 14A2 2 0 0 0 4 21h16a2 2 0 0 0 1.73-3")),
+				html.Elem("path", attr.Attr("d", "M12 9v4")),
+				html.Elem("path", attr.Attr("d", "M12 17h.01")),
+			),
+			html.H2(nil,
+				html.Text("External site"),
+			),
+			html.Section(nil,
+				html.Text(fmt.Sprintf("This link leads to %s, which is not one of our trusted domains. Only continue if you trust the site.", host)),
+			),
+		)
+	}
+
+	page := &Page{
+		Title: "Quick Links - " + link.Name(),
+		Body: html.Div(attr.Class("min-h-screen grid place-items-center bg-gray-100"),
+			html.Div(attr.Class("card max-w-lg w-full"),
+				html.Header(nil,
+					html.P(attr.Class("text-sm text-muted-foreground"),
+						html.Text("Short link "+link.Name()),
+					),
+					html.H2(nil,
+						html.Text("This link goes to "+host),
+					),
+					html.P(attr.Class("break-all"),
+						html.Text(destination),
+					),
+				),
+				html.Section(attr.Class("grid gap-4"),
+					warning,
+					html.Dl(attr.Class("grid grid-cols-[auto_1fr] gap-x-4 gap-y-1 text-sm"),
+						previewItem("Owner", owner),
+						previewItem("Created", link.CreatedAt.Local().Format("2006-01-02")),
+						previewItem("Visits", strconv.FormatInt(link.VisitsCount, 10)),
+					),
+				),
+				html.Footer(attr.Class("flex gap-2"),
+					html.A(attr.Href(destination).Class("btn").Rel("noreferrer"),
+						html.Text("Continue to "+host),
+					),
+				),
+			),
+		),
+	}
+	return page.RenderHTML(req), nil
+}
+
+func previewItem(label, value string) html.Block {
+	return html.Blocks{
+		html.Dt(attr.Class("text-muted-foreground"),
+			html.Text(label),
+		),
+		html.Dd(nil,
+			html.Text(value),
+		),
+	}
+}
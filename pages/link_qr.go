@@ -0,0 +1,131 @@
+package pages
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/qr"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultQRSize = 512
+	maxQRSize     = 4096
+	maxQRMargin   = 16
+)
+
+// LinkQR renders the QR code of a short link as qr.svg or qr.png. The size
+// in pixels, the margin in modules and the error correction level L, M, Q
+// or H can be set with the size, margin and level parameters. With
+// download=1 the image is sent as an attachment.
+func (h *Handler) LinkQR(req *foundation.Request) (html.Block, error) {
+	shortLink := req.Params.ByName("short_link")
+	link, err := h.DB.Links.ByShortLink(req.Context.Context, shortLink)
+	if err != nil {
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "Link not found", err)
+	}
+
+	query := req.Request.URL.Query()
+	size, err := qrParam(query.Get("size"), defaultQRSize, 32, maxQRSize)
+	if err != nil {
+		return nil, foundation.NewHTTPError(http.StatusBadRequest, "Invalid size", err)
+	}
+	margin, err := qrParam(query.Get("margin"), qr.DefaultMargin, 0, maxQRMargin)
+	if err != nil {
+		return nil, foundation.NewHTTPError(http.StatusBadRequest, "Invalid margin", err)
+	}
+	level := qr.Medium
+	if l := query.Get("level"); l != "" {
+		level, err = qr.ParseLevel(l)
+		if err != nil {
+			return nil, foundation.NewHTTPError(http.StatusBadRequest, "Invalid level", err)
+		}
+	}
+
+	code, err := qr.Encode(shortLinkURL(req, link.ShortLink), level)
+	if err != nil {
+		return nil, errors.Wrap(err, "qr.Encode")
+	}
+
+	ext := path.Ext(req.Request.URL.Path)
+	header := req.Writer.Header()
+	if query.Get("download") == "1" {
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "qr-"+link.Name()+ext))
+	}
+	header.Set("Cache-Control", "no-cache")
+	if ext == ".png" {
+		header.Set("Content-Type", "image/png")
+		err = code.PNG(req.Writer, size, margin)
+	} else {
+		header.Set("Content-Type", "image/svg+xml")
+		err = code.SVG(req.Writer, size, margin)
+	}
+	if err != nil {
+		// the headers are already sent, so only log the error
+		log.Println("LinkQR error:", err)
+	}
+	return nil, nil
+}
+
+func qrParam(value string, fallback, min, max int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < min || n > max {
+		return 0, errors.Errorf("%d is not between %d and %d", n, min, max)
+	}
+	return n, nil
+}
+
+// shortLinkURL is the absolute URL of a short link. Links on the default
+// domain use the host of the request.
+func shortLinkURL(req *foundation.Request, key string) string {
+	scheme := "http"
+	if req.Request.TLS != nil {
+		scheme = "https"
+	}
+	name, domain := foundation.SplitLinkKey(key)
+	if domain == "" {
+		domain = req.Request.Host
+	}
+	return fmt.Sprintf("%s://%s/%s", scheme, domain, name)
+}
+
+// linkQRPreview shows the QR code of a link in the edit dialog with
+// download buttons.
+func linkQRPreview(req *foundation.Request, shortLink string) html.Block {
+	qrURL := func(ext, params string) string {
+		return fmt.Sprintf("/admin/links/%s/qr.%s?%s", shortLink, ext, params)
+	}
+	return html.Elem("details", attr.Class("mt-6 pt-4 border-t"),
+		html.Elem("summary", attr.Class("cursor-pointer font-medium"),
+			html.Text("QR Code"),
+		),
+		html.Div(attr.Class("flex items-center gap-4 mt-3"),
+			html.Img(attr.Src(qrURL("svg", "size=128")).Alt("QR code of "+shortLinkURL(req, shortLink)).Width("128").Height("128").Attr("loading", "lazy")),
+			html.Div(attr.Class("grid gap-2"),
+				html.P(attr.Class("text-sm text-muted-foreground break-all"),
+					html.Text(shortLinkURL(req, shortLink)),
+				),
+				html.Div(attr.Class("flex gap-2"),
+					html.A(attr.Href(qrURL("svg", "download=1")).Class("btn-sm-outline").Attr("data-turbo", "false"),
+						html.Text("Download SVG"),
+					),
+					html.A(attr.Href(qrURL("png", "size=1024&download=1")).Class("btn-sm-outline").Attr("data-turbo", "false"),
+						html.Text("Download PNG"),
+					),
+				),
+			),
+		),
+	)
+}
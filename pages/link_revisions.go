@@ -0,0 +1,179 @@
+package pages
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/db"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+	"github.com/pkg/errors"
+)
+
+// recordRevision stores rev as made by the user of req. It has to be
+// called with the ctx of the transaction that writes the change.
+func (h *Handler) recordRevision(ctx context.Context, req *foundation.Request, rev *foundation.LinkRevision) error {
+	if req.User != nil {
+		rev.ActorID = sql.NullInt64{Int64: req.User.ID, Valid: true}
+		rev.ActorName = req.User.UserName
+	}
+	return h.DB.Revisions.Insert(ctx, rev)
+}
+
+// LinkRevertFrame restores the URL, tags, tracking setting, redirect
+// options, access mode and targeting rules of a link from an earlier
+// revision, which is recorded as a new revision. Revisions don't keep link
+// passwords, so the current password stays.
+func (h *Handler) LinkRevertFrame(req *foundation.Request) (html.Block, error) {
+	shortLink := req.Params.ByName("short_link")
+	revisionID, err := strconv.ParseInt(req.Params.ByName("id"), 10, 64)
+	if err != nil {
+		return nil, foundation.NewHTTPError(http.StatusBadRequest, "Invalid revision ID", err)
+	}
+
+	link, err := h.DB.Links.ByShortLink(req.Context.Context, shortLink)
+	if err != nil {
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "Link not found", err)
+	}
+	rev, err := h.DB.Revisions.ByID(req.Context.Context, revisionID)
+	if err != nil || rev.ShortLink != link.ShortLink {
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "Revision not found", err)
+	}
+	err = h.DB.Links.LoadTags(req.Context.Context, link)
+	if err != nil {
+		return nil, errors.Wrap(err, "LoadTags")
+	}
+	rules, err := h.DB.Rules.ByShortLink(req.Context.Context, link.ShortLink)
+	if err != nil {
+		return nil, errors.Wrap(err, "Rules.ByShortLink")
+	}
+	if rev.Access == foundation.AccessPassword && link.PasswordHash == "" {
+		return nil, foundation.NewHTTPError(http.StatusConflict, "The link has no password, set one in the link dialog instead of reverting", nil)
+	}
+	before := linkAuditOf(link, db.TagNames(link.Tags), rules)
+	// revisions from before targeting rules have no rules, which is valid
+	revRules, err := db.ParseLinkRules(rev.Rules)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseLinkRules")
+	}
+
+	link.FullURL = rev.FullURL
+	link.NoTrack = rev.NoTrack
+	link.RedirectOptions = rev.RedirectOptions
+	link.Access = rev.Access
+	if rev.Access != foundation.AccessPassword {
+		link.PasswordHash = ""
+	}
+	link.UpdatedAt = time.Now()
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		err := h.DB.Links.Update(ctx, link)
+		if err != nil {
+			return errors.Wrap(err, "Update link")
+		}
+		err = h.DB.Tags.Set(ctx, link.ShortLink, rev.Tags)
+		if err != nil {
+			return errors.Wrap(err, "Tags.Set")
+		}
+		err = h.DB.Rules.Set(ctx, link.ShortLink, revRules)
+		if err != nil {
+			return errors.Wrap(err, "Rules.Set")
+		}
+		revert := db.NewLinkRevision(link, rev.Tags, rev.Rules, "revert")
+		revert.RevertedFrom = sql.NullInt64{Int64: rev.ID, Valid: true}
+		err = h.recordRevision(ctx, req, revert)
+		if err != nil {
+			return err
+		}
+		return h.audit(ctx, req, "link.revert", "link:"+link.ShortLink, before, linkAuditOf(link, rev.Tags, revRules))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.linksChanged()
+	if err != nil {
+		return nil, errors.Wrap(err, "linksChanged")
+	}
+	return h.linksFrame(req, linksQuery(req.Request), nil)
+}
+
+// revisionState is the part of a revision that is compared to show what
+// changed between two revisions.
+type revisionState struct {
+	FullURL string   `json:"full_url"`
+	NoTrack bool     `json:"no_track"`
+	Tags    []string `json:"tags"`
+	Rules   string   `json:"rules"`
+	foundation.RedirectOptions
+	Access foundation.LinkAccess `json:"access"`
+}
+
+// linkHistory lists the revisions of a link, newest first, with what
+// changed compared to the previous revision. All but the newest revision
+// can be reverted to.
+func linkHistory(shortLink string, revisions []*foundation.LinkRevision, q db.LinkQuery) html.Block {
+	var items html.Blocks
+	for i, rev := range revisions {
+		var changes map[string]foundation.AuditChange
+		after := &revisionState{FullURL: rev.FullURL, NoTrack: rev.NoTrack, Tags: rev.Tags, Rules: rev.Rules, RedirectOptions: rev.RedirectOptions, Access: rev.Access}
+		if i+1 < len(revisions) {
+			prev := revisions[i+1]
+			changes = db.AuditDiff(&revisionState{FullURL: prev.FullURL, NoTrack: prev.NoTrack, Tags: prev.Tags, Rules: prev.Rules, RedirectOptions: prev.RedirectOptions, Access: prev.Access}, after)
+		} else {
+			changes = db.AuditDiff(nil, after)
+		}
+		if rev.RenamedFrom != "" {
+			changes["short_link"] = foundation.AuditChange{Before: rev.RenamedFrom, After: rev.ShortLink}
+		}
+
+		actor := rev.ActorName
+		if actor == "" {
+			actor = "system"
+		}
+		action := rev.Action
+		if rev.RevertedFrom.Valid {
+			action = fmt.Sprintf("revert to #%d", rev.RevertedFrom.Int64)
+		}
+
+		var revert html.Block
+		if i > 0 {
+			revert = html.Form(attr.Method("POST").Action(linksURL(fmt.Sprintf("/admin/links/%s/revisions/%d/revert", shortLink, rev.ID), q)).Attr("data-turbo-frame", "links-frame").Attr("data-turbo-confirm", fmt.Sprintf("Revert %q to revision #%d?", shortLink, rev.ID)),
+				html.Button(attr.Type("submit").Class("btn-sm-outline"),
+					html.Text("Revert"),
+				),
+			)
+		}
+
+		items.Add(html.Li(attr.Class("py-3 border-b last:border-b-0"),
+			html.Div(attr.Class("flex justify-between items-center gap-2"),
+				html.Div(attr.Class("text-sm"),
+					html.Span(attr.Class("font-medium"), html.Text(fmt.Sprintf("#%d %s", rev.ID, action))),
+					html.Span(attr.Class("text-muted-foreground"),
+						html.Text(fmt.Sprintf(" by %s, %s", actor, rev.CreatedAt.Local().Format("2006-01-02 15:04"))),
+					),
+				),
+				revert,
+			),
+			auditChanges(changes),
+		))
+	}
+	if len(items) == 0 {
+		items.Add(html.Li(attr.Class("py-3 text-sm text-muted-foreground"),
+			html.Text("No revisions recorded."),
+		))
+	}
+
+	return html.Elem("details", attr.Class("mt-6 pt-4 border-t"),
+		html.Elem("summary", attr.Class("cursor-pointer font-medium"),
+			html.Text(fmt.Sprintf("History (%d)", len(revisions))),
+		),
+		html.Ul(attr.Class("max-h-64 overflow-y-auto"),
+			items,
+		),
+	)
+}
@@ -1,17 +1,30 @@
 package pages
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/auth"
+	"github.com/mbertschler/foundation/db"
+	"github.com/mbertschler/foundation/form"
+	"github.com/mbertschler/foundation/metrics"
+	"github.com/mbertschler/foundation/pages/components"
 	"github.com/mbertschler/html"
 	"github.com/mbertschler/html/attr"
 	"github.com/pkg/errors"
 )
 
+var redirectsTotal = metrics.NewCounterVec("foundation_redirects_total",
+	"Number of short link lookups by result.", "result")
+
 func (h *Handler) LinksPage(req *foundation.Request) (*Page, error) {
 	linksFrame, err := h.LinksFrame(req)
 	if err != nil {
@@ -20,7 +33,6 @@ func (h *Handler) LinksPage(req *foundation.Request) (*Page, error) {
 
 	var body html.Blocks
 	body.Add(linksFrame)
-	body.Add(html.Elem("turbo-stream-source", attr.Src("/admin/stream/links")))
 
 	page := &Page{
 		Title:   "Quick Links - Links",
@@ -34,40 +46,136 @@ func (h *Handler) LinksPage(req *foundation.Request) (*Page, error) {
 	return page, nil
 }
 
+// linksQuery reads the search, sort and page of the links table from the
+// URL query, so that they survive reloads and turbo-frame navigations.
+func linksQuery(r *http.Request) db.LinkQuery {
+	values := r.URL.Query()
+	q := db.LinkQuery{
+		Search: strings.TrimSpace(values.Get("q")),
+		Sort:   db.LinkSort(values.Get("sort")),
+		Desc:   values.Get("dir") == "desc",
+		Tag:    values.Get("tag"),
+		After:  values.Get("after"),
+	}
+	if !db.ValidLinkSort(q.Sort) {
+		q.Sort = db.SortShortLink
+	}
+	return q
+}
+
+// linksURL returns path with the query parameters that select q.
+func linksURL(path string, q db.LinkQuery) string {
+	values := url.Values{}
+	if q.Search != "" {
+		values.Set("q", q.Search)
+	}
+	if q.Tag != "" {
+		values.Set("tag", q.Tag)
+	}
+	if q.Sort != "" && q.Sort != db.SortShortLink {
+		values.Set("sort", string(q.Sort))
+	}
+	if q.Desc {
+		values.Set("dir", "desc")
+	}
+	if q.After != "" {
+		values.Set("after", q.After)
+	}
+	if len(values) == 0 {
+		return path
+	}
+	return path + "?" + values.Encode()
+}
+
 func (h *Handler) LinksFrame(req *foundation.Request) (html.Block, error) {
+	query := linksQuery(req.Request)
+	// dialog is set if a submitted form has to be shown again with errors
+	var dialog html.Block
 	switch req.Request.Method {
 	case http.MethodPost:
-		err := h.postNewLink(req)
+		f, errs, err := h.postNewLink(req)
 		if err != nil {
 			return nil, errors.Wrap(err, "postNewLink")
 		}
-		err = h.Broadcast.Send("links")
+		if len(errs) > 0 {
+			dialog = newLinkDialog(f, errs, query)
+			break
+		}
+		err = h.linksChanged()
 		if err != nil {
-			return nil, errors.Wrap(err, "Broadcast.Send")
+			return nil, errors.Wrap(err, "linksChanged")
 		}
 	case http.MethodPatch:
-		err := h.patchLink(req)
+		f, errs, err := h.patchLink(req)
 		if err != nil {
 			return nil, errors.Wrap(err, "patchLink")
 		}
-		err = h.Broadcast.Send("links")
+		if len(errs) > 0 {
+			dialog = editLinkDialog(req.Params.ByName("short_link"), f, errs, query, nil)
+			break
+		}
+		err = h.linksChanged()
 		if err != nil {
-			return nil, errors.Wrap(err, "Broadcast.Send")
+			return nil, errors.Wrap(err, "linksChanged")
 		}
 	case http.MethodDelete:
 		err := h.deleteLink(req)
 		if err != nil {
 			return nil, errors.Wrap(err, "deleteLink")
 		}
-		err = h.Broadcast.Send("links")
+		err = h.linksChanged()
 		if err != nil {
-			return nil, errors.Wrap(err, "Broadcast.Send")
+			return nil, errors.Wrap(err, "linksChanged")
 		}
 	}
 
-	allLinks, err := h.DB.Links.AllWithVisitCounts(req.Context.Context)
+	return h.linksFrame(req, query, dialog)
+}
+
+// LinkPurgeVisitsFrame deletes all visit data of a link and renders the
+// links frame with the reset counters.
+func (h *Handler) LinkPurgeVisitsFrame(req *foundation.Request) (html.Block, error) {
+	shortLink := req.Params.ByName("short_link")
+	link, err := h.DB.Links.ByShortLink(req.Context.Context, shortLink)
 	if err != nil {
-		return nil, errors.Wrap(err, "AllWithVisitCounts")
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "Link not found", err)
+	}
+
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		err := h.DB.Visits.Purge(ctx, shortLink)
+		if err != nil {
+			return errors.Wrap(err, "Visits.Purge")
+		}
+		return h.auditChanges(ctx, req, "link.purge_visits", "link:"+shortLink, map[string]foundation.AuditChange{
+			"visits_count": {Before: link.VisitsCount, After: 0},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = h.linksChanged()
+	if err != nil {
+		return nil, errors.Wrap(err, "linksChanged")
+	}
+	return h.linksFrame(req, linksQuery(req.Request), nil)
+}
+
+// linksFrame renders the links frame with an optional dialog. A dialog
+// means that a submitted form is shown again with errors.
+func (h *Handler) linksFrame(req *foundation.Request, query db.LinkQuery, dialog html.Block) (html.Block, error) {
+	table, err := h.linksTable(req, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "linksTable")
+	}
+	tags, err := h.DB.Tags.All(req.Context.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "Tags.All")
+	}
+
+	if dialog == nil {
+		dialog = html.Elem("turbo-frame", attr.Id("link-dialog-frame"))
+	} else {
+		req.Writer.WriteHeader(http.StatusUnprocessableEntity)
 	}
 
 	return html.Elem("turbo-frame", attr.Id("links-frame"),
@@ -75,208 +183,538 @@ func (h *Handler) LinksFrame(req *foundation.Request) (html.Block, error) {
 			html.Main(attr.Class("mx-auto relative w-full max-w-screen-lg gap-10"),
 				html.Div(attr.Class("flex justify-between items-center mb-4"),
 					html.H2(attr.Class("text-2xl font-bold"), html.Text("Short Links")),
-					newLinkForm(),
+					html.Div(attr.Class("flex gap-2"),
+						html.A(attr.Href(linksURL("/admin/export/links.csv", db.LinkQuery{Tag: query.Tag})).Class("btn-ghost").Attr("data-turbo", "false"),
+							html.Text("Export CSV"),
+						),
+						html.A(attr.Href(linksURL("/admin/export/links.json", db.LinkQuery{Tag: query.Tag})).Class("btn-ghost").Attr("data-turbo", "false"),
+							html.Text("Export JSON"),
+						),
+						html.A(attr.Href("/admin/import/links").Class("btn-outline").Attr("data-turbo-frame", "_top"),
+							html.Text("Import"),
+						),
+						newLinkForm(query),
+					),
 				),
-				linksTable(allLinks),
+				linksSearchForm(query, tags),
+				table,
 			),
 		),
-		html.Elem("turbo-frame", attr.Id("link-dialog-frame")),
+		html.Elem("turbo-stream-source", attr.Src(linksURL("/admin/stream/links", query))),
+		dialog,
 	), nil
 }
 
-func (h *Handler) postNewLink(req *foundation.Request) error {
-	r := req.Request
-	err := r.ParseForm()
+// linkForm holds the fields of the new and edit link dialogs.
+type linkForm struct {
+	// ShortLink is the name of the link on Domain, see foundation.LinkKey.
+	ShortLink string `form:"short_link" validate:"required,max=100" pattern:"^[A-Za-z0-9_-]+$" message:"Use only letters, digits, - and _."`
+	FullURL   string `form:"full_url" validate:"required,max=2048,url"`
+	NoTrack   bool   `form:"no_track"`
+	Domain    string `form:"domain"`
+	// Tags is a comma separated list of tag names.
+	Tags string `form:"tags" validate:"max=1000"`
+	// Rules are the targeting rules, one per line, see db.ParseLinkRules.
+	Rules string `form:"rules" validate:"max=10000"`
+
+	RedirectStatus int    `form:"redirect_status"`
+	ForwardQuery   bool   `form:"forward_query"`
+	ForwardPath    bool   `form:"forward_path"`
+	UTMSource      string `form:"utm_source" validate:"max=200"`
+	UTMCampaign    string `form:"utm_campaign" validate:"max=200"`
+	Preview        bool   `form:"preview"`
+
+	Access string `form:"access"`
+	// Password is a new link password, empty keeps the current one.
+	Password string `form:"password" validate:"max=1024"`
+
+	// domains are the configured domains that can be chosen.
+	domains []string
+	// hasPassword is set if the link already has a password.
+	hasPassword bool
+	// access are the access options with the hashed password, which are
+	// only set if the form is valid.
+	access foundation.AccessOptions
+}
+
+// key is the short link that the link is stored as.
+func (f *linkForm) key() string {
+	return foundation.LinkKey(f.Domain, f.ShortLink)
+}
+
+// redirectOptions returns the redirect options of the form.
+func (f *linkForm) redirectOptions() foundation.RedirectOptions {
+	return foundation.RedirectOptions{
+		RedirectStatus: f.RedirectStatus,
+		ForwardQuery:   f.ForwardQuery,
+		ForwardPath:    f.ForwardPath,
+		UTMSource:      f.UTMSource,
+		UTMCampaign:    f.UTMCampaign,
+		Preview:        f.Preview,
+	}
+}
+
+// passwordChanged reports whether a new link password was set.
+func (f *linkForm) passwordChanged() bool {
+	return f.Password != "" && f.access.Access == foundation.AccessPassword
+}
+
+// decodeLinkForm decodes and validates the link dialog form. current are
+// the access options of the link before the change, which keep its
+// password if no new one is entered.
+func decodeLinkForm(req *foundation.Request, current foundation.AccessOptions) (*linkForm, []string, []*foundation.LinkRule, form.Errors, error) {
+	var f linkForm
+	errs, err := form.Decode(req.Request, &f)
 	if err != nil {
-		http.Error(req.Writer, "Failed to parse form", http.StatusBadRequest)
-		return errors.Wrap(err, "ParseForm")
+		return nil, nil, nil, nil, foundation.NewHTTPError(http.StatusBadRequest, "Failed to parse form", err)
+	}
+	f.domains = req.Current().DomainNames()
+	if f.Domain != "" && !slices.Contains(f.domains, f.Domain) {
+		errs.Add("domain", "Please choose a configured domain.")
+	}
+	tags := db.ParseTags(f.Tags)
+	err = db.CheckTags(tags)
+	if err != nil {
+		errs.Add("tags", fmt.Sprintf("Invalid tags: %s.", err))
+	}
+	if !slices.Contains(foundation.RedirectStatuses, f.RedirectStatus) && errs.Get("redirect_status") == "" {
+		errs.Add("redirect_status", "Please choose a redirect type.")
+	}
+	rules, err := db.ParseLinkRules(f.Rules)
+	if err != nil && errs.Get("rules") == "" {
+		errs.Add("rules", fmt.Sprintf("Invalid rules: %s.", err))
+	}
+	f.hasPassword = current.PasswordHash != ""
+	access := foundation.LinkAccess(f.Access)
+	if !slices.Contains(foundation.LinkAccessModes, access) {
+		errs.Add("access", "Please choose who can visit the link.")
+	}
+	if access == foundation.AccessPassword && f.Password == "" && !f.hasPassword {
+		errs.Add("password", "Please set a password.")
+	}
+	if len(errs) > 0 {
+		return &f, tags, rules, errs, nil
 	}
 
-	shortLink := r.FormValue("short_link")
-	fullURL := r.FormValue("full_url")
+	f.access = foundation.AccessOptions{Access: access}
+	if access == foundation.AccessPassword {
+		f.access.PasswordHash = current.PasswordHash
+		if f.Password != "" {
+			f.access.PasswordHash, err = auth.HashPassword(f.Password)
+			if err != nil {
+				return nil, nil, nil, nil, errors.Wrap(err, "HashPassword")
+			}
+		}
+	}
+	return &f, tags, rules, errs, nil
+}
+
+func (h *Handler) postNewLink(req *foundation.Request) (*linkForm, form.Errors, error) {
+	f, tags, rules, errs, err := decodeLinkForm(req, foundation.AccessOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(errs) > 0 {
+		return f, errs, nil
+	}
 
-	_, err = h.DB.Links.ByShortLink(req.Context.Context, shortLink)
+	_, err = h.DB.Links.ByShortLink(req.Context.Context, f.key())
 	if err == nil {
-		http.Error(req.Writer, fmt.Sprintf("Short link %q already exists", shortLink), http.StatusConflict)
-		return errors.New("short link exists")
+		errs.Add("short_link", fmt.Sprintf("Short link %q already exists.", f.key()))
+		return f, errs, nil
+	}
+	inTrash, err := h.DB.Links.InTrash(req.Context.Context, f.key())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "InTrash")
+	}
+	if inTrash {
+		errs.Add("short_link", fmt.Sprintf("Short link %q is in the trash, restore or purge it first.", f.key()))
+		return f, errs, nil
 	}
 
 	if req.User == nil {
-		http.Error(req.Writer, "User must be logged in", http.StatusUnauthorized)
-		return errors.New("not logged in")
+		return nil, nil, foundation.NewHTTPError(http.StatusUnauthorized, "User must be logged in", nil)
 	}
 
 	link := &foundation.Link{
-		ShortLink: shortLink,
-		FullURL:   fullURL,
+		ShortLink: f.key(),
+		FullURL:   f.FullURL,
+		NoTrack:   f.NoTrack,
 		UserID:    req.User.ID,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-	}
-	err = h.DB.Links.Insert(req.Context, link)
-	if err != nil {
-		return errors.Wrap(err, "Insert link")
-	}
 
-	err = h.Broadcast.Send("links")
+		RedirectOptions: f.redirectOptions(),
+		AccessOptions:   f.access,
+	}
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		err := h.DB.Links.Insert(ctx, link)
+		if err != nil {
+			return errors.Wrap(err, "Insert link")
+		}
+		err = h.DB.Tags.Set(ctx, link.ShortLink, tags)
+		if err != nil {
+			return errors.Wrap(err, "Tags.Set")
+		}
+		err = h.DB.Rules.Set(ctx, link.ShortLink, rules)
+		if err != nil {
+			return errors.Wrap(err, "Rules.Set")
+		}
+		err = h.recordRevision(ctx, req, db.NewLinkRevision(link, tags, db.FormatLinkRules(rules), "create"))
+		if err != nil {
+			return err
+		}
+		return h.audit(ctx, req, "link.create", "link:"+link.ShortLink, nil, linkAuditOf(link, tags, rules))
+	})
 	if err != nil {
-		return errors.Wrap(err, "Broadcast.Send")
+		return nil, nil, err
 	}
 
-	return nil
+	return nil, nil, nil
 }
 
-func (h *Handler) patchLink(req *foundation.Request) error {
-	r := req.Request
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(req.Writer, "Failed to parse form", http.StatusBadRequest)
-		return errors.Wrap(err, "ParseForm")
-	}
-
+func (h *Handler) patchLink(req *foundation.Request) (*linkForm, form.Errors, error) {
 	oldShortLink := req.Params.ByName("short_link")
 	if oldShortLink == "" {
-		http.Error(req.Writer, "Short link is required", http.StatusBadRequest)
-		return errors.New("missing short link")
+		return nil, nil, foundation.NewHTTPError(http.StatusBadRequest, "Short link is required", nil)
 	}
 
 	existingLink, err := h.DB.Links.ByShortLink(req.Context.Context, oldShortLink)
 	if err != nil {
-		http.Error(req.Writer, "Link not found", http.StatusNotFound)
-		return errors.Wrap(err, "link not found")
+		return nil, nil, foundation.NewHTTPError(http.StatusNotFound, "Link not found", err)
 	}
+	err = h.DB.Links.LoadTags(req.Context.Context, existingLink)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "LoadTags")
+	}
+	existingRules, err := h.DB.Rules.ByShortLink(req.Context.Context, oldShortLink)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Rules.ByShortLink")
+	}
+	before := linkAuditOf(existingLink, db.TagNames(existingLink.Tags), existingRules)
 
-	newShortLink := r.FormValue("short_link")
-	newFullURL := r.FormValue("full_url")
+	f, tags, rules, errs, err := decodeLinkForm(req, existingLink.AccessOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(errs) > 0 {
+		return f, errs, nil
+	}
 
-	if newShortLink == oldShortLink {
+	if f.key() == oldShortLink {
 		// Just update the URL
-		existingLink.FullURL = newFullURL
+		existingLink.FullURL = f.FullURL
+		existingLink.NoTrack = f.NoTrack
+		existingLink.RedirectOptions = f.redirectOptions()
+		existingLink.AccessOptions = f.access
 		existingLink.UpdatedAt = time.Now()
-		err = h.DB.Links.Update(req.Context, existingLink)
+		err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+			err := h.DB.Links.Update(ctx, existingLink)
+			if err != nil {
+				return errors.Wrap(err, "Update link")
+			}
+			err = h.DB.Tags.Set(ctx, existingLink.ShortLink, tags)
+			if err != nil {
+				return errors.Wrap(err, "Tags.Set")
+			}
+			err = h.DB.Rules.Set(ctx, existingLink.ShortLink, rules)
+			if err != nil {
+				return errors.Wrap(err, "Rules.Set")
+			}
+			after := linkAuditOf(existingLink, tags, rules)
+			if f.passwordChanged() {
+				after.Password = "(changed)"
+			}
+			if len(db.AuditDiff(before, after)) == 0 {
+				// nothing changed, so there is no new revision
+				return nil
+			}
+			err = h.recordRevision(ctx, req, db.NewLinkRevision(existingLink, tags, after.Rules, "update"))
+			if err != nil {
+				return err
+			}
+			return h.audit(ctx, req, "link.update", "link:"+oldShortLink, before, after)
+		})
 		if err != nil {
-			return errors.Wrap(err, "Update link")
+			return nil, nil, err
 		}
-		err = h.Broadcast.Send("links")
+		return nil, nil, nil
+	}
+
+	// Check if new short link already exists
+	_, err = h.DB.Links.ByShortLink(req.Context.Context, f.key())
+	if err == nil {
+		errs.Add("short_link", fmt.Sprintf("Short link %q already exists.", f.key()))
+		return f, errs, nil
+	}
+	inTrash, err := h.DB.Links.InTrash(req.Context.Context, f.key())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "InTrash")
+	}
+	if inTrash {
+		errs.Add("short_link", fmt.Sprintf("Short link %q is in the trash, restore or purge it first.", f.key()))
+		return f, errs, nil
+	}
+
+	newLink := &foundation.Link{
+		ShortLink: f.key(),
+		FullURL:   f.FullURL,
+		NoTrack:   f.NoTrack,
+		UserID:    existingLink.UserID,
+		CreatedAt: existingLink.CreatedAt,
+		UpdatedAt: time.Now(),
+
+		VisitsCount:    existingLink.VisitsCount,
+		LastVisitedAt:  existingLink.LastVisitedAt,
+		VisitsPurgedAt: existingLink.VisitsPurgedAt,
+
+		RedirectOptions: f.redirectOptions(),
+		AccessOptions:   f.access,
+	}
+	// Replace the link, keeping its visits and history
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		err := h.DB.Revisions.Move(ctx, oldShortLink, newLink.ShortLink)
 		if err != nil {
-			return errors.Wrap(err, "Broadcast.Send")
-		}
-	} else {
-		// Check if new short link already exists
-		_, err := h.DB.Links.ByShortLink(req.Context.Context, newShortLink)
-		if err == nil {
-			http.Error(req.Writer, fmt.Sprintf("Short link %q already exists", newShortLink), http.StatusConflict)
-			return errors.New("short link exists")
+			return errors.Wrap(err, "Revisions.Move")
 		}
-
-		// Replace the link: delete old, insert new
-		err = h.DB.Links.Delete(req.Context.Context, oldShortLink)
+		err = h.DB.Links.Rename(ctx, oldShortLink, newLink)
 		if err != nil {
-			return errors.Wrap(err, "Delete old link")
+			return errors.Wrap(err, "Links.Rename")
 		}
-
-		newLink := &foundation.Link{
-			ShortLink: newShortLink,
-			FullURL:   newFullURL,
-			UserID:    existingLink.UserID,
-			CreatedAt: existingLink.CreatedAt,
-			UpdatedAt: time.Now(),
+		err = h.DB.Tags.Set(ctx, newLink.ShortLink, tags)
+		if err != nil {
+			return errors.Wrap(err, "Tags.Set")
 		}
-		err = h.DB.Links.Insert(req.Context, newLink)
+		err = h.DB.Rules.Set(ctx, newLink.ShortLink, rules)
 		if err != nil {
-			return errors.Wrap(err, "Insert new link")
+			return errors.Wrap(err, "Rules.Set")
 		}
-
-		err = h.Broadcast.Send("links")
+		rev := db.NewLinkRevision(newLink, tags, db.FormatLinkRules(rules), "rename")
+		rev.RenamedFrom = oldShortLink
+		err = h.recordRevision(ctx, req, rev)
 		if err != nil {
-			return errors.Wrap(err, "Broadcast.Send")
+			return err
 		}
+		after := linkAuditOf(newLink, tags, rules)
+		if f.passwordChanged() {
+			after.Password = "(changed)"
+		}
+		return h.audit(ctx, req, "link.update", "link:"+oldShortLink, before, after)
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
+	return nil, nil, nil
 }
 
 func (h *Handler) deleteLink(req *foundation.Request) error {
 	shortLink := req.Params.ByName("short_link")
 	if shortLink == "" {
-		http.Error(req.Writer, "Short link is required", http.StatusBadRequest)
-		return errors.New("missing short link")
+		return foundation.NewHTTPError(http.StatusBadRequest, "Short link is required", nil)
 	}
 
-	_, err := h.DB.Links.ByShortLink(req.Context.Context, shortLink)
+	link, err := h.DB.Links.ByShortLink(req.Context.Context, shortLink)
 	if err != nil {
-		http.Error(req.Writer, "Link not found", http.StatusNotFound)
-		return errors.Wrap(err, "link not found")
+		return foundation.NewHTTPError(http.StatusNotFound, "Link not found", err)
 	}
-
-	err = h.DB.Links.Delete(req.Context.Context, shortLink)
+	err = h.DB.Links.LoadTags(req.Context.Context, link)
 	if err != nil {
-		return errors.Wrap(err, "Delete link")
+		return errors.Wrap(err, "LoadTags")
 	}
-
-	err = h.Broadcast.Send("links")
+	rules, err := h.DB.Rules.ByShortLink(req.Context.Context, shortLink)
 	if err != nil {
-		return errors.Wrap(err, "Broadcast.Send")
+		return errors.Wrap(err, "Rules.ByShortLink")
 	}
-	return nil
+
+	return h.DB.InTx(req.Context, func(ctx context.Context) error {
+		err := h.DB.Links.Delete(ctx, shortLink)
+		if err != nil {
+			return errors.Wrap(err, "Delete link")
+		}
+		return h.audit(ctx, req, "link.delete", "link:"+shortLink, linkAuditOf(link, db.TagNames(link.Tags), rules), nil)
+	})
 }
 
-func linksTable(links []*foundation.Link) html.Block {
+// linksTable renders the page of links selected by q. It is replaced on
+// its own by LinksStream, so that open dialogs and the search input are
+// not reset by live updates.
+func (h *Handler) linksTable(req *foundation.Request, q db.LinkQuery) (html.Block, error) {
+	page, err := h.DB.Links.Page(req.Context.Context, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "Links.Page")
+	}
+
 	var rows html.Blocks
-	for _, l := range links {
-		rows.Add(linkTableRow(l))
+	for _, l := range page.Links {
+		rows.Add(linkTableRow(l, q))
+	}
+	if len(page.Links) == 0 {
+		rows.Add(html.Tr(nil,
+			html.Td(attr.Attr("colspan", "8").Class("text-center text-muted-foreground"),
+				html.Text("No links found."),
+			),
+		))
 	}
 
-	return html.Div(attr.Class("overflow-x-auto w-full"),
-		html.Table(attr.Class("table"),
-			html.Thead(nil,
-				html.Tr(nil,
-					html.Th(nil,
-						html.Text("Short Link"),
-					),
-					html.Th(nil,
-						html.Text("Full URL"),
-					),
-					html.Th(nil,
-						html.Text("User"),
-					),
-					html.Th(nil,
-						html.Text("Visits"),
-					),
-					html.Th(nil,
-						html.Text("Created"),
-					),
-					html.Th(nil,
-						html.Text("Updated"),
-					),
-					html.Th(nil,
-						html.Text("Actions"),
+	return html.Div(attr.Id("links-table"),
+		html.Div(attr.Class("overflow-x-auto w-full"),
+			html.Table(attr.Class("table"),
+				html.Thead(nil,
+					html.Tr(nil,
+						sortHeader("Short Link", db.SortShortLink, q),
+						html.Th(nil,
+							html.Text("Full URL"),
+						),
+						html.Th(nil,
+							html.Text("Tags"),
+						),
+						sortHeader("User", db.SortOwner, q),
+						sortHeader("Visits", db.SortVisits, q),
+						sortHeader("Created", db.SortCreated, q),
+						sortHeader("Updated", db.SortUpdated, q),
+						html.Th(nil,
+							html.Text("Actions"),
+						),
 					),
 				),
+				html.Tbody(nil,
+					rows,
+				),
 			),
-			html.Tbody(nil,
-				rows,
-			),
+		),
+		linksPagination(page, q),
+	), nil
+}
+
+// linksFrameLink returns the attributes of a link that navigates the links
+// frame and updates the browser URL.
+func linksFrameLink(href string) attr.Attributes {
+	return attr.Href(href).Attr("data-turbo-frame", "links-frame").Attr("data-turbo-action", "advance")
+}
+
+// sortHeader links to the first page sorted by sort. If the table is
+// already sorted by it, the direction is reversed.
+func sortHeader(label string, sort db.LinkSort, q db.LinkQuery) html.Block {
+	next := db.LinkQuery{Search: q.Search, Tag: q.Tag, Sort: sort}
+	indicator := ""
+	if q.Sort == sort {
+		next.Desc = !q.Desc
+		indicator = " ▲"
+		if q.Desc {
+			indicator = " ▼"
+		}
+	}
+	return html.Th(nil,
+		html.A(linksFrameLink(linksURL("/admin/links", next)),
+			html.Text(label+indicator),
 		),
 	)
 }
 
-func linkTableRow(link *foundation.Link) html.Block {
+func linksPagination(page *db.LinkPage, q db.LinkQuery) html.Block {
+	first := q
+	first.After = ""
+	next := q
+	next.After = page.Next
+
+	var links html.Blocks
+	if q.After != "" {
+		links.Add(html.A(linksFrameLink(linksURL("/admin/links", first)).Class("btn-sm-outline"),
+			html.Text("First page"),
+		))
+	}
+	if page.Next != "" {
+		links.Add(html.A(linksFrameLink(linksURL("/admin/links", next)).Class("btn-sm-outline"),
+			html.Text("Next page"),
+		))
+	}
+
+	return html.Div(attr.Class("flex justify-between items-center mt-4"),
+		html.P(attr.Class("text-sm text-muted-foreground"),
+			html.Text(fmt.Sprintf("%d links", page.Total)),
+		),
+		html.Div(attr.Class("flex gap-2"),
+			links,
+		),
+	)
+}
+
+// linksSearchForm searches with a GET request that navigates the links
+// frame, so the search ends up in the URL like sorting and paging.
+func linksSearchForm(q db.LinkQuery, tags []*foundation.Tag) html.Block {
+	var sort html.Blocks
+	if q.Sort != db.SortShortLink {
+		sort.Add(html.Input(attr.Type("hidden").Name("sort").Value(string(q.Sort))))
+	}
+	if q.Desc {
+		sort.Add(html.Input(attr.Type("hidden").Name("dir").Value("desc")))
+	}
+	return html.Form(attr.Method("GET").Action("/admin/links").Class("flex gap-2 mb-4").Attr("role", "search").Attr("data-turbo-frame", "links-frame").Attr("data-turbo-action", "advance"),
+		html.Input(attr.Type("search").Name("q").Value(q.Search).Attr("placeholder", "Search short links and URLs").Class("input").Attr("aria-label", "Search links")),
+		tagSelect(q.Tag, tags),
+		sort,
+		html.Button(attr.Type("submit").Class("btn-outline"),
+			html.Text("Search"),
+		),
+	)
+}
+
+// tagSelect filters the links by tag as soon as another tag is selected.
+func tagSelect(selected string, tags []*foundation.Tag) html.Block {
+	options := html.Blocks{
+		html.Option(attr.Value(""), html.Text("All tags")),
+	}
+	for _, tag := range tags {
+		option := attr.Value(tag.Name)
+		if strings.EqualFold(tag.Name, selected) {
+			option = option.Attr("selected", "")
+		}
+		options.Add(html.Option(option, html.Text(fmt.Sprintf("%s (%d)", tag.Name, tag.LinksCount))))
+	}
+	return html.Select(attr.Name("tag").Class("select").Attr("aria-label", "Filter by tag").Attr("onchange", "this.form.requestSubmit()"),
+		options,
+	)
+}
+
+// tagChips links each tag to the links table filtered by it.
+func tagChips(tags []*foundation.Tag, q db.LinkQuery) html.Block {
+	var chips html.Blocks
+	for _, tag := range tags {
+		filter := db.LinkQuery{Search: q.Search, Sort: q.Sort, Desc: q.Desc, Tag: tag.Name}
+		chips.Add(html.A(linksFrameLink(linksURL("/admin/links", filter)).Class("badge-secondary"),
+			html.Text(tag.Name),
+		))
+	}
+	return html.Div(attr.Class("flex flex-wrap gap-1"),
+		chips,
+	)
+}
+
+func linkTableRow(link *foundation.Link, q db.LinkQuery) html.Block {
 	displayName := "Unknown"
 	if link.User != nil {
 		displayName = link.User.DisplayName
 	}
+	lastVisit := "Never visited"
+	if !link.LastVisitedAt.IsZero() {
+		lastVisit = "Last visit " + link.LastVisitedAt.Format("2006-01-02 15:04")
+	}
 	return html.Tr(nil,
 		html.Td(attr.Class("font-medium"),
-			html.A(attr.Href(fmt.Sprintf("/%s", link.ShortLink)), html.Text(link.ShortLink)),
+			html.A(attr.Href(foundation.ShortLinkHref(link.ShortLink)), html.Text(link.Name())),
+			domainLabel(link.Domain()),
 		),
 		html.Td(nil,
 			html.A(attr.Href(link.FullURL), html.Text(link.FullURL)),
 		),
+		html.Td(nil,
+			tagChips(link.Tags, q),
+		),
 		html.Td(nil,
 			html.Text(displayName),
 		),
-		html.Td(attr.Class("text-right"),
+		html.Td(attr.Class("text-right").Attr("title", lastVisit),
 			html.Text(fmt.Sprint(link.VisitsCount)),
 		),
 		html.Td(attr.Class("text-right"),
@@ -286,22 +724,41 @@ func linkTableRow(link *foundation.Link) html.Block {
 			html.Text(link.UpdatedAt.Format("2006-01-02 15:04")),
 		),
 		html.Td(nil,
-			html.A(attr.Href(fmt.Sprintf("/admin/frame/links/update/%s", link.ShortLink)).Class("btn-ghost").Attr("data-turbo-frame", "link-dialog-frame"),
+			html.A(attr.Href(linksURL(fmt.Sprintf("/admin/frame/links/update/%s", link.ShortLink), q)).Class("btn-ghost").Attr("data-turbo-frame", "link-dialog-frame"),
 				html.Text("Edit"),
 			),
 		),
 	)
 }
 
-func newLinkForm() html.Block {
+// domainLabel shows the domain of a link that is not on the default domain.
+func domainLabel(domain string) html.Block {
+	if domain == "" {
+		return nil
+	}
+	return html.Span(attr.Class("block text-xs text-muted-foreground"), html.Text(domain))
+}
+
+func newLinkForm(q db.LinkQuery) html.Block {
 	return html.Blocks{
-		html.A(attr.Href("/admin/frame/links/new").Class("btn-outline").Attr("data-turbo-frame", "link-dialog-frame"),
+		html.A(attr.Href(linksURL("/admin/frame/links/new", q)).Class("btn-outline").Attr("data-turbo-frame", "link-dialog-frame"),
 			html.Text("Add Link"),
 		),
 	}
 }
 
 func (h *Handler) LinkNewFrame(req *foundation.Request) (html.Block, error) {
+	f := &linkForm{
+		RedirectStatus: http.StatusFound,
+		Access:         string(foundation.AccessPublic),
+		domains:        req.Current().DomainNames(),
+	}
+	return newLinkDialog(f, nil, linksQuery(req.Request)), nil
+}
+
+// newLinkDialog keeps the table state q in the form action, so that the
+// links frame is rendered with the same page after submitting.
+func newLinkDialog(f *linkForm, errs form.Errors, q db.LinkQuery) html.Block {
 	return html.Elem("turbo-frame", attr.Id("link-dialog-frame"),
 		html.Dialog(attr.Id("new-link-dialog").Class("dialog w-full sm:max-w-[425px] max-h-[612px]").Attr("aria-labelledby", "new-link-dialog-title").Attr("aria-describedby", "new-link-dialog-description").Attr("onclick", "if (event.target === this) this.close()"),
 			html.Article(nil,
@@ -314,19 +771,25 @@ func (h *Handler) LinkNewFrame(req *foundation.Request) (html.Block, error) {
 					),
 				),
 				html.Section(nil,
-					html.Form(attr.Method("POST").Action("/admin/links").Class("form grid gap-4").Attr("data-turbo-frame", "links-frame"),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For("short-link"),
-								html.Text("Short Link"),
-							),
-							html.Input(attr.Type("text").Name("short_link").Id("short-link").Required("").Autofocus("")),
-						),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For("full-url"),
-								html.Text("Full URL"),
-							),
-							html.Input(attr.Type("url").Name("full_url").Id("full-url").Required("")),
-						),
+					html.Form(attr.Method("POST").Action(linksURL("/admin/links", q)).Class("form grid gap-4").Attr("data-turbo-frame", "links-frame"),
+						domainField("domain", f, errs),
+						components.Field{
+							Id:    "short-link",
+							Label: "Short Link",
+							Input: attr.Type("text").Name("short_link").Value(f.ShortLink).Required("").Autofocus(""),
+							Error: errs.Get("short_link"),
+						},
+						components.Field{
+							Id:    "full-url",
+							Label: "Full URL",
+							Input: attr.Type("url").Name("full_url").Value(f.FullURL).Required(""),
+							Error: errs.Get("full_url"),
+						},
+						tagsField("tags", f, errs),
+						rulesField("rules", f, errs),
+						redirectFields("redirect", f, errs),
+						accessFields("access", f, errs),
+						noTrackCheckbox("no-track", f.NoTrack),
 						html.Div(attr.Class("flex justify-end gap-2 mt-4"),
 							html.Button(attr.Type("button").Class("btn-outline").Attr("onclick", "this.closest('dialog').close()"),
 								html.Text("Cancel"),
@@ -346,45 +809,311 @@ func (h *Handler) LinkNewFrame(req *foundation.Request) (html.Block, error) {
 			),
 		),
 		html.Script(nil, html.JS("document.getElementById('new-link-dialog').showModal();")),
-	), nil
+	)
+}
+
+func tagsField(id string, f *linkForm, errs form.Errors) html.Block {
+	return components.Field{
+		Id:    id,
+		Label: "Tags",
+		Input: attr.Type("text").Name("tags").Value(f.Tags).Attr("placeholder", "campaign, team"),
+		Error: errs.Get("tags"),
+	}
+}
+
+// domainField selects the domain of a link. It is only shown if domains
+// are configured.
+func domainField(id string, f *linkForm, errs form.Errors) html.Block {
+	if len(f.domains) == 0 && f.Domain == "" {
+		return nil
+	}
+	options := html.Blocks{
+		html.Option(attr.Value(""), html.Text("Default domain")),
+	}
+	domains := f.domains
+	if f.Domain != "" && !slices.Contains(domains, f.Domain) {
+		// keep the domain of a link whose domain is no longer configured
+		domains = append([]string{f.Domain}, domains...)
+	}
+	for _, domain := range domains {
+		option := attr.Value(domain)
+		if domain == f.Domain {
+			option = option.Attr("selected", "")
+		}
+		options.Add(html.Option(option, html.Text(domain)))
+	}
+	var errBlock html.Block
+	if message := errs.Get("domain"); message != "" {
+		errBlock = html.P(attr.Class("text-destructive text-sm"), html.Text(message))
+	}
+	return html.Div(attr.Class("grid gap-3"),
+		html.Label(attr.For(id),
+			html.Text("Domain"),
+		),
+		html.Select(attr.Id(id).Name("domain").Class("select"),
+			options,
+		),
+		errBlock,
+	)
+}
+
+// rulesField is a textarea for the targeting rules of a link, which are
+// explained below it.
+func rulesField(id string, f *linkForm, errs form.Errors) html.Block {
+	textarea := attr.Id(id).Name("rules").Class("textarea font-mono text-xs").Attr("rows", "3").
+		Attr("placeholder", "de language de https://example.com/de").Attr("spellcheck", "false")
+	var errBlock html.Block
+	if message := errs.Get("rules"); message != "" {
+		textarea = textarea.Attr("aria-invalid", "true").Attr("aria-describedby", id+"-error")
+		errBlock = html.P(attr.Id(id+"-error").Class("text-destructive text-sm"),
+			html.Text(message),
+		)
+	}
+	return html.Div(attr.Class("grid gap-3"),
+		html.Label(attr.For(id),
+			html.Text("Targeting Rules"),
+		),
+		html.Elem("textarea", textarea, html.Text(f.Rules)),
+		html.P(attr.Class("text-muted-foreground text-xs"),
+			html.Text("One rule per line: variant kind value url. Kinds are language (de,fr-CH), platform (ios, android, windows, macos, linux, mobile, desktop), query (key=value), time (start/end in RFC 3339) and split (percentage). The first matching rule wins, split rules come last."),
+		),
+		errBlock,
+	)
+}
+
+// redirectFields are the redirect options of a link. They are collapsed
+// unless one of them is invalid.
+func redirectFields(id string, f *linkForm, errs form.Errors) html.Block {
+	var options html.Blocks
+	for _, status := range foundation.RedirectStatuses {
+		option := attr.Value(status)
+		if status == f.RedirectStatus {
+			option = option.Attr("selected", "")
+		}
+		options.Add(html.Option(option,
+			html.Text(fmt.Sprintf("%d %s", status, http.StatusText(status))),
+		))
+	}
+	var statusError html.Block
+	if message := errs.Get("redirect_status"); message != "" {
+		statusError = html.P(attr.Class("text-destructive text-sm"), html.Text(message))
+	}
+
+	details := attr.Class("grid gap-4")
+	for _, field := range []string{"redirect_status", "utm_source", "utm_campaign"} {
+		if errs.Get(field) != "" {
+			details = details.Attr("open", "")
+		}
+	}
+	return html.Elem("details", details,
+		html.Elem("summary", attr.Class("cursor-pointer font-medium text-sm"),
+			html.Text("Redirect Options"),
+		),
+		html.Div(attr.Class("grid gap-3"),
+			html.Label(attr.For(id+"-status"),
+				html.Text("Redirect Type"),
+			),
+			html.Select(attr.Id(id+"-status").Name("redirect_status").Class("select"),
+				options,
+			),
+			statusError,
+		),
+		checkbox(id+"-forward-query", "forward_query", "Forward query parameters", f.ForwardQuery),
+		checkbox(id+"-forward-path", "forward_path", "Forward the path after the short link", f.ForwardPath),
+		checkbox(id+"-preview", "preview", "Show a preview page before redirecting", f.Preview),
+		components.Field{
+			Id:    id + "-utm-source",
+			Label: "UTM Source",
+			Input: attr.Type("text").Name("utm_source").Value(f.UTMSource).Attr("placeholder", "newsletter"),
+			Error: errs.Get("utm_source"),
+		},
+		components.Field{
+			Id:    id + "-utm-campaign",
+			Label: "UTM Campaign",
+			Input: attr.Type("text").Name("utm_campaign").Value(f.UTMCampaign).Attr("placeholder", "{short_link}-{variant}"),
+			Error: errs.Get("utm_campaign"),
+		},
+	)
+}
+
+// accessLabels describe the access modes in the link dialogs.
+var accessLabels = map[foundation.LinkAccess]string{
+	foundation.AccessPublic:   "Everyone",
+	foundation.AccessLogin:    "Logged in users",
+	foundation.AccessPassword: "Everyone with the link password",
+}
+
+// accessFields select who can visit a link, with the password of password
+// protected links.
+func accessFields(id string, f *linkForm, errs form.Errors) html.Block {
+	var options html.Blocks
+	for _, mode := range foundation.LinkAccessModes {
+		option := attr.Value(string(mode))
+		if string(mode) == f.Access {
+			option = option.Attr("selected", "")
+		}
+		options.Add(html.Option(option, html.Text(accessLabels[mode])))
+	}
+	var accessError html.Block
+	if message := errs.Get("access"); message != "" {
+		accessError = html.P(attr.Class("text-destructive text-sm"), html.Text(message))
+	}
+	placeholder := "Only for password protected links"
+	if f.hasPassword {
+		placeholder = "Leave empty to keep the current password"
+	}
+	return html.Blocks{
+		html.Div(attr.Class("grid gap-3"),
+			html.Label(attr.For(id),
+				html.Text("Who Can Visit"),
+			),
+			html.Select(attr.Id(id).Name("access").Class("select"),
+				options,
+			),
+			accessError,
+		),
+		components.Field{
+			Id:    id + "-password",
+			Label: "Link Password",
+			Input: attr.Type("password").Name("password").Attr("autocomplete", "new-password").Attr("placeholder", placeholder),
+			Error: errs.Get("password"),
+		},
+	}
+}
+
+func noTrackCheckbox(id string, checked bool) html.Block {
+	return checkbox(id, "no_track", "Don't record visits", checked)
+}
+
+func checkbox(id, name, label string, checked bool) html.Block {
+	input := attr.Type("checkbox").Id(id).Name(name).Class("input")
+	if checked {
+		input = input.Attr("checked", "")
+	}
+	return html.Label(attr.For(id).Class("label gap-3"),
+		html.Input(input),
+		html.Text(label),
+	)
 }
 
 func (h *Handler) LinkUpdateFrame(req *foundation.Request) (html.Block, error) {
 	shortLink := req.Params.ByName("short_link")
 	if shortLink == "" {
-		return nil, errors.New("short link is required")
+		return nil, foundation.NewHTTPError(http.StatusBadRequest, "Short link is required", nil)
 	}
 
 	link, err := h.DB.Links.ByShortLink(req.Context.Context, shortLink)
 	if err != nil {
-		return nil, errors.Wrap(err, "link not found")
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "Link not found", err)
 	}
+	err = h.DB.Links.LoadTags(req.Context.Context, link)
+	if err != nil {
+		return nil, errors.Wrap(err, "Links.LoadTags")
+	}
+
+	rules, err := h.DB.Rules.ByShortLink(req.Context.Context, link.ShortLink)
+	if err != nil {
+		return nil, errors.Wrap(err, "Rules.ByShortLink")
+	}
+	variants, err := h.DB.Visits.Variants(req.Context.Context, link.ShortLink)
+	if err != nil {
+		return nil, errors.Wrap(err, "Visits.Variants")
+	}
+	revisions, err := h.DB.Revisions.ByShortLink(req.Context.Context, link.ShortLink)
+	if err != nil {
+		return nil, errors.Wrap(err, "Revisions.ByShortLink")
+	}
+
+	f := &linkForm{
+		ShortLink: link.Name(),
+		Domain:    link.Domain(),
+		FullURL:   link.FullURL,
+		NoTrack:   link.NoTrack,
+		Tags:      strings.Join(db.TagNames(link.Tags), ", "),
+		Rules:     db.FormatLinkRules(rules),
+
+		RedirectStatus: link.Status(),
+		ForwardQuery:   link.ForwardQuery,
+		ForwardPath:    link.ForwardPath,
+		UTMSource:      link.UTMSource,
+		UTMCampaign:    link.UTMCampaign,
+		Preview:        link.Preview,
+
+		Access: string(link.Mode()),
+
+		domains:     req.Current().DomainNames(),
+		hasPassword: link.PasswordHash != "",
+	}
+	query := linksQuery(req.Request)
+	history := html.Blocks{
+		linkQRPreview(req, link.ShortLink),
+		variantVisits(variants),
+		linkHistory(link.ShortLink, revisions, query),
+	}
+	return editLinkDialog(link.ShortLink, f, nil, query, history), nil
+}
+
+// variantVisits shows how the recorded visits of a link were split between
+// its targeting rules. It is empty if no visit was sent to a rule.
+func variantVisits(counts []*db.VariantCount) html.Block {
+	targeted := false
+	var items html.Blocks
+	for _, count := range counts {
+		name := count.Variant
+		if name == "" {
+			name = "default"
+		} else {
+			targeted = true
+		}
+		items.Add(html.Li(attr.Class("flex justify-between py-1 text-sm"),
+			html.Span(attr.Class("font-mono"), html.Text(name)),
+			html.Span(attr.Class("text-muted-foreground"), html.Text(fmt.Sprintf("%d visits", count.Visits))),
+		))
+	}
+	if !targeted {
+		return nil
+	}
+	return html.Div(attr.Class("mt-6 pt-4 border-t"),
+		html.P(attr.Class("font-medium"), html.Text("Visits by Variant")),
+		html.Ul(nil, items),
+	)
+}
 
+// editLinkDialog shows the edit dialog for the link that is currently
+// stored as shortLink, with the values from f. history is only shown if
+// it is not nil.
+func editLinkDialog(shortLink string, f *linkForm, errs form.Errors, q db.LinkQuery, history html.Block) html.Block {
 	return html.Elem("turbo-frame", attr.Id("link-dialog-frame"),
-		html.Dialog(attr.Id(fmt.Sprintf("edit-link-dialog-%s", link.ShortLink)).Class("dialog w-full sm:max-w-[425px] max-h-[612px]").Attr("aria-labelledby", fmt.Sprintf("edit-link-dialog-title-%s", link.ShortLink)).Attr("aria-describedby", fmt.Sprintf("edit-link-dialog-description-%s", link.ShortLink)).Attr("onclick", "if (event.target === this) this.close()"),
+		html.Dialog(attr.Id(fmt.Sprintf("edit-link-dialog-%s", shortLink)).Class("dialog w-full sm:max-w-[425px] max-h-[612px]").Attr("aria-labelledby", fmt.Sprintf("edit-link-dialog-title-%s", shortLink)).Attr("aria-describedby", fmt.Sprintf("edit-link-dialog-description-%s", shortLink)).Attr("onclick", "if (event.target === this) this.close()"),
 			html.Article(nil,
 				html.Header(nil,
-					html.H2(attr.Id(fmt.Sprintf("edit-link-dialog-title-%s", link.ShortLink)),
+					html.H2(attr.Id(fmt.Sprintf("edit-link-dialog-title-%s", shortLink)),
 						html.Text("Edit Link"),
 					),
-					html.P(attr.Id(fmt.Sprintf("edit-link-dialog-description-%s", link.ShortLink)),
+					html.P(attr.Id(fmt.Sprintf("edit-link-dialog-description-%s", shortLink)),
 						html.Text("Make changes to the link details."),
 					),
 				),
 				html.Section(nil,
-					html.Form(attr.Method("PATCH").Action(fmt.Sprintf("/admin/links/%s", link.ShortLink)).Class("form grid gap-4").Attr("data-turbo-frame", "links-frame"),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For(fmt.Sprintf("edit-short-link-%s", link.ShortLink)),
-								html.Text("Short Link"),
-							),
-							html.Input(attr.Type("text").Name("short_link").Id(fmt.Sprintf("edit-short-link-%s", link.ShortLink)).Value(link.ShortLink).Required("")),
-						),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For(fmt.Sprintf("edit-full-url-%s", link.ShortLink)),
-								html.Text("Full URL"),
-							),
-							html.Input(attr.Type("url").Name("full_url").Id(fmt.Sprintf("edit-full-url-%s", link.ShortLink)).Value(link.FullURL).Required("").Autofocus("")),
-						),
+					html.Form(attr.Method("PATCH").Action(linksURL(fmt.Sprintf("/admin/links/%s", shortLink), q)).Class("form grid gap-4").Attr("data-turbo-frame", "links-frame"),
+						domainField(fmt.Sprintf("edit-domain-%s", shortLink), f, errs),
+						components.Field{
+							Id:    fmt.Sprintf("edit-short-link-%s", shortLink),
+							Label: "Short Link",
+							Input: attr.Type("text").Name("short_link").Value(f.ShortLink).Required(""),
+							Error: errs.Get("short_link"),
+						},
+						components.Field{
+							Id:    fmt.Sprintf("edit-full-url-%s", shortLink),
+							Label: "Full URL",
+							Input: attr.Type("url").Name("full_url").Value(f.FullURL).Required("").Autofocus(""),
+							Error: errs.Get("full_url"),
+						},
+						tagsField(fmt.Sprintf("edit-tags-%s", shortLink), f, errs),
+						rulesField(fmt.Sprintf("edit-rules-%s", shortLink), f, errs),
+						redirectFields(fmt.Sprintf("edit-redirect-%s", shortLink), f, errs),
+						accessFields(fmt.Sprintf("edit-access-%s", shortLink), f, errs),
+						noTrackCheckbox(fmt.Sprintf("edit-no-track-%s", shortLink), f.NoTrack),
 						html.Div(attr.Class("flex justify-end gap-2 mt-4"),
 							html.Button(attr.Type("button").Class("btn-outline").Attr("onclick", "this.closest('dialog').close()"),
 								html.Text("Cancel"),
@@ -394,6 +1123,23 @@ func (h *Handler) LinkUpdateFrame(req *foundation.Request) (html.Block, error) {
 							),
 						),
 					),
+					html.Form(attr.Method("POST").Action(linksURL(fmt.Sprintf("/admin/links/%s/purge-visits", shortLink), q)).Class("flex justify-between items-center gap-2 mt-6 pt-4 border-t").Attr("data-turbo-frame", "links-frame").Attr("data-turbo-confirm", fmt.Sprintf("Delete all visit data of %q?", shortLink)),
+						html.P(attr.Class("text-sm text-muted-foreground"),
+							html.Text("Delete all recorded visits and reset the counters."),
+						),
+						html.Button(attr.Type("submit").Class("btn-destructive"),
+							html.Text("Purge Visits"),
+						),
+					),
+					html.Form(attr.Method("DELETE").Action(linksURL(fmt.Sprintf("/admin/links/%s", shortLink), q)).Class("flex justify-between items-center gap-2 mt-4").Attr("data-turbo-frame", "links-frame"),
+						html.P(attr.Class("text-sm text-muted-foreground"),
+							html.Text("Stop redirecting and move the link to the trash."),
+						),
+						html.Button(attr.Type("submit").Class("btn-destructive"),
+							html.Text("Move to Trash"),
+						),
+					),
+					history,
 				),
 				html.Button(attr.Type("button").Attr("aria-label", "Close dialog").Attr("onclick", "this.closest('dialog').close()"),
 					html.Elem("svg", attr.Attr("xmlns", "http://www.w3.org/2000/svg").Width("24").Height("24").Attr("viewbox", "0 0 24 24").Attr("fill", "none").Attr("stroke", "currentColor").Attr("stroke-width", "2").Attr("stroke-linecap", "round").Attr("stroke-linejoin", "round").Class("lucide lucide-x-icon lucide-x"),
@@ -403,43 +1149,113 @@ func (h *Handler) LinkUpdateFrame(req *foundation.Request) (html.Block, error) {
 				),
 			),
 		),
-		html.Script(nil, html.JS(fmt.Sprintf("document.getElementById('edit-link-dialog-%s').showModal();", link.ShortLink))),
-	), nil
+		html.Script(nil, html.JS(fmt.Sprintf("document.getElementById('edit-link-dialog-%s').showModal();", shortLink))),
+	)
 }
 
 func (h *Handler) ShortLinkHandler(req *foundation.Request) (html.Block, error) {
-	path := strings.TrimPrefix(req.Request.URL.Path, "/")
+	// the rest of the path is forwarded if the link allows it
+	name, suffix, _ := strings.Cut(strings.TrimPrefix(req.Request.URL.EscapedPath(), "/"), "/")
+	// a + after the name, like /docs+, shows the preview page of the link
+	name, preview := strings.CutSuffix(name, "+")
+	config := req.Current()
+	domain := config.RequestDomain(req.Request.Host)
 
-	link, err := h.DB.Links.ByShortLink(req.Context.Context, path)
-	if err != nil || link == nil {
-		req.Writer.WriteHeader(http.StatusNotFound)
-		return html.Text("page not found"), errors.New("not found")
+	var redirect *db.Redirect
+	err := sql.ErrNoRows
+	// names with a domain would reach the links of another domain
+	if !strings.Contains(name, "@") {
+		redirect, err = h.DB.Redirects.Lookup(req.Context.Context, foundation.LinkKey(domain, name))
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && suffix != "" && !redirect.Link.ForwardPath) {
+		if fallback := config.Domains[domain]; domain != "" && fallback != "" {
+			redirectsTotal.WithLabelValues("fallback").Inc()
+			http.Redirect(req.Writer, req.Request, fallback, http.StatusFound)
+			return nil, nil
+		}
+		redirectsTotal.WithLabelValues("not_found").Inc()
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "", err)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Redirects.Lookup")
 	}
 
-	visit := &foundation.LinkVisit{
-		ShortLink: path,
-		UserID:    req.Session.UserID,
+	link := redirect.Link
+	if !canVisit(req, link) {
+		// locked links don't show their destination, not even in previews
+		redirectsTotal.WithLabelValues("locked").Inc()
+		return h.lockedLink(req, link)
+	}
+
+	target := link.FullURL
+	variant := ""
+	if rule := foundation.SelectLinkRule(redirect.Rules, req.Request, time.Now(), rand.Float64()); rule != nil {
+		target = rule.FullURL
+		variant = rule.Variant
 	}
-	err = h.DB.Visits.Insert(req.Context.Context, visit)
+
+	destination, err := link.Destination(target, link.ShortLink, variant, suffix, req.Request.URL.Query())
 	if err != nil {
-		return nil, errors.Wrap(err, "Visits.Insert")
+		return nil, errors.Wrap(err, "Destination")
 	}
 
-	err = h.Broadcast.Send("links")
+	external := config.ExternalURL(destination, req.Request.Host)
+	if preview {
+		// looking at the preview is not a visit
+		redirectsTotal.WithLabelValues("preview").Inc()
+		return h.linkPreview(req, link.ShortLink, destination, external)
+	}
+
+	err = h.recordVisit(req, link, variant)
 	if err != nil {
-		return nil, errors.Wrap(err, "Broadcast.Send")
+		return nil, errors.Wrap(err, "recordVisit")
 	}
 
-	http.Redirect(req.Writer, req.Request, link.FullURL, http.StatusFound)
+	if link.Preview || config.PreviewLinks || external {
+		redirectsTotal.WithLabelValues("preview").Inc()
+		return h.linkPreview(req, link.ShortLink, destination, external)
+	}
+
+	redirectsTotal.WithLabelValues("redirect").Inc()
+	http.Redirect(req.Writer, req.Request, destination, link.Status())
 	return nil, nil
 }
 
+// recordVisit stores a visit of link that was sent to the targeting rule
+// variant, unless tracking is disabled for the link. Visitors that send a
+// DNT or Sec-GPC header are only counted, their visit is not associated
+// with their user. IP addresses are never stored.
+func (h *Handler) recordVisit(req *foundation.Request, link *foundation.Link, variant string) error {
+	if link.NoTrack {
+		return nil
+	}
+
+	visit := &foundation.LinkVisit{
+		ShortLink: link.ShortLink,
+		Variant:   variant,
+	}
+	if !doNotTrack(req.Request) {
+		visit.UserID = req.Session.UserID
+	}
+	err := h.DB.Visits.Insert(req.Context.Context, visit)
+	if err != nil {
+		return errors.Wrap(err, "Visits.Insert")
+	}
+
+	return h.Broadcast.Send("links")
+}
+
+// doNotTrack reports whether the browser asked not to be tracked.
+func doNotTrack(r *http.Request) bool {
+	return r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1"
+}
+
 func (h *Handler) LinksStream(req *foundation.Request) (html.Block, error) {
-	frame, err := h.LinksFrame(req)
+	table, err := h.linksTable(req, linksQuery(req.Request))
 	if err != nil {
-		return nil, errors.Wrap(err, "LinksFrame")
+		return nil, errors.Wrap(err, "linksTable")
 	}
 
-	return html.Elem("turbo-stream", attr.Action("replace").Target("links-frame"),
-		html.Template(nil, frame)), nil
+	return html.Elem("turbo-stream", attr.Action("replace").Target("links-table"),
+		html.Template(nil, table)), nil
 }
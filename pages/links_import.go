@@ -0,0 +1,280 @@
+package pages
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/db"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+	"github.com/pkg/errors"
+)
+
+// maxImportSize limits the size of uploaded link files.
+const maxImportSize = 10 << 20
+
+// LinksExport downloads all links as CSV or JSON, depending on the
+// extension of the requested path. The tag query parameter only exports
+// the links with that tag.
+func (h *Handler) LinksExport(req *foundation.Request) (html.Block, error) {
+	format, err := db.FileFormatOf(req.Request.URL.Path)
+	if err != nil {
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "", err)
+	}
+
+	records, err := h.DB.Links.Export(req.Context.Context, req.Request.URL.Query().Get("tag"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Links.Export")
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == db.FormatJSON {
+		contentType = "application/json"
+	}
+	name := fmt.Sprintf("links-%s%s", time.Now().UTC().Format("20060102T150405Z"), path.Ext(req.Request.URL.Path))
+	req.Writer.Header().Set("Content-Type", contentType)
+	req.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	err = db.WriteLinkRecords(req.Writer, format, records)
+	if err != nil {
+		// the headers are already sent, so only log the error
+		log.Println("LinksExport WriteLinkRecords error:", err)
+	}
+	return nil, nil
+}
+
+func (h *Handler) LinksImportPage(req *foundation.Request) (*Page, error) {
+	importFrame, err := h.LinksImportFrame(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "LinksImportFrame")
+	}
+
+	page := &Page{
+		Title:   "Quick Links - Import Links",
+		Sidebar: Sidebar{},
+		Header: Header{
+			Title: "Import Links",
+		},
+		Body: importFrame,
+	}
+
+	return page, nil
+}
+
+// LinksImportFrame shows the upload form. An uploaded file is previewed as
+// a dry run, the preview holds the parsed records to apply them without
+// uploading the file again.
+func (h *Handler) LinksImportFrame(req *foundation.Request) (html.Block, error) {
+	var content html.Block
+	if req.Request.Method == http.MethodPost {
+		var err error
+		content, err = h.postLinksImport(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "postLinksImport")
+		}
+	}
+
+	return html.Elem("turbo-frame", attr.Id("import-frame"),
+		html.Div(attr.Class("p-4 md:p-6 xl:p-12"),
+			html.Main(attr.Class("mx-auto relative w-full max-w-screen-lg gap-10"),
+				html.Div(attr.Class("flex justify-between items-center mb-4"),
+					html.H2(attr.Class("text-2xl font-bold"), html.Text("Import Links")),
+					html.A(attr.Href("/admin/links").Class("btn-outline").Attr("data-turbo-frame", "_top"),
+						html.Text("Back to Links"),
+					),
+				),
+				html.Form(attr.Method("POST").Action("/admin/import/links").Attr("enctype", "multipart/form-data").Class("form flex items-end gap-2 mb-6").Attr("data-turbo-frame", "import-frame"),
+					html.Div(attr.Class("grid gap-3"),
+						html.Label(attr.For("import-file"),
+							html.Text("CSV or JSON file"),
+						),
+						html.Input(attr.Type("file").Id("import-file").Name("file").Attr("accept", ".csv,.json").Required("")),
+					),
+					html.Button(attr.Type("submit").Class("btn-outline"),
+						html.Text("Preview"),
+					),
+				),
+				html.P(attr.Class("text-sm text-muted-foreground mb-6"),
+					html.Text("CSV files need a header row with the columns short_link and full_url, and optionally owner and no_track. An export of the links can be imported again. New links without an owner belong to you."),
+				),
+				content,
+			),
+		),
+	), nil
+}
+
+// postLinksImport either previews an uploaded file or applies records from
+// a previous preview.
+func (h *Handler) postLinksImport(req *foundation.Request) (html.Block, error) {
+	req.Request.Body = http.MaxBytesReader(req.Writer, req.Request.Body, maxImportSize)
+	err := req.Request.ParseMultipartForm(maxImportSize)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return nil, foundation.NewHTTPError(http.StatusBadRequest, "Failed to parse upload", err)
+	}
+
+	var records []*db.LinkRecord
+	apply := req.Request.FormValue("apply") == "true"
+	if apply {
+		err = json.Unmarshal([]byte(req.Request.FormValue("records")), &records)
+		if err != nil {
+			return nil, foundation.NewHTTPError(http.StatusBadRequest, "Invalid import records", err)
+		}
+	} else {
+		file, header, err := req.Request.FormFile("file")
+		if err != nil {
+			return nil, foundation.NewHTTPError(http.StatusBadRequest, "Missing file", err)
+		}
+		defer file.Close()
+
+		format, err := db.FileFormatOf(header.Filename)
+		if err != nil {
+			return importError(err), nil
+		}
+		records, err = db.ReadLinkRecords(file, format)
+		if err != nil {
+			return importError(err), nil
+		}
+	}
+
+	var result *db.LinkImport
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		existing, err := h.DB.Links.Export(ctx, "")
+		if err != nil {
+			return errors.Wrap(err, "Links.Export")
+		}
+		result, err = h.DB.Links.Import(ctx, records, req.User.ID, !apply)
+		if err != nil {
+			return errors.Wrap(err, "Links.Import")
+		}
+		if !result.Applied {
+			return nil
+		}
+		return h.auditChanges(ctx, req, "link.import", "links", importAuditChanges(existing, result))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Applied {
+		log.Printf("user %d imported links: %d created, %d updated", req.User.ID, result.Creates, result.Updates)
+		err = h.linksChanged()
+		if err != nil {
+			return nil, errors.Wrap(err, "linksChanged")
+		}
+	}
+	return importPreview(records, result)
+}
+
+// importAuditChanges records the URL of each created and updated link.
+func importAuditChanges(existing []*db.LinkRecord, result *db.LinkImport) map[string]foundation.AuditChange {
+	urls := map[string]string{}
+	for _, r := range existing {
+		urls[r.ShortLink] = r.FullURL
+	}
+	changes := map[string]foundation.AuditChange{}
+	for _, r := range result.Results {
+		switch r.Action {
+		case db.ImportCreate:
+			changes[r.Record.ShortLink] = foundation.AuditChange{After: r.Record.FullURL}
+		case db.ImportUpdate:
+			changes[r.Record.ShortLink] = foundation.AuditChange{Before: urls[r.Record.ShortLink], After: r.Record.FullURL}
+		}
+	}
+	return changes
+}
+
+func importError(err error) html.Block {
+	return html.Div(attr.Class("alert-destructive").Attr("role", "alert"),
+		html.H2(nil, html.Text("The file can't be imported")),
+		html.Section(nil, html.Text(err.Error())),
+	)
+}
+
+func importPreview(records []*db.LinkRecord, result *db.LinkImport) (html.Block, error) {
+	summary := fmt.Sprintf("%d to create, %d to update, %d unchanged, %d conflicts.",
+		result.Creates, result.Updates, result.Unchanged, result.Conflicts)
+	var action html.Block
+	switch {
+	case result.Applied:
+		summary = fmt.Sprintf("Imported: %d created, %d updated, %d unchanged.",
+			result.Creates, result.Updates, result.Unchanged)
+	case result.Conflicts > 0:
+		summary += " Fix the conflicts in the file and upload it again."
+	case result.Creates+result.Updates > 0:
+		buf, err := json.Marshal(records)
+		if err != nil {
+			return nil, errors.Wrap(err, "Marshal records")
+		}
+		action = html.Form(attr.Method("POST").Action("/admin/import/links").Attr("data-turbo-frame", "import-frame"),
+			html.Input(attr.Type("hidden").Name("apply").Value("true")),
+			html.Input(attr.Type("hidden").Name("records").Value(string(buf))),
+			html.Button(attr.Type("submit").Class("btn"),
+				html.Text("Apply Import"),
+			),
+		)
+	}
+
+	var rows html.Blocks
+	for _, r := range result.Results {
+		if r.Action == db.ImportUnchanged {
+			continue
+		}
+		rows.Add(html.Tr(nil,
+			html.Td(nil,
+				html.Span(attr.Class(importActionBadge(r.Action)), html.Text(string(r.Action))),
+			),
+			html.Td(attr.Class("font-medium"),
+				html.Text(r.Record.ShortLink),
+			),
+			html.Td(nil,
+				html.Text(r.Record.FullURL),
+			),
+			html.Td(nil,
+				html.Text(r.Record.Owner),
+			),
+			html.Td(attr.Class("text-destructive"),
+				html.Text(r.Reason),
+			),
+		))
+	}
+
+	var table html.Block
+	if len(rows) > 0 {
+		table = html.Div(attr.Class("overflow-x-auto w-full mt-4"),
+			html.Table(attr.Class("table"),
+				html.Thead(nil,
+					html.Tr(nil,
+						html.Th(nil, html.Text("Action")),
+						html.Th(nil, html.Text("Short Link")),
+						html.Th(nil, html.Text("Full URL")),
+						html.Th(nil, html.Text("Owner")),
+						html.Th(nil, html.Text("Problem")),
+					),
+				),
+				html.Tbody(nil, rows),
+			),
+		)
+	}
+
+	return html.Div(nil,
+		html.Div(attr.Class("flex justify-between items-center"),
+			html.P(nil, html.Text(summary)),
+			action,
+		),
+		table,
+	), nil
+}
+
+func importActionBadge(action db.LinkImportAction) string {
+	switch action {
+	case db.ImportConflict:
+		return "badge-destructive"
+	case db.ImportCreate:
+		return "badge"
+	}
+	return "badge-secondary"
+}
@@ -3,6 +3,8 @@ package pages
 import (
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/mbertschler/foundation"
 	"github.com/mbertschler/html"
@@ -11,12 +13,13 @@ import (
 )
 
 func (h *Handler) LoginPage(req *foundation.Request) (*Page, error) {
+	next := loginNext(req.Request.URL.Query().Get("next"))
 	var loginErr error
 	switch req.Request.Method {
 	case http.MethodPost:
 		loginErr = h.postLogin(req)
 		if loginErr == nil && req.User != nil {
-			http.Redirect(req.Writer, req.Request, "/admin", http.StatusSeeOther)
+			http.Redirect(req.Writer, req.Request, next, http.StatusSeeOther)
 			return nil, nil
 		}
 		if loginErr != nil {
@@ -26,11 +29,20 @@ func (h *Handler) LoginPage(req *foundation.Request) (*Page, error) {
 
 	page := &Page{
 		Title: "Foundation - Login",
-		Body:  loginFrame(loginErr),
+		Body:  loginFrame(req, loginErr, next),
 	}
 	return page, nil
 }
 
+// loginNext returns where to go after logging in, which is next if it is
+// a path on this server, like the short link that needs a login.
+func loginNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/admin"
+	}
+	return next
+}
+
 func (h *Handler) postLogin(req *foundation.Request) error {
 	err := h.Auth.Login(req)
 	if err != nil {
@@ -40,7 +52,10 @@ func (h *Handler) postLogin(req *foundation.Request) error {
 	return nil
 }
 
-func loginFrame(err error) html.Block {
+// loginFrame renders the login form. After logging in it goes to next,
+// which can be a short link that redirects to another site. Turbo can't
+// follow such redirects, so the form is then submitted without Turbo.
+func loginFrame(req *foundation.Request, err error, next string) html.Block {
 	var errBlock html.Block
 	if err != nil {
 		errBlock = html.Div(attr.Class("alert-destructive"),
@@ -70,8 +85,7 @@ func loginFrame(err error) html.Block {
 				errBlock,
 			),
 			html.Section(nil,
-				html.Form(attr.Id("login-form").Class("form grid gap-6").
-					Method("POST").Action("/admin/login"),
+				loginForm(req, next,
 					html.Div(attr.Class("grid gap-2"),
 						html.Label(attr.For("login-form-username"),
 							html.Text("Username"),
@@ -98,6 +112,15 @@ func loginFrame(err error) html.Block {
 	)
 }
 
+func loginForm(req *foundation.Request, next string, fields ...html.Block) html.Block {
+	form := attr.Id("login-form").Class("form grid gap-6").Method("POST")
+	if next == "/admin" {
+		return html.Form(form.Action("/admin/login"), fields...)
+	}
+	form = form.Action("/admin/login?next="+url.QueryEscape(next)).Attr("data-turbo", "false")
+	return html.Form(form, append(fields, csrfField(req))...)
+}
+
 func (h *Handler) LogoutFrame(req *foundation.Request) (html.Block, error) {
 	if req.Request.Method != http.MethodPost {
 		return nil, errors.New("method not allowed")
@@ -14,6 +14,14 @@ import (
 type PageFunc func(*foundation.Request) (*Page, error)
 type FrameFunc func(*foundation.Request) (html.Block, error)
 
+// CSRFFormKey is the form field with the CSRF token of forms that are
+// submitted without Turbo, which can't send it as a header.
+const CSRFFormKey = "csrf_token"
+
+func csrfField(req *foundation.Request) html.Block {
+	return html.Input(attr.Type("hidden").Name(CSRFFormKey).Value(req.CSRFToken()))
+}
+
 type Page struct {
 	Title   string
 	Sidebar html.Block
@@ -83,6 +91,53 @@ func (s Sidebar) RenderHTML() html.Block {
 								),
 							),
 						),
+						html.Li(nil,
+							html.A(attr.Href("/admin/status"),
+								html.Elem("svg", attr.Attr("xmlns", "http://www.w3.org/2000/svg").Width("24").Height("24").Attr("viewbox", "0 0 24 24").Attr("fill", "none").Attr("stroke", "currentColor").Attr("stroke-width", "2").Attr("stroke-linecap", "round").Attr("stroke-linejoin", "round"),
+									html.Elem("path", attr.Attr("d", "M22 12h-2.48a2 2 0 0 0-1.93 1.46l-2.35 8.36a.25.25 0 0 1-.48 0L9.24 2.18a.25.25 0 0 0-.48 0l-2.35 8.36A2 2 0 0 1 4.49 12H2")),
+								),
+								html.Span(nil,
+									html.Text("Status"),
+								),
+							),
+						),
+						html.Li(nil,
+							html.A(attr.Href("/admin/trash"),
+								html.Elem("svg", attr.Attr("xmlns", "http://www.w3.org/2000/svg").Width("24").Height("24").Attr("viewbox", "0 0 24 24").Attr("fill", "none").Attr("stroke", "currentColor").Attr("stroke-width", "2").Attr("stroke-linecap", "round").Attr("stroke-linejoin", "round"),
+									html.Elem("path", attr.Attr("d", "M3 6h18")),
+									html.Elem("path", attr.Attr("d", "M19 6v14c0 1-1 2-2 2H7c-1 0-2-1-2-2V6")),
+									html.Elem("path", attr.Attr("d", "M8 6V4c0-1 1-2 2-2h4c1 0 2 1 2 2v2")),
+								),
+								html.Span(nil,
+									html.Text("Trash"),
+								),
+							),
+						),
+						html.Li(nil,
+							html.A(attr.Href("/admin/audit"),
+								html.Elem("svg", attr.Attr("xmlns", "http://www.w3.org/2000/svg").Width("24").Height("24").Attr("viewbox", "0 0 24 24").Attr("fill", "none").Attr("stroke", "currentColor").Attr("stroke-width", "2").Attr("stroke-linecap", "round").Attr("stroke-linejoin", "round"),
+									html.Elem("path", attr.Attr("d", "M15 12h-5")),
+									html.Elem("path", attr.Attr("d", "M15 8h-5")),
+									html.Elem("path", attr.Attr("d", "M19 17V5a2 2 0 0 0-2-2H4")),
+									html.Elem("path", attr.Attr("d", "M8 21h12a2 2 0 0 0 2-2v-1a1 1 0 0 0-1-1H11a1 1 0 0 0-1 1v1a2 2 0 1 1-4 0V5a2 2 0 1 0-4 0v2a1 1 0 0 0 1 1h3")),
+								),
+								html.Span(nil,
+									html.Text("Audit Log"),
+								),
+							),
+						),
+						html.Li(nil,
+							html.A(attr.Href("/admin/backups"),
+								html.Elem("svg", attr.Attr("xmlns", "http://www.w3.org/2000/svg").Width("24").Height("24").Attr("viewbox", "0 0 24 24").Attr("fill", "none").Attr("stroke", "currentColor").Attr("stroke-width", "2").Attr("stroke-linecap", "round").Attr("stroke-linejoin", "round"),
+									html.Elem("ellipse", attr.Attr("cx", "12").Attr("cy", "5").Attr("rx", "9").Attr("ry", "3")),
+									html.Elem("path", attr.Attr("d", "M3 5V19A9 3 0 0 0 21 19V5")),
+									html.Elem("path", attr.Attr("d", "M3 12A9 3 0 0 0 21 12")),
+								),
+								html.Span(nil,
+									html.Text("Backups"),
+								),
+							),
+						),
 					),
 					html.Form(attr.Class("mt-auto mb-2 text-center").Method("POST").Action("/admin/logout"),
 						html.Button(attr.Class("btn-outline").Type("submit"),
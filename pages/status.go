@@ -0,0 +1,111 @@
+package pages
+
+import (
+	"fmt"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/server/health"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+)
+
+func (h *Handler) StatusPage(req *foundation.Request) (*Page, error) {
+	status, _ := h.Health.Ready(req.Context.Context)
+
+	var components html.Blocks
+	for _, c := range h.Health.Components() {
+		components.Add(componentCard(c))
+	}
+
+	page := &Page{
+		Title:   "Quick Links - Status",
+		Sidebar: Sidebar{},
+		Header: Header{
+			Title: "Status",
+		},
+		Body: html.Div(attr.Class("p-4 md:p-6 xl:p-12"),
+			html.Main(attr.Class("mx-auto relative w-full max-w-screen-lg grid gap-10"),
+				html.Div(nil,
+					html.H2(attr.Class("text-2xl font-bold mb-4"), html.Text(fmt.Sprintf("Readiness: %s", status.Status))),
+					checksTable(status.Checks),
+				),
+				components,
+			),
+		),
+	}
+	return page, nil
+}
+
+func checksTable(checks []health.CheckResult) html.Block {
+	var rows html.Blocks
+	for _, c := range checks {
+		rows.Add(html.Tr(nil,
+			html.Td(attr.Class("font-medium"),
+				html.Text(c.Name),
+			),
+			html.Td(nil,
+				html.Span(attr.Class(statusBadgeClass(c.Status)), html.Text(c.Status)),
+			),
+			html.Td(nil,
+				html.Text(c.Error),
+			),
+			html.Td(attr.Class("text-right"),
+				html.Text(fmt.Sprintf("%.1f ms", c.DurationMS)),
+			),
+		))
+	}
+
+	return html.Div(attr.Class("overflow-x-auto w-full"),
+		html.Table(attr.Class("table"),
+			html.Thead(nil,
+				html.Tr(nil,
+					html.Th(nil,
+						html.Text("Check"),
+					),
+					html.Th(nil,
+						html.Text("Status"),
+					),
+					html.Th(nil,
+						html.Text("Error"),
+					),
+					html.Th(nil,
+						html.Text("Duration"),
+					),
+				),
+			),
+			html.Tbody(nil,
+				rows,
+			),
+		),
+	)
+}
+
+func componentCard(c health.ComponentStatus) html.Block {
+	var fields html.Blocks
+	for _, f := range c.Fields {
+		fields.Add(
+			html.Dt(attr.Class("text-muted-foreground"), html.Text(f.Name)),
+			html.Dd(attr.Class("font-mono break-all"), html.Text(f.Value)),
+		)
+	}
+
+	return html.Div(attr.Class("card"),
+		html.Header(nil,
+			html.H2(nil,
+				html.Text(c.Name),
+			),
+		),
+		html.Section(nil,
+			html.Dl(attr.Class("grid grid-cols-[auto_1fr] gap-x-6 gap-y-2"),
+				fields,
+			),
+		),
+	)
+}
+
+func statusBadgeClass(status string) string {
+	if status == "ok" {
+		return "badge"
+	}
+	return "badge-destructive"
+}
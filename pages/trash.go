@@ -0,0 +1,249 @@
+package pages
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/db"
+	"github.com/mbertschler/html"
+	"github.com/mbertschler/html/attr"
+	"github.com/pkg/errors"
+)
+
+func (h *Handler) TrashPage(req *foundation.Request) (*Page, error) {
+	trashFrame, err := h.trashFrame(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "trashFrame")
+	}
+
+	page := &Page{
+		Title:   "Quick Links - Trash",
+		Sidebar: Sidebar{},
+		Header: Header{
+			Title: "Trash",
+		},
+		Body: trashFrame,
+	}
+
+	return page, nil
+}
+
+// TrashLinkFrame restores a link from the trash with POST, or purges it
+// permanently with DELETE.
+func (h *Handler) TrashLinkFrame(req *foundation.Request) (html.Block, error) {
+	shortLink := req.Params.ByName("short_link")
+	err := h.DB.InTx(req.Context, func(ctx context.Context) error {
+		if req.Request.Method == http.MethodDelete {
+			inTrash, err := h.DB.Links.InTrash(ctx, shortLink)
+			if err != nil {
+				return errors.Wrap(err, "Links.InTrash")
+			}
+			if !inTrash {
+				return sql.ErrNoRows
+			}
+			err = h.DB.Links.Purge(ctx, shortLink)
+			if err != nil {
+				return errors.Wrap(err, "Links.Purge")
+			}
+			return h.auditChanges(ctx, req, "link.purge", "link:"+shortLink, nil)
+		}
+		err := h.DB.Links.Restore(ctx, shortLink)
+		if err != nil {
+			return errors.Wrap(err, "Links.Restore")
+		}
+		return h.auditChanges(ctx, req, "link.restore", "link:"+shortLink, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "Link not found in the trash", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.linksChanged()
+	if err != nil {
+		return nil, errors.Wrap(err, "linksChanged")
+	}
+	return h.trashFrame(req)
+}
+
+// TrashUserFrame restores a user from the trash with POST, or purges them
+// permanently with DELETE.
+func (h *Handler) TrashUserFrame(req *foundation.Request) (html.Block, error) {
+	userID, err := strconv.ParseInt(req.Params.ByName("id"), 10, 64)
+	if err != nil {
+		return nil, foundation.NewHTTPError(http.StatusBadRequest, "Invalid user ID", err)
+	}
+	target := fmt.Sprintf("user:%d", userID)
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		if req.Request.Method == http.MethodDelete {
+			err := h.DB.Users.Purge(ctx, userID)
+			if err != nil {
+				return errors.Wrap(err, "Users.Purge")
+			}
+			return h.auditChanges(ctx, req, "user.purge", target, nil)
+		}
+		err := h.DB.Users.Restore(ctx, userID)
+		if err != nil {
+			return errors.Wrap(err, "Users.Restore")
+		}
+		return h.auditChanges(ctx, req, "user.restore", target, nil)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "User not found in the trash", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return h.trashFrame(req)
+}
+
+func (h *Handler) trashFrame(req *foundation.Request) (html.Block, error) {
+	links, err := h.DB.Links.Trashed(req.Context.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "Links.Trashed")
+	}
+	users, err := h.DB.Users.Trashed(req.Context.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "Users.Trashed")
+	}
+
+	retention := db.TrashRetention
+	if r := req.Current().TrashRetention; r > 0 {
+		retention = r.Duration()
+	}
+
+	return html.Elem("turbo-frame", attr.Id("trash-frame"),
+		html.Div(attr.Class("p-4 md:p-6 xl:p-12"),
+			html.Main(attr.Class("mx-auto relative w-full max-w-screen-lg gap-10"),
+				html.P(attr.Class("text-sm text-muted-foreground mb-6"),
+					html.Text(fmt.Sprintf("Deleted links and users are purged permanently after %d days in the trash.", int(retention.Hours()/24))),
+				),
+				html.H2(attr.Class("text-2xl font-bold mb-4"), html.Text("Links")),
+				trashedLinksTable(links),
+				html.H2(attr.Class("text-2xl font-bold mt-10 mb-4"), html.Text("Users")),
+				trashedUsersTable(users),
+			),
+		),
+	), nil
+}
+
+func trashedLinksTable(links []*foundation.Link) html.Block {
+	var rows html.Blocks
+	for _, link := range links {
+		owner := "Unknown"
+		if link.User != nil {
+			owner = link.User.DisplayName
+		}
+		rows.Add(html.Tr(nil,
+			html.Td(attr.Class("font-medium"),
+				html.Text(link.ShortLink),
+			),
+			html.Td(nil,
+				html.Text(link.FullURL),
+			),
+			html.Td(nil,
+				html.Text(owner),
+			),
+			html.Td(attr.Class("text-right"),
+				html.Text(link.DeletedAt.Local().Format("2006-01-02 15:04")),
+			),
+			html.Td(nil,
+				trashActions(fmt.Sprintf("/admin/trash/links/%s", link.ShortLink), link.ShortLink),
+			),
+		))
+	}
+	if len(rows) == 0 {
+		rows.Add(emptyTrashRow("5", "No links in the trash."))
+	}
+
+	return html.Div(attr.Class("overflow-x-auto w-full"),
+		html.Table(attr.Class("table"),
+			html.Thead(nil,
+				html.Tr(nil,
+					html.Th(nil, html.Text("Short Link")),
+					html.Th(nil, html.Text("Full URL")),
+					html.Th(nil, html.Text("Owner")),
+					html.Th(nil, html.Text("Deleted")),
+					html.Th(nil, html.Text("Actions")),
+				),
+			),
+			html.Tbody(nil,
+				rows,
+			),
+		),
+	)
+}
+
+func trashedUsersTable(users []*foundation.User) html.Block {
+	var rows html.Blocks
+	for _, user := range users {
+		rows.Add(html.Tr(nil,
+			html.Td(attr.Class("font-medium"),
+				html.Text(fmt.Sprint(user.ID)),
+			),
+			html.Td(nil,
+				html.Text(user.DisplayName),
+			),
+			html.Td(nil,
+				html.Text(user.UserName),
+			),
+			html.Td(attr.Class("text-right"),
+				html.Text(user.DeletedAt.Local().Format("2006-01-02 15:04")),
+			),
+			html.Td(nil,
+				trashActions(fmt.Sprintf("/admin/trash/users/%d", user.ID), user.UserName),
+			),
+		))
+	}
+	if len(rows) == 0 {
+		rows.Add(emptyTrashRow("5", "No users in the trash."))
+	}
+
+	return html.Div(attr.Class("overflow-x-auto w-full"),
+		html.Table(attr.Class("table"),
+			html.Thead(nil,
+				html.Tr(nil,
+					html.Th(nil, html.Text("ID")),
+					html.Th(nil, html.Text("Display Name")),
+					html.Th(nil, html.Text("Username")),
+					html.Th(nil, html.Text("Deleted")),
+					html.Th(nil, html.Text("Actions")),
+				),
+			),
+			html.Tbody(nil,
+				rows,
+			),
+		),
+	)
+}
+
+// trashActions restores the item at path with POST and purges it with
+// DELETE after a confirmation.
+func trashActions(path, name string) html.Block {
+	return html.Div(attr.Class("flex gap-2"),
+		html.Form(attr.Method("POST").Action(path+"/restore").Attr("data-turbo-frame", "trash-frame"),
+			html.Button(attr.Type("submit").Class("btn-sm-outline"),
+				html.Text("Restore"),
+			),
+		),
+		html.Form(attr.Method("DELETE").Action(path).Attr("data-turbo-frame", "trash-frame").Attr("data-turbo-confirm", fmt.Sprintf("Permanently delete %q? This can't be undone.", name)),
+			html.Button(attr.Type("submit").Class("btn-sm-destructive"),
+				html.Text("Delete Forever"),
+			),
+		),
+	)
+}
+
+func emptyTrashRow(colspan, text string) html.Block {
+	return html.Tr(nil,
+		html.Td(attr.Attr("colspan", colspan).Class("text-center text-muted-foreground"),
+			html.Text(text),
+		),
+	)
+}
@@ -1,6 +1,7 @@
 package pages
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/mbertschler/foundation"
 	"github.com/mbertschler/foundation/auth"
+	"github.com/mbertschler/foundation/form"
 	"github.com/mbertschler/foundation/pages/components"
 	"github.com/mbertschler/html"
 	"github.com/mbertschler/html/attr"
@@ -33,17 +35,25 @@ func (h *Handler) UsersPage(req *foundation.Request) (*Page, error) {
 }
 
 func (h *Handler) UsersFrame(req *foundation.Request) (html.Block, error) {
+	// dialog is set if a submitted form has to be shown again with errors
+	var dialog html.Block
 	switch req.Request.Method {
 	case http.MethodPost:
-		err := h.postNewUser(req)
+		f, errs, err := h.postNewUser(req)
 		if err != nil {
 			return nil, errors.Wrap(err, "postNewUser")
 		}
+		if len(errs) > 0 {
+			dialog = newUserDialog(f, errs)
+		}
 	case http.MethodPatch:
-		err := h.patchUser(req)
+		f, errs, err := h.patchUser(req)
 		if err != nil {
 			return nil, errors.Wrap(err, "patchUser")
 		}
+		if len(errs) > 0 {
+			dialog = editUserDialog(req.Params.ByName("id"), f, errs)
+		}
 	case http.MethodDelete:
 		err := h.deleteUser(req)
 		if err != nil {
@@ -56,6 +66,12 @@ func (h *Handler) UsersFrame(req *foundation.Request) (html.Block, error) {
 		return nil, errors.Wrap(err, "All users")
 	}
 
+	if dialog == nil {
+		dialog = html.Elem("turbo-frame", attr.Id("user-dialog-frame"))
+	} else {
+		req.Writer.WriteHeader(http.StatusUnprocessableEntity)
+	}
+
 	return html.Elem("turbo-frame", attr.Id("users-frame"),
 		html.Div(attr.Class("p-4 md:p-6 xl:p-12"),
 			html.Main(attr.Class("mx-auto relative w-full max-w-screen-lg gap-10"),
@@ -66,146 +82,173 @@ func (h *Handler) UsersFrame(req *foundation.Request) (html.Block, error) {
 				usersTable(allUsers),
 			),
 		),
-		html.Elem("turbo-frame", attr.Id("user-dialog-frame")),
+		dialog,
 	), nil
 }
 
-func (h *Handler) postNewUser(req *foundation.Request) error {
-	r := req.Request
-	err := r.ParseForm()
+// userForm holds the fields of the new and edit user dialogs. The password
+// is only required for new users.
+type userForm struct {
+	DisplayName string `form:"display_name" validate:"required,max=100"`
+	UserName    string `form:"username" validate:"required,max=255" pattern:"^\\S+$" message:"Must not contain spaces."`
+	Password    string `form:"password" validate:"max=1024"`
+}
+
+func (h *Handler) postNewUser(req *foundation.Request) (*userForm, form.Errors, error) {
+	var f userForm
+	errs, err := form.Decode(req.Request, &f)
 	if err != nil {
-		http.Error(req.Writer, "Failed to parse form", http.StatusBadRequest)
-		return errors.Wrap(err, "ParseForm")
+		return nil, nil, foundation.NewHTTPError(http.StatusBadRequest, "Failed to parse form", err)
+	}
+	if f.Password == "" {
+		errs.Add("password", "Please fill in this field.")
+	}
+	if len(errs) > 0 {
+		return &f, errs, nil
 	}
 
-	displayName := r.FormValue("display_name")
-	username := r.FormValue("username")
-	password := r.FormValue("password")
-
-	exists, err := h.DB.Users.ExistsByUsername(req.Context.Context, username)
+	exists, err := h.DB.Users.ExistsByUsername(req.Context.Context, f.UserName)
 	if err != nil {
-		return errors.Wrap(err, "ExistsByUsername")
+		return nil, nil, errors.Wrap(err, "ExistsByUsername")
 	}
 	if exists {
-		http.Error(req.Writer, fmt.Sprintf("Username %q already exists", username), http.StatusConflict)
-		return errors.New("username exists")
+		errs.Add("username", fmt.Sprintf("Username %q already exists.", f.UserName))
+		return &f, errs, nil
 	}
 
-	hashedPassword, err := auth.HashPassword(password)
+	hashedPassword, err := auth.HashPassword(f.Password)
 	if err != nil {
-		return errors.Wrap(err, "HashPassword")
+		return nil, nil, errors.Wrap(err, "HashPassword")
 	}
 
 	user := &foundation.User{
-		DisplayName:    displayName,
-		UserName:       username,
+		DisplayName:    f.DisplayName,
+		UserName:       f.UserName,
 		HashedPassword: hashedPassword,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	}
-	err = h.DB.Users.Insert(req.Context, user)
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		err := h.DB.Users.Insert(ctx, user)
+		if err != nil {
+			return errors.Wrap(err, "Insert user")
+		}
+		return h.audit(ctx, req, "user.create", fmt.Sprintf("user:%d", user.ID), nil, userAuditOf(user))
+	})
 	if err != nil {
-		return errors.Wrap(err, "Insert user")
+		return nil, nil, err
 	}
 
-	return nil
+	return nil, nil, nil
 }
 
-func (h *Handler) patchUser(req *foundation.Request) error {
-	r := req.Request
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(req.Writer, "Failed to parse form", http.StatusBadRequest)
-		return errors.Wrap(err, "ParseForm")
-	}
-
+func (h *Handler) patchUser(req *foundation.Request) (*userForm, form.Errors, error) {
 	// Extract user ID from URL path
 	userIDStr := req.Params.ByName("id")
 	if userIDStr == "" {
-		http.Error(req.Writer, "User ID is required", http.StatusBadRequest)
-		return errors.New("missing user ID")
+		return nil, nil, foundation.NewHTTPError(http.StatusBadRequest, "User ID is required", nil)
 	}
 
 	var userID int64
-	_, err = fmt.Sscanf(userIDStr, "%d", &userID)
+	_, err := fmt.Sscanf(userIDStr, "%d", &userID)
 	if err != nil {
-		http.Error(req.Writer, "Invalid user ID", http.StatusBadRequest)
-		return errors.Wrap(err, "invalid user ID")
+		return nil, nil, foundation.NewHTTPError(http.StatusBadRequest, "Invalid user ID", err)
 	}
 
 	// Get existing user
 	existingUser, err := h.DB.Users.ByID(req.Context.Context, userID)
 	if err != nil {
-		http.Error(req.Writer, "User not found", http.StatusNotFound)
-		return errors.Wrap(err, "user not found")
+		return nil, nil, foundation.NewHTTPError(http.StatusNotFound, "User not found", err)
 	}
 
-	displayName := r.FormValue("display_name")
-	username := r.FormValue("username")
-	password := r.FormValue("password")
+	var f userForm
+	errs, err := form.Decode(req.Request, &f)
+	if err != nil {
+		return nil, nil, foundation.NewHTTPError(http.StatusBadRequest, "Failed to parse form", err)
+	}
+	if len(errs) > 0 {
+		return &f, errs, nil
+	}
 
 	// Check if username is being changed and if it already exists
-	if username != existingUser.UserName {
-		exists, err := h.DB.Users.ExistsByUsername(req.Context.Context, username)
+	if f.UserName != existingUser.UserName {
+		exists, err := h.DB.Users.ExistsByUsername(req.Context.Context, f.UserName)
 		if err != nil {
-			return errors.Wrap(err, "ExistsByUsername")
+			return nil, nil, errors.Wrap(err, "ExistsByUsername")
 		}
 		if exists {
-			http.Error(req.Writer, fmt.Sprintf("Username %q already exists", username), http.StatusConflict)
-			return errors.New("username exists")
+			errs.Add("username", fmt.Sprintf("Username %q already exists.", f.UserName))
+			return &f, errs, nil
 		}
 	}
 
 	// Update user fields
-	existingUser.DisplayName = displayName
-	existingUser.UserName = username
-	if password != "" {
-		hashedPassword, err := auth.HashPassword(password)
+	before := userAuditOf(existingUser)
+	existingUser.DisplayName = f.DisplayName
+	existingUser.UserName = f.UserName
+	if f.Password != "" {
+		hashedPassword, err := auth.HashPassword(f.Password)
 		if err != nil {
-			return errors.Wrap(err, "HashPassword")
+			return nil, nil, errors.Wrap(err, "HashPassword")
 		}
 		existingUser.HashedPassword = hashedPassword
 	}
 	existingUser.UpdatedAt = time.Now()
+	after := userAuditOf(existingUser)
+	if f.Password != "" {
+		after.Password = "(changed)"
+	}
 
-	err = h.DB.Users.Update(req.Context.Context, existingUser)
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		err := h.DB.Users.Update(ctx, existingUser)
+		if err != nil {
+			return errors.Wrap(err, "Update user")
+		}
+		return h.audit(ctx, req, "user.update", fmt.Sprintf("user:%d", userID), before, after)
+	})
 	if err != nil {
-		return errors.Wrap(err, "Update user")
+		return nil, nil, err
 	}
 
 	log.Printf("Updated user with ID %d", userID)
-	return nil
+	return nil, nil, nil
 }
 
 func (h *Handler) deleteUser(req *foundation.Request) error {
 	// Extract user ID from URL path
 	userIDStr := req.Params.ByName("id")
 	if userIDStr == "" {
-		http.Error(req.Writer, "User ID is required", http.StatusBadRequest)
-		return errors.New("missing user ID")
+		return foundation.NewHTTPError(http.StatusBadRequest, "User ID is required", nil)
 	}
 
 	var userID int64
 	_, err := fmt.Sscanf(userIDStr, "%d", &userID)
 	if err != nil {
-		http.Error(req.Writer, "Invalid user ID", http.StatusBadRequest)
-		return errors.Wrap(err, "invalid user ID")
+		return foundation.NewHTTPError(http.StatusBadRequest, "Invalid user ID", err)
 	}
 
 	// Check if user exists
-	_, err = h.DB.Users.ByID(req.Context.Context, userID)
+	user, err := h.DB.Users.ByID(req.Context.Context, userID)
 	if err != nil {
-		http.Error(req.Writer, "User not found", http.StatusNotFound)
-		return errors.Wrap(err, "user not found")
+		return foundation.NewHTTPError(http.StatusNotFound, "User not found", err)
+	}
+	if req.User == nil || req.User.ID == userID {
+		return foundation.NewHTTPError(http.StatusBadRequest, "You can't delete your own user", nil)
 	}
 
-	// Delete user
-	err = h.DB.Users.Delete(req.Context.Context, userID)
+	// Move the user to the trash, their links now belong to the deleting user
+	err = h.DB.InTx(req.Context, func(ctx context.Context) error {
+		err := h.DB.Users.Delete(ctx, userID, req.User.ID)
+		if err != nil {
+			return errors.Wrap(err, "Delete user")
+		}
+		return h.audit(ctx, req, "user.delete", fmt.Sprintf("user:%d", userID), userAuditOf(user), nil)
+	})
 	if err != nil {
-		return errors.Wrap(err, "Delete user")
+		return err
 	}
 
-	log.Printf("Deleted user with ID %d", userID)
+	log.Printf("Moved user with ID %d to the trash", userID)
 	return nil
 }
 
@@ -280,6 +323,12 @@ func newUserForm() html.Block {
 }
 
 func (h *Handler) UserNewFrame(req *foundation.Request) (html.Block, error) {
+	return newUserDialog(&userForm{}, nil), nil
+}
+
+// newUserDialog shows the new user dialog. Submitted passwords are never
+// shown again.
+func newUserDialog(f *userForm, errs form.Errors) html.Block {
 	return html.Elem("turbo-frame", attr.Id("user-dialog-frame"),
 		html.Dialog(attr.Id("new-user-dialog").Class("dialog w-full sm:max-w-[425px] max-h-[612px]").Attr("aria-labelledby", "new-user-dialog-title").Attr("aria-describedby", "new-user-dialog-description").Attr("onclick", "if (event.target === this) this.close()"),
 			html.Article(nil,
@@ -293,24 +342,24 @@ func (h *Handler) UserNewFrame(req *foundation.Request) (html.Block, error) {
 				),
 				html.Section(nil,
 					html.Form(attr.Method("POST").Action("/admin/users").Class("form grid gap-4").Attr("data-turbo-frame", "users-frame"),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For("display-name"),
-								html.Text("Display Name"),
-							),
-							html.Input(attr.Type("text").Name("display_name").Id("display-name").Required("").Autofocus("")),
-						),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For("username"),
-								html.Text("Username"),
-							),
-							html.Input(attr.Type("text").Name("username").Id("username").Required("")),
-						),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For("password"),
-								html.Text("Password"),
-							),
-							html.Input(attr.Type("password").Name("password").Id("password").Required("")),
-						),
+						components.Field{
+							Id:    "display-name",
+							Label: "Display Name",
+							Input: attr.Type("text").Name("display_name").Value(f.DisplayName).Required("").Autofocus(""),
+							Error: errs.Get("display_name"),
+						},
+						components.Field{
+							Id:    "username",
+							Label: "Username",
+							Input: attr.Type("text").Name("username").Value(f.UserName).Required(""),
+							Error: errs.Get("username"),
+						},
+						components.Field{
+							Id:    "password",
+							Label: "Password",
+							Input: attr.Type("password").Name("password").Required(""),
+							Error: errs.Get("password"),
+						},
 						html.Div(attr.Class("flex justify-end gap-2 mt-4"),
 							html.Button(attr.Type("button").Class("btn-outline").Attr("onclick", "this.closest('dialog').close()"),
 								html.Text("Cancel"),
@@ -330,64 +379,76 @@ func (h *Handler) UserNewFrame(req *foundation.Request) (html.Block, error) {
 			),
 		),
 		html.Script(nil, html.JS("document.getElementById('new-user-dialog').showModal();")),
-	), nil
+	)
 }
 
 func (h *Handler) UserUpdateFrame(req *foundation.Request) (html.Block, error) {
 	userIDStr := req.Params.ByName("id")
 	if userIDStr == "" {
-		return nil, errors.New("user ID is required")
+		return nil, foundation.NewHTTPError(http.StatusBadRequest, "User ID is required", nil)
 	}
 
 	var userID int64
 	_, err := fmt.Sscanf(userIDStr, "%d", &userID)
 	if err != nil {
-		return nil, errors.Wrap(err, "invalid user ID")
+		return nil, foundation.NewHTTPError(http.StatusBadRequest, "Invalid user ID", err)
 	}
 
 	user, err := h.DB.Users.ByID(req.Context.Context, userID)
 	if err != nil {
-		return nil, errors.Wrap(err, "user not found")
+		return nil, foundation.NewHTTPError(http.StatusNotFound, "User not found", err)
 	}
 
+	f := &userForm{
+		DisplayName: user.DisplayName,
+		UserName:    user.UserName,
+	}
+	return editUserDialog(fmt.Sprint(user.ID), f, nil), nil
+}
+
+// editUserDialog shows the edit dialog for the user with the given ID.
+func editUserDialog(id string, f *userForm, errs form.Errors) html.Block {
 	return html.Elem("turbo-frame", attr.Id("user-dialog-frame"),
-		html.Dialog(attr.Id(fmt.Sprintf("edit-user-dialog-%d", user.ID)).Class("dialog w-full sm:max-w-[425px] max-h-[612px]").Attr("aria-labelledby", fmt.Sprintf("edit-user-dialog-title-%d", user.ID)).Attr("aria-describedby", fmt.Sprintf("edit-user-dialog-description-%d", user.ID)).Attr("onclick", "if (event.target === this) this.close()"),
+		html.Dialog(attr.Id(fmt.Sprintf("edit-user-dialog-%s", id)).Class("dialog w-full sm:max-w-[425px] max-h-[612px]").Attr("aria-labelledby", fmt.Sprintf("edit-user-dialog-title-%s", id)).Attr("aria-describedby", fmt.Sprintf("edit-user-dialog-description-%s", id)).Attr("onclick", "if (event.target === this) this.close()"),
 			html.Article(nil,
 				html.Header(nil,
-					html.H2(attr.Id(fmt.Sprintf("edit-user-dialog-title-%d", user.ID)),
+					html.H2(attr.Id(fmt.Sprintf("edit-user-dialog-title-%s", id)),
 						html.Text("Edit User"),
 					),
-					html.P(attr.Id(fmt.Sprintf("edit-user-dialog-description-%d", user.ID)),
+					html.P(attr.Id(fmt.Sprintf("edit-user-dialog-description-%s", id)),
 						html.Text("Make changes to the user details."),
 					),
 				),
 				html.Section(nil,
-					html.Form(attr.Id("delete-user").Method("DELETE").Action(fmt.Sprintf("/admin/users/%d", user.ID)).Attr("data-turbo-frame", "users-frame")), //.Attr("onsubmit", "this.closest('dialog').close(); return true;"),
-					html.Form(attr.Method("PATCH").Action(fmt.Sprintf("/admin/users/%d", user.ID)).Class("form grid gap-4").Attr("data-turbo-frame", "users-frame"),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For(fmt.Sprintf("edit-display-name-%d", user.ID)),
-								html.Text("Display Name"),
-							),
-							html.Input(attr.Type("text").Name("display_name").Id(fmt.Sprintf("edit-display-name-%d", user.ID)).Value(user.DisplayName).Required("")),
-						),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For(fmt.Sprintf("edit-username-%d", user.ID)),
-								html.Text("Username"),
-							),
-							html.Input(attr.Type("text").Name("username").Id(fmt.Sprintf("edit-username-%d", user.ID)).Value(user.UserName).Required("")),
-						),
-						html.Div(attr.Class("grid gap-3"),
-							html.Label(attr.For(fmt.Sprintf("edit-password-%d", user.ID)),
-								html.Text("New Password (leave empty to keep current)"),
-							),
-							html.Input(attr.Type("password").Name("password").Id(fmt.Sprintf("edit-password-%d", user.ID))),
-						),
+					html.Form(attr.Id("delete-user").Method("DELETE").Action(fmt.Sprintf("/admin/users/%s", id)).Attr("data-turbo-frame", "users-frame")), //.Attr("onsubmit", "this.closest('dialog').close(); return true;"),
+					html.Form(attr.Method("PATCH").Action(fmt.Sprintf("/admin/users/%s", id)).Class("form grid gap-4").Attr("data-turbo-frame", "users-frame"),
+						components.Field{
+							Id:    fmt.Sprintf("edit-display-name-%s", id),
+							Label: "Display Name",
+							Input: attr.Type("text").Name("display_name").Value(f.DisplayName).Required(""),
+							Error: errs.Get("display_name"),
+						},
+						components.Field{
+							Id:    fmt.Sprintf("edit-username-%s", id),
+							Label: "Username",
+							Input: attr.Type("text").Name("username").Value(f.UserName).Required(""),
+							Error: errs.Get("username"),
+						},
+						components.Field{
+							Id:    fmt.Sprintf("edit-password-%s", id),
+							Label: "New Password (leave empty to keep current)",
+							Input: attr.Type("password").Name("password"),
+							Error: errs.Get("password"),
+						},
 						html.Div(attr.Class("flex justify-between items-center mt-4"),
 							components.Dropdown{
 								Id:          "delete-user",
 								ButtonText:  "Delete User",
 								ButtonClass: "btn-destructive",
 								Items: html.Blocks{
+									html.P(attr.Class("px-2 py-1.5 text-sm text-muted-foreground"),
+										html.Text("The links of the user are transferred to you."),
+									),
 									html.Div(attr.Role("menuitem"),
 										html.Text("Cancel"),
 									),
@@ -415,6 +476,6 @@ func (h *Handler) UserUpdateFrame(req *foundation.Request) (html.Block, error) {
 				),
 			),
 		),
-		html.Script(nil, html.JS(fmt.Sprintf("document.getElementById('edit-user-dialog-%d').showModal();", user.ID))),
-	), nil
+		html.Script(nil, html.JS(fmt.Sprintf("document.getElementById('edit-user-dialog-%s').showModal();", id))),
+	)
 }
@@ -0,0 +1,530 @@
+// Package qr encodes QR codes (ISO/IEC 18004) and renders them as SVG and
+// PNG images. Data is always encoded in byte mode, which fits URLs well.
+package qr
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Level is the error correction level of a code. Higher levels can be read
+// when more of the code is damaged or covered, but need more modules.
+type Level int
+
+const (
+	// Low recovers about 7% of the codewords.
+	Low Level = iota
+	// Medium recovers about 15% of the codewords.
+	Medium
+	// Quartile recovers about 25% of the codewords.
+	Quartile
+	// High recovers about 30% of the codewords.
+	High
+)
+
+// ParseLevel parses the level letters L, M, Q and H.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	}
+	return 0, errors.Errorf("unknown error correction level %q, use L, M, Q or H", s)
+}
+
+func (l Level) String() string {
+	return string("LMQH"[l])
+}
+
+// formatBits are the bits of a level in the format information.
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// eccCodewordsPerBlock and numECCBlocks are indexed by level and version.
+var eccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var numECCBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Code is an encoded QR code, a square of dark and light modules.
+type Code struct {
+	// Size is the number of modules per side, without quiet zone.
+	Size    int
+	Version int
+	Level   Level
+	Mask    int
+
+	modules    []bool
+	isFunction []bool
+}
+
+// Encode encodes data in the smallest version that fits at level.
+func Encode(data string, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, errors.Errorf("invalid level %d", level)
+	}
+	version := minVersion
+	for ; ; version++ {
+		if version > maxVersion {
+			return nil, errors.Errorf("%d bytes are too long for a QR code at level %s", len(data), level)
+		}
+		if 4+charCountBits(version)+8*len(data) <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	var bits bitBuffer
+	bits.append(0b0100, 4) // byte mode
+	bits.append(len(data), charCountBits(version))
+	for i := 0; i < len(data); i++ {
+		bits.append(int(data[i]), 8)
+	}
+	capacity := numDataCodewords(version, level) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+
+	c := &Code{
+		Size:    version*4 + 17,
+		Version: version,
+		Level:   level,
+	}
+	c.modules = make([]bool, c.Size*c.Size)
+	c.isFunction = make([]bool, c.Size*c.Size)
+	c.drawFunctionPatterns()
+	c.drawCodewords(c.addECCAndInterleave(codewords))
+
+	// use the mask with the lowest penalty, as the standard suggests
+	minPenalty := -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		penalty := c.penalty()
+		if minPenalty < 0 || penalty < minPenalty {
+			c.Mask = mask
+			minPenalty = penalty
+		}
+		c.applyMask(mask) // masks are undone by applying them again
+	}
+	c.applyMask(c.Mask)
+	c.drawFormatBits(c.Mask)
+	return c, nil
+}
+
+// Dark reports whether the module at x, y is dark. Coordinates outside of
+// the code are light, like the quiet zone around it.
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y*c.Size+x]
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+	c.isFunction[y*c.Size+x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.Size-4, 3)
+	c.drawFinderPattern(3, c.Size-4)
+
+	positions := alignmentPositions(c.Version)
+	n := len(positions)
+	for i := range positions {
+		for j := range positions {
+			// skip the corners with finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			c.drawAlignmentPattern(positions[i], positions[j])
+		}
+	}
+
+	// reserve the format bits, they are drawn after masking
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+func (c *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.set(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatInfo returns the 15 format bits of level and mask, with their BCH
+// error correction.
+func formatInfo(level Level, mask int) int {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatInfo(c.Level, mask)
+
+	// around the top left finder pattern
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(bits, i))
+	}
+	c.set(8, 7, bit(bits, 6))
+	c.set(8, 8, bit(bits, 7))
+	c.set(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(bits, i))
+	}
+
+	// next to the other finder patterns
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(bits, i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionInfo(c.Version)
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		c.set(a, b, bit(bits, i))
+		c.set(b, a, bit(bits, i))
+	}
+}
+
+// versionInfo returns the 18 version bits of versions 7 and up, with their
+// BCH error correction.
+func versionInfo(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+// addECCAndInterleave splits data into blocks, appends the error
+// correction codewords of each block and interleaves the blocks.
+func (c *Code) addECCAndInterleave(data []byte) []byte {
+	numBlocks := numECCBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	rawCodewords := numRawDataModules(c.Version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			n++
+		}
+		block := make([]byte, shortBlockLen+1)
+		copy(block, data[k:k+n])
+		copy(block[shortBlockLen+1-eccLen:], reedSolomonRemainder(data[k:k+n], divisor))
+		k += n
+		blocks[i] = block
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i <= shortBlockLen; i++ {
+		for j, block := range blocks {
+			// short blocks have a gap before their error correction
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// drawCodewords places data in the zigzag order of the standard, two
+// columns at a time from the bottom right.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// skip the vertical timing pattern
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y*c.Size+x] && i < len(data)*8 {
+					c.modules[y*c.Size+x] = bit(int(data[i>>3]), 7-i&7)
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			i := y*c.Size + x
+			c.modules[i] = c.modules[i] != (invert && !c.isFunction[i])
+		}
+	}
+}
+
+// penalty scores how hard the code is to read, see section 7.8.3 of the
+// standard.
+func (c *Code) penalty() int {
+	const n1, n2, n3, n4 = 3, 3, 40, 10
+	result := 0
+	for _, horizontal := range []bool{true, false} {
+		for a := 0; a < c.Size; a++ {
+			runColor := false
+			run := 0
+			var history [7]int
+			for b := 0; b < c.Size; b++ {
+				x, y := b, a
+				if !horizontal {
+					x, y = a, b
+				}
+				if c.Dark(x, y) == runColor {
+					run++
+					if run == 5 {
+						result += n1
+					} else if run > 5 {
+						result++
+					}
+					continue
+				}
+				c.addFinderHistory(run, &history)
+				if !runColor {
+					result += countFinderPatterns(history) * n3
+				}
+				runColor = c.Dark(x, y)
+				run = 1
+			}
+			if runColor {
+				c.addFinderHistory(run, &history)
+				run = 0
+			}
+			c.addFinderHistory(run+c.Size, &history)
+			result += countFinderPatterns(history) * n3
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			color := c.Dark(x, y)
+			if color {
+				dark++
+			}
+			if x < c.Size-1 && y < c.Size-1 && color == c.Dark(x+1, y) && color == c.Dark(x, y+1) && color == c.Dark(x+1, y+1) {
+				result += n2
+			}
+		}
+	}
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*n4
+}
+
+// addFinderHistory adds a run to the history of run lengths, newest first.
+// The light quiet zone is added to the first run.
+func (c *Code) addFinderHistory(run int, history *[7]int) {
+	if history[0] == 0 {
+		run += c.Size
+	}
+	copy(history[1:], history[:6])
+	history[0] = run
+}
+
+// countFinderPatterns counts the dark-light-dark-light-dark runs with the
+// ratio 1:1:3:1:1 that have four light modules on one side.
+func countFinderPatterns(history [7]int) int {
+	n := history[1]
+	core := n > 0 && history[2] == n && history[3] == n*3 && history[4] == n && history[5] == n
+	count := 0
+	if core && history[0] >= n*4 && history[6] >= n {
+		count++
+	}
+	if core && history[6] >= n*4 && history[0] >= n {
+		count++
+	}
+	return count
+}
+
+// alignmentPositions returns the centers of the alignment patterns on both
+// axes.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	positions := make([]int, n)
+	positions[0] = 6
+	for i, pos := n-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// numRawDataModules is the number of modules for data and error correction
+// of a version, after all function patterns.
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		n := version/7 + 2
+		result -= (25*n-10)*n - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numECCBlocks[level][version]
+}
+
+// charCountBits is the length of the character count of byte mode.
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// reedSolomonDivisor returns the generator polynomial of degree, without
+// its leading coefficient, highest power first.
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// reedSolomonRemainder returns the error correction codewords of data.
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, bit(value, i))
+	}
+}
+
+func bit(x, i int) bool {
+	return (x>>i)&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,240 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// HELLO WORLD as 1-M, from the worked example of the standard
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := reedSolomonRemainder(data, reedSolomonDivisor(10))
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if !bytes.Equal(ecc, expected) {
+		t.Errorf("expected %v, got %v", expected, ecc)
+	}
+}
+
+func TestFormatAndVersionInfo(t *testing.T) {
+	formats := map[Level]int{
+		Low:      0b111011111000100,
+		Medium:   0b101010000010010,
+		Quartile: 0b011010101011111,
+		High:     0b001011010001001,
+	}
+	for level, expected := range formats {
+		if got := formatInfo(level, 0); got != expected {
+			t.Errorf("format info of %s: expected %015b, got %015b", level, expected, got)
+		}
+	}
+	if got := formatInfo(Medium, 5); got != 0b100000011001110 {
+		t.Errorf("format info of M mask 5: got %015b", got)
+	}
+	if got := versionInfo(7); got != 0b000111110010010100 {
+		t.Errorf("version info of 7: got %018b", got)
+	}
+	if got := versionInfo(40); got != 0b101000110001101001 {
+		t.Errorf("version info of 40: got %018b", got)
+	}
+}
+
+func TestTables(t *testing.T) {
+	capacities := []struct {
+		version int
+		level   Level
+		data    int
+	}{
+		{1, Low, 19}, {1, High, 9}, {5, Quartile, 62}, {10, Medium, 216},
+		{27, Quartile, 808}, {40, Low, 2956}, {40, High, 1276},
+	}
+	for _, c := range capacities {
+		if got := numDataCodewords(c.version, c.level); got != c.data {
+			t.Errorf("%d-%s: expected %d data codewords, got %d", c.version, c.level, c.data, got)
+		}
+	}
+	alignments := map[int]string{1: "[]", 2: "[6 18]", 7: "[6 22 38]", 32: "[6 34 60 86 112 138]", 40: "[6 30 58 86 114 142 170]"}
+	for version, expected := range alignments {
+		if got := fmt.Sprint(alignmentPositions(version)); got != expected {
+			t.Errorf("alignment of %d: expected %s, got %s", version, expected, got)
+		}
+	}
+}
+
+// TestEncode reads the codewords back from the modules and checks them.
+func TestEncode(t *testing.T) {
+	inputs := []struct {
+		data    string
+		level   Level
+		version int
+	}{
+		{"https://go.example.com/docs", Medium, 3},
+		{"https://example.com/" + strings.Repeat("a", 100), High, 11},
+		{strings.Repeat("0123456789", 30), Quartile, 16},
+	}
+	for _, in := range inputs {
+		code, err := Encode(in.data, in.level)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if code.Version != in.version || code.Size != in.version*4+17 {
+			t.Errorf("expected version %d, got %d", in.version, code.Version)
+		}
+		decoded := readBack(t, code)
+		if decoded != in.data {
+			t.Errorf("expected %q, got %q", in.data, decoded)
+		}
+	}
+
+	_, err := Encode(strings.Repeat("x", 3000), Low)
+	if err == nil {
+		t.Error("expected an error for data that doesn't fit")
+	}
+}
+
+// readBack undoes the mask, reads the codewords in placement order,
+// checks the error correction of every block and returns the data.
+func readBack(t *testing.T, c *Code) string {
+	t.Helper()
+	if formatInfo(c.Level, c.Mask) != readFormat(c) {
+		t.Fatalf("unexpected format bits")
+	}
+	unmasked := *c
+	unmasked.modules = append([]bool(nil), c.modules...)
+	unmasked.applyMask(c.Mask)
+
+	raw := make([]byte, numRawDataModules(c.Version)/8)
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y*c.Size+x] && i < len(raw)*8 {
+					if unmasked.modules[y*c.Size+x] {
+						raw[i>>3] |= 1 << (7 - i&7)
+					}
+					i++
+				}
+			}
+		}
+	}
+
+	numBlocks := numECCBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShortBlocks := numBlocks - len(raw)%numBlocks
+	shortBlockLen := len(raw) / numBlocks
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for col := 0; col <= shortBlockLen; col++ {
+		for j := range blocks {
+			if col != shortBlockLen-eccLen || j >= numShortBlocks {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for _, block := range blocks {
+		n := len(block) - eccLen
+		ecc := reedSolomonRemainder(block[:n], reedSolomonDivisor(eccLen))
+		if !bytes.Equal(ecc, block[n:]) {
+			t.Fatalf("invalid error correction of block %v", block)
+		}
+		data = append(data, block[:n]...)
+	}
+
+	if data[0]>>4 != 0b0100 {
+		t.Fatalf("expected byte mode, got %04b", data[0]>>4)
+	}
+	readBits := func(offset, n int) int {
+		v := 0
+		for i := offset; i < offset+n; i++ {
+			v = v<<1 | int(data[i>>3]>>(7-i&7))&1
+		}
+		return v
+	}
+	count := readBits(4, charCountBits(c.Version))
+	var out []byte
+	for i := 0; i < count; i++ {
+		out = append(out, byte(readBits(4+charCountBits(c.Version)+8*i, 8)))
+	}
+	return string(out)
+}
+
+func readFormat(c *Code) int {
+	bits := 0
+	for i := 0; i <= 5; i++ {
+		if c.Dark(8, i) {
+			bits |= 1 << i
+		}
+	}
+	for i, p := range [][2]int{{8, 7}, {8, 8}, {7, 8}} {
+		if c.Dark(p[0], p[1]) {
+			bits |= 1 << (6 + i)
+		}
+	}
+	for i := 9; i < 15; i++ {
+		if c.Dark(14-i, 8) {
+			bits |= 1 << i
+		}
+	}
+	// the second copy has to be the same
+	second := 0
+	for i := 0; i < 8; i++ {
+		if c.Dark(c.Size-1-i, 8) {
+			second |= 1 << i
+		}
+	}
+	for i := 8; i < 15; i++ {
+		if c.Dark(8, c.Size-15+i) {
+			second |= 1 << i
+		}
+	}
+	if bits != second {
+		return -1
+	}
+	return bits
+}
+
+func TestRender(t *testing.T) {
+	code, err := Encode("https://example.com/docs", Medium)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var buf bytes.Buffer
+	err = code.PNG(&buf, 300, DefaultMargin)
+	if err != nil {
+		t.Fatalf("PNG failed: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode failed: %v", err)
+	}
+	modules := code.Size + 2*DefaultMargin
+	scale := 300 / modules
+	if img.Bounds().Dx() != modules*scale {
+		t.Errorf("expected %d pixels, got %d", modules*scale, img.Bounds().Dx())
+	}
+	// the top left module of the finder pattern is dark
+	if r, _, _, _ := img.At(DefaultMargin*scale, DefaultMargin*scale).RGBA(); r != 0 {
+		t.Error("expected a dark finder pattern")
+	}
+
+	buf.Reset()
+	err = code.SVG(&buf, 256, 0)
+	if err != nil {
+		t.Fatalf("SVG failed: %v", err)
+	}
+	svg := buf.String()
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, fmt.Sprintf(`viewBox="0 0 %d %d"`, code.Size, code.Size)) || !strings.Contains(svg, "M0 0h1v1h-1z") {
+		t.Errorf("unexpected svg %.200s", svg)
+	}
+}
@@ -0,0 +1,57 @@
+package qr
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMargin is the width of the quiet zone in modules that the
+// standard asks for.
+const DefaultMargin = 4
+
+// Image renders the code with a quiet zone of margin modules. Every module
+// is an integer number of pixels wide, so the image is at most size pixels
+// wide, but at least one pixel per module.
+func (c *Code) Image(size, margin int) image.Image {
+	modules := c.Size + 2*margin
+	scale := max(size/modules, 1)
+	palette := color.Palette{color.White, color.Black}
+	img := image.NewPaletted(image.Rect(0, 0, modules*scale, modules*scale), palette)
+	for y := 0; y < modules*scale; y++ {
+		for x := 0; x < modules*scale; x++ {
+			if c.Dark(x/scale-margin, y/scale-margin) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return img
+}
+
+// PNG writes the code as a PNG image, see Image.
+func (c *Code) PNG(w io.Writer, size, margin int) error {
+	return errors.Wrap(png.Encode(w, c.Image(size, margin)), "png.Encode")
+}
+
+// SVG writes the code as an SVG image of size pixels with a quiet zone of
+// margin modules. It scales without loss, so size is only the default.
+func (c *Code) SVG(w io.Writer, size, margin int) error {
+	modules := c.Size + 2*margin
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, modules, modules)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				fmt.Fprintf(bw, "M%d %dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+	fmt.Fprint(bw, `"/></svg>`)
+	return errors.Wrap(bw.Flush(), "write svg")
+}
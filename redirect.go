@@ -0,0 +1,95 @@
+package foundation
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// RedirectStatuses are the status codes that a link can redirect with.
+var RedirectStatuses = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+// RedirectOptions control how a visit of a link is redirected to its
+// destination.
+type RedirectOptions struct {
+	// RedirectStatus is one of RedirectStatuses. Zero means 302 Found.
+	RedirectStatus int `bun:"redirect_status,notnull" json:"redirect_status"`
+	// ForwardQuery adds the query parameters of the visit to the
+	// destination, unless the destination already sets them.
+	ForwardQuery bool `bun:"forward_query,notnull" json:"forward_query"`
+	// ForwardPath appends the rest of the path after the short link to the
+	// destination, so that /docs/api goes to the destination + /api.
+	ForwardPath bool `bun:"forward_path,notnull" json:"forward_path"`
+	// UTMSource and UTMCampaign are added to the destination as utm_source
+	// and utm_campaign. {short_link} and {variant} are replaced with the
+	// short link and the variant of the targeting rule.
+	UTMSource   string `bun:"utm_source,notnull" json:"utm_source"`
+	UTMCampaign string `bun:"utm_campaign,notnull" json:"utm_campaign"`
+	// Preview shows a page with the destination and a button to continue
+	// instead of redirecting right away.
+	Preview bool `bun:"preview,notnull" json:"preview"`
+}
+
+// Status returns the status code of the redirect.
+func (o *RedirectOptions) Status() int {
+	if o.RedirectStatus == 0 {
+		return http.StatusFound
+	}
+	return o.RedirectStatus
+}
+
+// Destination returns the URL that a visit of shortLink is redirected to.
+// target is the FullURL of the link or of the chosen rule with variant,
+// suffix the rest of the escaped path after the short link, and query the
+// query parameters of the visit. The parameters of target always win over
+// the UTM parameters, which win over forwarded ones.
+func (o *RedirectOptions) Destination(target, shortLink, variant, suffix string, query url.Values) (string, error) {
+	if (suffix == "" || !o.ForwardPath) && (len(query) == 0 || !o.ForwardQuery) && o.UTMSource == "" && o.UTMCampaign == "" {
+		return target, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+
+	if o.ForwardPath && suffix != "" {
+		// cleaning a rooted path keeps the suffix below the destination
+		clean := strings.TrimPrefix(path.Clean("/"+suffix), "/")
+		if strings.HasSuffix(suffix, "/") && clean != "" {
+			clean += "/"
+		}
+		u = u.JoinPath(clean)
+	}
+
+	params := u.Query()
+	added := false
+	add := func(key, value string) {
+		if value == "" || params.Has(key) {
+			return
+		}
+		params.Set(key, value)
+		added = true
+	}
+	replacer := strings.NewReplacer("{short_link}", shortLink, "{variant}", variant)
+	add("utm_source", replacer.Replace(o.UTMSource))
+	add("utm_campaign", replacer.Replace(o.UTMCampaign))
+	if o.ForwardQuery {
+		for key, values := range query {
+			if params.Has(key) {
+				continue
+			}
+			params[key] = values
+			added = true
+		}
+	}
+	if added {
+		u.RawQuery = params.Encode()
+	}
+	return u.String(), nil
+}
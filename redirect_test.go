@@ -0,0 +1,46 @@
+package foundation
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRedirectDestination(t *testing.T) {
+	cases := []struct {
+		name    string
+		options RedirectOptions
+		target  string
+		suffix  string
+		query   string
+		expect  string
+	}{
+		{name: "unchanged", target: "https://example.com/a?x=1", suffix: "v2", query: "y=2", expect: "https://example.com/a?x=1"},
+		{name: "path", options: RedirectOptions{ForwardPath: true}, target: "https://example.com/docs/", suffix: "api/v2", expect: "https://example.com/docs/api/v2"},
+		{name: "path without base", options: RedirectOptions{ForwardPath: true}, target: "https://example.com", suffix: "api/", expect: "https://example.com/api/"},
+		{name: "path traversal", options: RedirectOptions{ForwardPath: true}, target: "https://example.com/docs", suffix: "../../admin", expect: "https://example.com/docs/admin"},
+		{name: "escaped path", options: RedirectOptions{ForwardPath: true}, target: "https://example.com/docs", suffix: "a%20b", expect: "https://example.com/docs/a%20b"},
+		{name: "query", options: RedirectOptions{ForwardQuery: true}, target: "https://example.com/?x=1", query: "x=2&y=3", expect: "https://example.com/?x=1&y=3"},
+		{name: "utm", options: RedirectOptions{UTMSource: "go", UTMCampaign: "{short_link}-{variant}"}, target: "https://example.com/", expect: "https://example.com/?utm_campaign=docs-b&utm_source=go"},
+		{name: "utm from target", options: RedirectOptions{ForwardQuery: true, UTMSource: "go"}, target: "https://example.com/?utm_source=mail", query: "utm_source=x&utm_medium=y", expect: "https://example.com/?utm_medium=y&utm_source=mail"},
+	}
+	for _, c := range cases {
+		query, err := url.ParseQuery(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.options.Destination(c.target, "docs", "b", c.suffix, query)
+		if err != nil {
+			t.Errorf("%s: Destination failed: %v", c.name, err)
+			continue
+		}
+		if got != c.expect {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expect, got)
+		}
+	}
+
+	var options RedirectOptions
+	if options.Status() != http.StatusFound {
+		t.Errorf("expected 302 by default, got %d", options.Status())
+	}
+}
@@ -3,6 +3,15 @@ package broadcast
 import (
 	"errors"
 	"sync"
+
+	"github.com/mbertschler/foundation/metrics"
+)
+
+var (
+	listenersGauge = metrics.NewGaugeVec("foundation_broadcast_listeners",
+		"Number of active listeners per broadcast channel.", "channel")
+	dropsTotal = metrics.NewCounterVec("foundation_broadcast_drops_total",
+		"Number of messages that could not be delivered to a busy listener.", "channel")
 )
 
 type Broadcaster struct {
@@ -11,6 +20,7 @@ type Broadcaster struct {
 }
 
 type channel struct {
+	name      string
 	mutex     sync.RWMutex
 	lastID    int
 	listeners map[int]*Listener
@@ -40,6 +50,7 @@ func (b *Broadcaster) Send(chanName string) error {
 		case l.C <- struct{}{}:
 		default:
 			allSent = false
+			dropsTotal.WithLabelValues(chanName).Inc()
 		}
 	}
 
@@ -63,6 +74,7 @@ func (b *Broadcaster) Listen(chanName string) *Listener {
 	c := b.channels[chanName]
 	if c == nil {
 		c = &channel{
+			name:      chanName,
 			listeners: make(map[int]*Listener),
 		}
 		b.channels[chanName] = c
@@ -73,11 +85,14 @@ func (b *Broadcaster) Listen(chanName string) *Listener {
 	c.lastID++
 
 	l := &Listener{
-		id:      c.lastID,
-		C:       make(chan struct{}),
+		id: c.lastID,
+		// a busy listener keeps one message, so it doesn't miss the last
+		// change while it handles the previous one
+		C:       make(chan struct{}, 1),
 		channel: c,
 	}
 	c.listeners[l.id] = l
+	listenersGauge.WithLabelValues(chanName).Inc()
 	return l
 }
 
@@ -86,4 +101,5 @@ func (l *Listener) Close() {
 	defer l.channel.mutex.Unlock()
 	delete(l.channel.listeners, l.id)
 	close(l.C)
+	listenersGauge.WithLabelValues(l.channel.name).Dec()
 }
@@ -0,0 +1,28 @@
+package server
+
+import "net/http"
+
+// DefaultSecurityHeaders are set on every response if the SecurityHeaders
+// config value is not set.
+var DefaultSecurityHeaders = map[string]string{
+	"X-Content-Type-Options": "nosniff",
+	"X-Frame-Options":        "DENY",
+	"Referrer-Policy":        "strict-origin-when-cross-origin",
+}
+
+// securityHeaders sets the configured security headers. The config is read
+// for every request, so that changes apply after a config reload.
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers := s.ctx.Current().SecurityHeaders
+		if headers == nil {
+			headers = DefaultSecurityHeaders
+		}
+		for name, value := range headers {
+			if value != "" {
+				w.Header().Set(name, value)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,181 @@
+// Package health implements liveness and readiness endpoints backed by a
+// list of named dependency checks.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckTimeout bounds how long a single readiness check may take.
+var CheckTimeout = 5 * time.Second
+
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Component is a part of the service that has a readiness check and
+// reports status details for the admin status page.
+type Component interface {
+	Check(ctx context.Context) error
+	Status() []Field
+}
+
+type Field struct {
+	Name  string
+	Value string
+}
+
+type ComponentStatus struct {
+	Name   string
+	Fields []Field
+}
+
+type component struct {
+	name string
+	c    Component
+}
+
+type Checker struct {
+	mutex        sync.RWMutex
+	checks       []check
+	components   []component
+	shuttingDown atomic.Bool
+}
+
+func New() *Checker {
+	return &Checker{}
+}
+
+// Add registers a readiness check. Checks run in the order they were added.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// AddComponent registers the check of comp and makes its status details
+// available through Components.
+func (c *Checker) AddComponent(name string, comp Component) {
+	c.Add(name, comp.Check)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.components = append(c.components, component{name: name, c: comp})
+}
+
+// Components returns the current status details of all registered components.
+func (c *Checker) Components() []ComponentStatus {
+	c.mutex.RLock()
+	components := append([]component(nil), c.components...)
+	c.mutex.RUnlock()
+
+	var statuses []ComponentStatus
+	for _, comp := range components {
+		statuses = append(statuses, ComponentStatus{
+			Name:   comp.name,
+			Fields: comp.c.Status(),
+		})
+	}
+	return statuses
+}
+
+// SetShuttingDown makes all following readiness checks fail, so that load
+// balancers stop sending traffic while the server drains.
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+type Status struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Ready runs all checks and reports whether every one of them passed.
+func (c *Checker) Ready(ctx context.Context) (*Status, bool) {
+	c.mutex.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mutex.RUnlock()
+
+	status := &Status{Status: "ready"}
+	ready := true
+	for _, ch := range checks {
+		result := runCheck(ctx, ch)
+		if result.Status != "ok" {
+			ready = false
+		}
+		status.Checks = append(status.Checks, result)
+	}
+
+	if c.shuttingDown.Load() {
+		status.Status = "shutting_down"
+		return status, false
+	}
+	if !ready {
+		status.Status = "not_ready"
+	}
+	return status, ready
+}
+
+func runCheck(ctx context.Context, ch check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ch.fn(ctx)
+	result := CheckResult{
+		Name:       ch.name,
+		Status:     "ok",
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler only confirms that the process is able to serve requests.
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, &Status{Status: "ok"})
+	})
+}
+
+// ReadinessHandler runs all checks and answers 503 if any of them failed
+// or the server is shutting down.
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, ready := c.Ready(r.Context())
+		code := http.StatusOK
+		if !ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, status)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Println("health writeJSON error:", err)
+	}
+}
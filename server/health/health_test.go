@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	c := New()
+	c.Add("ok", func(ctx context.Context) error { return nil })
+
+	status, code := readyz(t, c)
+	if code != http.StatusOK || status.Status != "ready" {
+		t.Fatalf("expected ready, got %d %q", code, status.Status)
+	}
+
+	c.Add("broken", func(ctx context.Context) error { return errors.New("boom") })
+
+	status, code = readyz(t, c)
+	if code != http.StatusServiceUnavailable || status.Status != "not_ready" {
+		t.Fatalf("expected not ready, got %d %q", code, status.Status)
+	}
+	if len(status.Checks) != 2 {
+		t.Fatalf("expected 2 check results, got %d", len(status.Checks))
+	}
+	if status.Checks[1].Name != "broken" || status.Checks[1].Error != "boom" {
+		t.Errorf("unexpected check result: %+v", status.Checks[1])
+	}
+}
+
+func TestShuttingDown(t *testing.T) {
+	c := New()
+	c.Add("ok", func(ctx context.Context) error { return nil })
+	c.SetShuttingDown()
+
+	status, code := readyz(t, c)
+	if code != http.StatusServiceUnavailable || status.Status != "shutting_down" {
+		t.Fatalf("expected shutting_down, got %d %q", code, status.Status)
+	}
+
+	rec := httptest.NewRecorder()
+	c.LivenessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("liveness should stay ok during shutdown, got %d", rec.Code)
+	}
+}
+
+func readyz(t *testing.T, c *Checker) (*Status, int) {
+	rec := httptest.NewRecorder()
+	c.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+	var status Status
+	err := json.Unmarshal(rec.Body.Bytes(), &status)
+	if err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return &status, rec.Code
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbertschler/foundation/metrics"
+	"github.com/pkg/errors"
+)
+
+var (
+	httpRequestsTotal = metrics.NewCounterVec("foundation_http_requests_total",
+		"Number of HTTP requests by route and status code.", "method", "route", "code")
+	httpRequestDuration = metrics.NewHistogramVec("foundation_http_request_duration_seconds",
+		"Duration of HTTP requests by route.", nil, "method", "route")
+)
+
+// instrument records the count and duration of requests to route.
+func instrument(route string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := NewStatusWriter(w)
+			next.ServeHTTP(sw, r)
+
+			httpRequestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(sw.Status())).Inc()
+			httpRequestDuration.WithLabelValues(r.Method, route).ObserveSince(start)
+		})
+	}
+}
+
+// setupMetricsRoutes exposes /metrics on the main router if a token is
+// configured, and on a separate listener if MetricsHostPort is set.
+func (s *Server) setupMetricsRoutes() {
+	token := s.ctx.Config.MetricsToken
+	handler := metrics.Default.Handler()
+	if token != "" {
+		handler = requireBearerToken(token, handler)
+		s.router.Handler("GET", "/metrics", handler)
+	}
+
+	hostPort := s.ctx.Config.MetricsHostPort
+	if hostPort == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", handler)
+	s.metricsServer = &http.Server{
+		Addr:    hostPort,
+		Handler: mux,
+	}
+}
+
+// runMetrics starts the separate metrics listener, if it is configured.
+// Errors binding the address are returned, later errors are only logged.
+func (s *Server) runMetrics() error {
+	if s.metricsServer == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.metricsServer.Addr)
+	if err != nil {
+		return errors.Wrap(err, "metrics Listen")
+	}
+	log.Printf("serving metrics on http://%s/metrics", s.metricsServer.Addr)
+	go func() {
+		err := s.metricsServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("metrics Serve error:", err)
+		}
+	}()
+	return nil
+}
+
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
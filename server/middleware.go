@@ -0,0 +1,124 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/metrics"
+)
+
+var httpPanicsTotal = metrics.NewCounter("foundation_http_panics_total",
+	"Number of panics recovered in HTTP handlers.")
+
+// Middleware wraps a handler with behavior that runs before and after it.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with the middlewares, the first one being the outermost.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Use adds middlewares that run for every request, after the ones that were
+// added before. It must be called before Run.
+func (s *Server) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+	s.handler = Chain(s.router, s.middlewares...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// handle registers a route on the router. The route is instrumented and
+// wrapped by the given middlewares, which can read the route parameters
+// with httprouter.ParamsFromContext.
+func (s *Server) handle(method, path string, handle httprouter.Handle, middlewares ...Middleware) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, httprouter.ParamsFromContext(r.Context()))
+	})
+	middlewares = append([]Middleware{instrument(path)}, middlewares...)
+	s.router.Handler(method, path, Chain(h, middlewares...))
+}
+
+// StatusWriter records the status code written by a handler. It is shared
+// by all middlewares of a request, see NewStatusWriter.
+type StatusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// NewStatusWriter wraps w, or returns it if it already is a StatusWriter.
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	if sw, ok := w.(*StatusWriter); ok {
+		return sw
+	}
+	return &StatusWriter{ResponseWriter: w}
+}
+
+func (w *StatusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *StatusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Status returns the written status code, or 200 if nothing was written.
+func (w *StatusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Written reports whether the response header was already sent.
+func (w *StatusWriter) Written() bool {
+	return w.status != 0
+}
+
+// Flush implements http.Flusher, which SSE streams rely on.
+func (w *StatusWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *StatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// recoverPanics logs panics in handlers and renders an error page instead,
+// if the response wasn't started yet.
+func (s *Server) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := NewStatusWriter(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			httpPanicsTotal.Inc()
+			log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+			s.writeError(sw, r, foundation.NewHTTPError(http.StatusInternalServerError, "", nil))
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
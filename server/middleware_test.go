@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mbertschler/foundation"
+	"github.com/pkg/errors"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mark("a"), mark("b"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got := strings.Join(order, ","); got != "a,b,handler" {
+		t.Errorf("unexpected order %s", got)
+	}
+}
+
+func TestRouteMiddlewareAndParams(t *testing.T) {
+	srv := &Server{router: httprouter.New()}
+	srv.Use()
+
+	var param string
+	setHeader := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Route", "yes")
+			next.ServeHTTP(w, r)
+		})
+	}
+	srv.handle("GET", "/items/:id", func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		param = params.ByName("id")
+		w.WriteHeader(http.StatusAccepted)
+	}, setHeader)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/items/42", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected status 202, got %d", rec.Code)
+	}
+	if param != "42" {
+		t.Errorf("expected param 42, got %q", param)
+	}
+	if rec.Header().Get("X-Route") != "yes" {
+		t.Error("route middleware didn't run")
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	srv := &Server{
+		ctx:    &foundation.Context{Context: context.Background(), Config: &foundation.Config{}},
+		router: httprouter.New(),
+	}
+	srv.Use(srv.recoverPanics)
+	srv.handle("GET", "/panic", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestStatusWriterFlusher(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := NewStatusWriter(rec)
+	if NewStatusWriter(sw) != sw {
+		t.Error("expected an existing StatusWriter to be reused")
+	}
+
+	var w http.ResponseWriter = sw
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("StatusWriter should implement http.Flusher")
+	}
+	flusher.Flush()
+	if !rec.Flushed {
+		t.Error("expected the underlying writer to be flushed")
+	}
+	if !sw.Written() || sw.Status() != http.StatusOK {
+		t.Errorf("expected status 200 after flush, got %d", sw.Status())
+	}
+}
+
+func TestRenderErrorStatus(t *testing.T) {
+	srv := &Server{
+		ctx: &foundation.Context{Context: context.Background(), Config: &foundation.Config{}},
+	}
+	testCases := []struct {
+		err    error
+		status int
+	}{
+		{errors.Wrap(foundation.NewHTTPError(http.StatusNotFound, "Link not found", nil), "patchLink"), http.StatusNotFound},
+		{foundation.NewHTTPError(http.StatusGone, "", nil), http.StatusGone},
+		{errors.New("Users.ByID: database is locked"), http.StatusInternalServerError},
+	}
+	for _, tc := range testCases {
+		rec := httptest.NewRecorder()
+		srv.renderError(rec, httptest.NewRequest("GET", "/", nil), tc.err)
+		if rec.Code != tc.status {
+			t.Errorf("expected status %d for %q, got %d", tc.status, tc.err, rec.Code)
+		}
+	}
+
+	// a handler that already started the response keeps its status
+	rec := httptest.NewRecorder()
+	sw := NewStatusWriter(rec)
+	sw.WriteHeader(http.StatusAccepted)
+	srv.renderError(sw, httptest.NewRequest("GET", "/", nil), errors.New("late"))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected status 202, got %d", rec.Code)
+	}
+}
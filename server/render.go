@@ -10,8 +10,6 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/mbertschler/foundation"
-	"github.com/mbertschler/foundation/auth"
-	"github.com/mbertschler/foundation/db"
 	"github.com/mbertschler/foundation/pages"
 	"github.com/mbertschler/html"
 )
@@ -50,7 +48,7 @@ func (s *Server) renderPage(ctx *foundation.Context, fn pages.PageFunc, opts ...
 
 func (s *Server) renderSSEStreamOnChannel(ctx *foundation.Context, chanName string, fn pages.FrameFunc, opts ...RenderOption) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-		req := prepareRequest(ctx, s.auth, s.db, w, r, params, opts...)
+		req := s.prepareRequest(ctx, w, r, params, opts...)
 		if req == nil {
 			return
 		}
@@ -63,7 +61,7 @@ func (s *Server) renderSSEStreamOnChannel(ctx *foundation.Context, chanName stri
 
 		flusher, ok := w.(http.Flusher)
 		if !ok {
-			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			s.renderError(w, r, errors.New("streaming unsupported"))
 			return
 		}
 		flusher.Flush()
@@ -76,17 +74,16 @@ func (s *Server) renderSSEStreamOnChannel(ctx *foundation.Context, chanName stri
 			case <-r.Context().Done():
 				return
 			case <-listener.C:
+				// the stream has started, so errors can only be logged
 				block, err := fn(req)
 				if err != nil {
 					log.Println("Block fn error:", err)
-					http.Error(w, err.Error(), http.StatusInternalServerError)
 					return
 				}
 
 				rendered, err := html.RenderString(block)
 				if err != nil {
 					log.Println("Render error:", err)
-					http.Error(w, err.Error(), http.StatusInternalServerError)
 					return
 				}
 				lines := strings.Split(rendered, "\n")
@@ -108,7 +105,7 @@ func (s *Server) renderSSEStreamOnChannel(ctx *foundation.Context, chanName stri
 
 func (s *Server) renderFrame(ctx *foundation.Context, fn pages.FrameFunc, opts ...RenderOption) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-		req := prepareRequest(ctx, s.auth, s.db, w, r, params, opts...)
+		req := s.prepareRequest(ctx, w, r, params, opts...)
 		if req == nil {
 			return
 		}
@@ -119,19 +116,52 @@ func (s *Server) renderFrame(ctx *foundation.Context, fn pages.FrameFunc, opts .
 
 		block, err := fn(req)
 		if err != nil {
-			log.Println("Render error:", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			s.renderError(w, r, err)
 			return
 		}
 
 		err = html.Render(w, block)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			// the response has started, so it's too late for an error page
+			log.Println("Render error:", err)
 		}
 	}
 }
 
-func prepareRequest(ctx *foundation.Context, authHandler *auth.Handler, database *db.DB, w http.ResponseWriter, r *http.Request, params httprouter.Params, opts ...RenderOption) *foundation.Request {
+// renderError logs err and renders an error page, or an error frame for
+// turbo frame requests. Users only see the public message of an HTTPError.
+func (s *Server) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("%s %s error: %v", r.Method, r.URL.Path, err)
+	s.writeError(w, r, foundation.AsHTTPError(err))
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, httpErr *foundation.HTTPError) {
+	if sw, ok := w.(*StatusWriter); ok && sw.Written() {
+		// a handler wrote the response before failing
+		return
+	}
+
+	req := &foundation.Request{
+		Context: s.ctx,
+		Writer:  w,
+		Request: r,
+	}
+	var block html.Block
+	if frameID := r.Header.Get("Turbo-Frame"); frameID != "" {
+		block = pages.ErrorFrame(frameID, httpErr.Status, httpErr.Message)
+	} else {
+		block = pages.ErrorPage(httpErr.Status, httpErr.Message).RenderHTML(req)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(httpErr.Status)
+	err := html.Render(w, block)
+	if err != nil {
+		log.Println("writeError render error:", err)
+	}
+}
+
+func (s *Server) prepareRequest(ctx *foundation.Context, w http.ResponseWriter, r *http.Request, params httprouter.Params, opts ...RenderOption) *foundation.Request {
 	req := &foundation.Request{
 		Context: ctx,
 		Writer:  w,
@@ -139,10 +169,9 @@ func prepareRequest(ctx *foundation.Context, authHandler *auth.Handler, database
 		Params:  params,
 	}
 
-	sess, err := authHandler.GetOrCreateSession(req)
+	sess, err := s.auth.GetOrCreateSession(req)
 	if err != nil {
-		log.Println("GetOrCreateSession error:", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		s.renderError(w, r, fmt.Errorf("GetOrCreateSession: %w", err))
 		return nil
 	}
 	req.Session = sess
@@ -150,17 +179,16 @@ func prepareRequest(ctx *foundation.Context, authHandler *auth.Handler, database
 	// Verify CSRF token for state-changing requests
 	if requiresCSRFProtection(r.Method) {
 		if err := verifyCSRFToken(req); err != nil {
-			log.Println("CSRF verification failed:", err)
-			http.Error(w, "CSRF token verification failed", http.StatusForbidden)
+			s.renderError(w, r, foundation.NewHTTPError(http.StatusForbidden,
+				"The form has expired, please reload the page and try again.", err))
 			return nil
 		}
 	}
 
 	if sess.UserID.Valid {
-		user, err := database.Users.ByID(req.Context, sess.UserID.Int64)
+		user, err := s.db.Users.ByID(req.Context, sess.UserID.Int64)
 		if err != nil {
-			log.Println("Users.ByID error:", err)
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+			s.renderError(w, r, fmt.Errorf("Users.ByID: %w", err))
 			return nil
 		}
 		req.User = user
@@ -172,8 +200,7 @@ func prepareRequest(ctx *foundation.Context, authHandler *auth.Handler, database
 				return nil
 			}
 			if err != nil {
-				log.Println("BeforeRender error:", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				s.renderError(w, r, fmt.Errorf("BeforeRender: %w", err))
 				return nil
 			}
 		}
@@ -192,9 +219,13 @@ func requiresCSRFProtection(method string) bool {
 	}
 }
 
-// verifyCSRFToken verifies the CSRF token from the X-CSRF-TOKEN header
+// verifyCSRFToken verifies the CSRF token from the X-CSRF-TOKEN header, or
+// from the csrf_token field of forms that are submitted without Turbo.
 func verifyCSRFToken(req *foundation.Request) error {
 	token := req.Request.Header.Get("X-CSRF-TOKEN")
+	if token == "" {
+		token = req.Request.PostFormValue(pages.CSRFFormKey)
+	}
 	if token == "" {
 		return errors.New("missing CSRF token header")
 	}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"log"
+	"net"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
@@ -10,64 +12,179 @@ import (
 	"github.com/mbertschler/foundation/db"
 	"github.com/mbertschler/foundation/pages"
 	"github.com/mbertschler/foundation/server/broadcast"
+	"github.com/mbertschler/foundation/server/health"
 	"github.com/pkg/errors"
 )
 
 type Server struct {
-	ctx       *foundation.Context
-	db        *db.DB
-	broadcast *broadcast.Broadcaster
-	router    *httprouter.Router
-	pages     *pages.Handler
-	auth      *auth.Handler
+	ctx        *foundation.Context
+	db         *db.DB
+	broadcast  *broadcast.Broadcaster
+	health     *health.Checker
+	router     *httprouter.Router
+	pages      *pages.Handler
+	auth       *auth.Handler
+	httpServer *http.Server
+	// middlewares wrap the router for every request, see Use
+	middlewares []Middleware
+	handler     http.Handler
+	// redirectServer redirects plain HTTP to HTTPS, if configured
+	redirectServer *http.Server
+	// metricsServer serves /metrics on MetricsHostPort, if configured
+	metricsServer *http.Server
 }
 
-func RunServer(ctx *foundation.Context, database *db.DB, broadcaster *broadcast.Broadcaster) error {
+func NewServer(ctx *foundation.Context, database *db.DB, broadcaster *broadcast.Broadcaster, checker *health.Checker) (*Server, error) {
 	srv := &Server{
 		ctx:       ctx,
 		db:        database,
 		broadcast: broadcaster,
+		health:    checker,
 		router:    httprouter.New(),
-		pages:     pages.NewHandler(database, broadcaster),
+		pages:     pages.NewHandler(database, broadcaster, checker),
 		auth:      auth.NewHandler(database),
 	}
+	srv.Use(srv.recoverPanics, srv.securityHeaders)
 
 	srv.setupPageRoutes()
+	srv.setupMetricsRoutes()
+	srv.setupHealthRoutes()
 
 	err := srv.setupGeneralRoutes()
 	if err != nil {
-		return errors.Wrap(err, "setupGeneralRoutes")
+		return nil, errors.Wrap(err, "setupGeneralRoutes")
 	}
 
-	hostPort := srv.ctx.Config.HostPort
-	log.Printf("starting server on http://%s", hostPort)
-	return http.ListenAndServe(hostPort, srv.router)
+	tlsConfig, err := srv.tlsConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "tlsConfig")
+	}
+
+	// HTTP/2 is only used over TLS
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+
+	// requests derive their context from baseCtx, which is canceled on
+	// shutdown so that long running SSE streams end and don't block it
+	baseCtx, cancel := context.WithCancel(context.Background())
+	srv.httpServer = &http.Server{
+		Addr:        ctx.Config.HostPort,
+		Handler:     srv,
+		TLSConfig:   tlsConfig,
+		Protocols:   protocols,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	srv.httpServer.RegisterOnShutdown(cancel)
+
+	if ctx.Config.HTTPRedirectHostPort != "" {
+		srv.redirectServer = &http.Server{
+			Addr:    ctx.Config.HTTPRedirectHostPort,
+			Handler: httpsRedirect(ctx.Config.HostPort),
+		}
+	}
+	return srv, nil
+}
+
+// Run serves HTTP, or HTTPS if TLS is configured, until the server is
+// shut down.
+func (s *Server) Run() error {
+	err := s.runMetrics()
+	if err != nil {
+		return err
+	}
+	if s.redirectServer != nil {
+		go func() {
+			log.Printf("redirecting http://%s to HTTPS", s.redirectServer.Addr)
+			err := s.redirectServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Println("redirect ListenAndServe error:", err)
+			}
+		}()
+	}
+
+	if s.httpServer.TLSConfig != nil {
+		log.Printf("starting server on https://%s", s.httpServer.Addr)
+		err = s.httpServer.ListenAndServeTLS("", "")
+	} else {
+		log.Printf("starting server on http://%s", s.httpServer.Addr)
+		err = s.httpServer.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown gracefully stops the server, waiting for in flight requests
+// until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.redirectServer != nil {
+		err := s.redirectServer.Shutdown(ctx)
+		if err != nil {
+			log.Println("redirect Shutdown error:", err)
+		}
+	}
+	if s.metricsServer != nil {
+		err := s.metricsServer.Shutdown(ctx)
+		if err != nil {
+			log.Println("metrics Shutdown error:", err)
+		}
+	}
+	return s.httpServer.Shutdown(ctx)
 }
 
 func (s *Server) setupPageRoutes() {
-	s.router.Handler("GET", "/", http.RedirectHandler("/admin", http.StatusFound))
-	s.router.GET("/admin/login", s.renderPage(s.ctx, s.pages.LoginPage))
-	s.router.POST("/admin/login", s.renderPage(s.ctx, s.pages.LoginPage))
+	s.handle("GET", "/", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		http.Redirect(w, r, "/admin", http.StatusFound)
+	})
+	s.handle("GET", "/admin/login", s.renderPage(s.ctx, s.pages.LoginPage))
+	s.handle("POST", "/admin/login", s.renderPage(s.ctx, s.pages.LoginPage))
 	// not really a frame, just redirects or throws error
-	s.router.POST("/admin/logout", s.renderFrame(s.ctx, s.pages.LogoutFrame, RequireLogin()))
-
-	s.router.GET("/admin", s.renderPage(s.ctx, s.pages.LinksPage, RequireLogin()))
-	s.router.GET("/admin/links", s.renderPage(s.ctx, s.pages.LinksPage, RequireLogin()))
-	s.router.GET("/admin/frame/links/new", s.renderFrame(s.ctx, s.pages.LinkNewFrame, RequireLogin()))
-	s.router.GET("/admin/frame/links/update/:short_link", s.renderFrame(s.ctx, s.pages.LinkUpdateFrame, RequireLogin()))
-	s.router.POST("/admin/links", s.renderFrame(s.ctx, s.pages.LinksFrame, RequireLogin()))
-	s.router.PATCH("/admin/links/:short_link", s.renderFrame(s.ctx, s.pages.LinksFrame, RequireLogin()))
-	s.router.DELETE("/admin/links/:short_link", s.renderFrame(s.ctx, s.pages.LinksFrame, RequireLogin()))
-	s.router.GET("/admin/stream/links", s.renderSSEStreamOnChannel(s.ctx, "links", s.pages.LinksStream, RequireLogin()))
-	s.router.GET("/admin/users", s.renderPage(s.ctx, s.pages.UsersPage, RequireLogin()))
-	s.router.GET("/admin/frame/users/new", s.renderFrame(s.ctx, s.pages.UserNewFrame, RequireLogin()))
-	s.router.GET("/admin/frame/users/update/:id", s.renderFrame(s.ctx, s.pages.UserUpdateFrame, RequireLogin()))
-	s.router.POST("/admin/users", s.renderFrame(s.ctx, s.pages.UsersFrame, RequireLogin()))
-	s.router.PATCH("/admin/users/:id", s.renderFrame(s.ctx, s.pages.UsersFrame, RequireLogin()))
-	s.router.DELETE("/admin/users/:id", s.renderFrame(s.ctx, s.pages.UsersFrame, RequireLogin()))
+	s.handle("POST", "/admin/logout", s.renderFrame(s.ctx, s.pages.LogoutFrame, RequireLogin()))
+
+	s.handle("GET", "/admin", s.renderPage(s.ctx, s.pages.LinksPage, RequireLogin()))
+	s.handle("GET", "/admin/links", s.renderPage(s.ctx, s.pages.LinksPage, RequireLogin()))
+	s.handle("GET", "/admin/frame/links/new", s.renderFrame(s.ctx, s.pages.LinkNewFrame, RequireLogin()))
+	s.handle("GET", "/admin/frame/links/update/:short_link", s.renderFrame(s.ctx, s.pages.LinkUpdateFrame, RequireLogin()))
+	s.handle("POST", "/admin/links", s.renderFrame(s.ctx, s.pages.LinksFrame, RequireLogin()))
+	s.handle("PATCH", "/admin/links/:short_link", s.renderFrame(s.ctx, s.pages.LinksFrame, RequireLogin()))
+	s.handle("DELETE", "/admin/links/:short_link", s.renderFrame(s.ctx, s.pages.LinksFrame, RequireLogin()))
+	s.handle("POST", "/admin/links/:short_link/purge-visits", s.renderFrame(s.ctx, s.pages.LinkPurgeVisitsFrame, RequireLogin()))
+	s.handle("POST", "/admin/links/:short_link/revisions/:id/revert", s.renderFrame(s.ctx, s.pages.LinkRevertFrame, RequireLogin()))
+	s.handle("GET", "/admin/links/:short_link/qr.svg", s.renderFrame(s.ctx, s.pages.LinkQR, RequireLogin()))
+	s.handle("GET", "/admin/links/:short_link/qr.png", s.renderFrame(s.ctx, s.pages.LinkQR, RequireLogin()))
+	s.handle("GET", "/admin/export/links.csv", s.renderFrame(s.ctx, s.pages.LinksExport, RequireLogin()))
+	s.handle("GET", "/admin/export/links.json", s.renderFrame(s.ctx, s.pages.LinksExport, RequireLogin()))
+	s.handle("GET", "/admin/import/links", s.renderPage(s.ctx, s.pages.LinksImportPage, RequireLogin()))
+	s.handle("POST", "/admin/import/links", s.renderFrame(s.ctx, s.pages.LinksImportFrame, RequireLogin()))
+	s.handle("GET", "/admin/stream/links", s.renderSSEStreamOnChannel(s.ctx, "links", s.pages.LinksStream, RequireLogin()))
+	s.handle("GET", "/admin/users", s.renderPage(s.ctx, s.pages.UsersPage, RequireLogin()))
+	s.handle("GET", "/admin/frame/users/new", s.renderFrame(s.ctx, s.pages.UserNewFrame, RequireLogin()))
+	s.handle("GET", "/admin/frame/users/update/:id", s.renderFrame(s.ctx, s.pages.UserUpdateFrame, RequireLogin()))
+	s.handle("POST", "/admin/users", s.renderFrame(s.ctx, s.pages.UsersFrame, RequireLogin()))
+	s.handle("PATCH", "/admin/users/:id", s.renderFrame(s.ctx, s.pages.UsersFrame, RequireLogin()))
+	s.handle("DELETE", "/admin/users/:id", s.renderFrame(s.ctx, s.pages.UsersFrame, RequireLogin()))
+	s.handle("GET", "/admin/status", s.renderPage(s.ctx, s.pages.StatusPage, RequireLogin()))
+	s.handle("GET", "/admin/trash", s.renderPage(s.ctx, s.pages.TrashPage, RequireLogin()))
+	s.handle("POST", "/admin/trash/links/:short_link/restore", s.renderFrame(s.ctx, s.pages.TrashLinkFrame, RequireLogin()))
+	s.handle("DELETE", "/admin/trash/links/:short_link", s.renderFrame(s.ctx, s.pages.TrashLinkFrame, RequireLogin()))
+	s.handle("POST", "/admin/trash/users/:id/restore", s.renderFrame(s.ctx, s.pages.TrashUserFrame, RequireLogin()))
+	s.handle("DELETE", "/admin/trash/users/:id", s.renderFrame(s.ctx, s.pages.TrashUserFrame, RequireLogin()))
+	s.handle("GET", "/admin/audit", s.renderPage(s.ctx, s.pages.AuditPage, RequireLogin()))
+	s.handle("GET", "/admin/export/audit.csv", s.renderFrame(s.ctx, s.pages.AuditExport, RequireLogin()))
+	s.handle("GET", "/admin/export/audit.json", s.renderFrame(s.ctx, s.pages.AuditExport, RequireLogin()))
+	s.handle("GET", "/admin/backups", s.renderPage(s.ctx, s.pages.BackupsPage, RequireLogin()))
+	s.handle("POST", "/admin/backups", s.renderFrame(s.ctx, s.pages.BackupsFrame, RequireLogin()))
+	s.handle("GET", "/admin/backups/:name", s.renderFrame(s.ctx, s.pages.BackupDownload, RequireLogin()))
 
 	// short link handler as last route, catch all
-	s.router.NotFound = handlerFuncAdapter(s.renderFrame(s.ctx, s.pages.ShortLinkHandler))
+	s.router.NotFound = Chain(handlerFuncAdapter(s.renderFrame(s.ctx, s.pages.ShortLinkHandler)), instrument("/:short_link"))
+}
+
+func (s *Server) setupHealthRoutes() {
+	s.router.Handler("GET", "/healthz", s.health.LivenessHandler())
+	s.router.Handler("GET", "/readyz", s.health.ReadinessHandler())
 }
 
 func (s *Server) setupGeneralRoutes() error {
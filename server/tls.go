@@ -0,0 +1,237 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// changes during TLS handshakes.
+var certCheckInterval = 10 * time.Second
+
+// tlsConfig returns the TLS config for the main server, or nil if TLS is
+// not enabled.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	config := s.ctx.Config
+	if !config.TLSEnabled() {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config.TLSCertFile == "" {
+		certPEM, keyPEM, err := selfSignedCertificate(config.HostPort)
+		if err != nil {
+			return nil, errors.Wrap(err, "selfSignedCertificate")
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, errors.Wrap(err, "X509KeyPair")
+		}
+		log.Println("using an in-memory self-signed TLS certificate")
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		return tlsConfig, nil
+	}
+
+	if config.TLSSelfSigned {
+		err := writeSelfSignedIfMissing(config.HostPort, config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "writeSelfSignedIfMissing")
+		}
+	}
+	reloader, err := newCertReloader(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.GetCertificate = reloader.GetCertificate
+	return tlsConfig, nil
+}
+
+// certReloader serves a certificate from PEM files and loads it again when
+// the files change, so that renewed certificates don't need a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex    sync.Mutex
+	cert     *tls.Certificate
+	loadedAt time.Time // modification time of the loaded files
+	checked  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		checked:  time.Now(),
+	}
+	modTime, err := r.modTime()
+	if err != nil {
+		return nil, err
+	}
+	err = r.load(modTime)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if time.Since(r.checked) >= certCheckInterval {
+		r.checked = time.Now()
+		modTime, err := r.modTime()
+		if err == nil && !modTime.Equal(r.loadedAt) {
+			err = r.load(modTime)
+		}
+		if err != nil {
+			// the files may be in the middle of being replaced
+			log.Println("reload TLS certificate error, keeping the previous one:", err)
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "LoadX509KeyPair")
+	}
+	if !r.loadedAt.IsZero() {
+		log.Printf("reloaded TLS certificate from %s", r.certFile)
+	}
+	r.cert = &cert
+	r.loadedAt = modTime
+	return nil
+}
+
+// modTime returns the later modification time of the two files.
+func (r *certReloader) modTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func writeSelfSignedIfMissing(hostPort, certFile, keyFile string) error {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+
+	certPEM, keyPEM, err := selfSignedCertificate(hostPort)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		path string
+		data []byte
+		perm os.FileMode
+	}{
+		{certFile, certPEM, 0644},
+		{keyFile, keyPEM, 0600},
+	} {
+		err = os.MkdirAll(filepath.Dir(f.path), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(f.path, f.data, f.perm)
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("wrote self-signed TLS certificate to %s", certFile)
+	return nil
+}
+
+// selfSignedCertificate returns a PEM encoded certificate and key for
+// development that is valid for localhost and the host of hostPort.
+func selfSignedCertificate(hostPort string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"foundation development"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	host, _, _ := net.SplitHostPort(hostPort)
+	if ip := net.ParseIP(host); ip != nil {
+		if !ip.IsUnspecified() && !ip.IsLoopback() {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		}
+	} else if host != "" && host != "localhost" {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// httpsRedirect redirects all requests to the same URL on the HTTPS port
+// of the main server.
+func httpsRedirect(hostPort string) http.Handler {
+	_, port, _ := net.SplitHostPort(hostPort)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	certPEM, keyPEM, err := selfSignedCertificate("example.test:8443")
+	if err != nil {
+		t.Fatalf("selfSignedCertificate failed: %v", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair failed: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "example.test", "127.0.0.1"} {
+		err = leaf.VerifyHostname(host)
+		if err != nil {
+			t.Errorf("certificate not valid for %s: %v", host, err)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	err := writeSelfSignedIfMissing("localhost:0", certFile, keyFile)
+	if err != nil {
+		t.Fatalf("writeSelfSignedIfMissing failed: %v", err)
+	}
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("newCertReloader failed: %v", err)
+	}
+	first, _ := r.GetCertificate(nil)
+
+	// replace the files and make sure the change is detected
+	os.Remove(certFile)
+	os.Remove(keyFile)
+	err = writeSelfSignedIfMissing("localhost:0", certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	r.checked = time.Time{}
+
+	second, _ := r.GetCertificate(nil)
+	if first == second {
+		t.Error("expected the certificate to be reloaded")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	testCases := []struct {
+		hostPort string
+		host     string
+		expected string
+	}{
+		{":443", "example.com", "https://example.com/a?b=c"},
+		{":443", "example.com:80", "https://example.com/a?b=c"},
+		{"localhost:3443", "localhost:3000", "https://localhost:3443/a?b=c"},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/a?b=c", nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		httpsRedirect(tc.hostPort).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusMovedPermanently {
+			t.Errorf("expected status 301, got %d", rec.Code)
+		}
+		if location := rec.Header().Get("Location"); location != tc.expected {
+			t.Errorf("expected redirect to %s, got %s", tc.expected, location)
+		}
+	}
+}
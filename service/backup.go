@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/db"
+	"github.com/pkg/errors"
+)
+
+// Backup creates a snapshot of the database in the configured BackupDir.
+// It is safe to run while the app is serving requests.
+func Backup(config *foundation.Config) error {
+	database, err := openDB(config)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	snapshot, err := database.Backups.Create(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "Backups.Create")
+	}
+	log.Printf("backup written to %s (%d bytes)", snapshot.Path, snapshot.Size)
+	return nil
+}
+
+// Restore replaces the database with the named snapshot from BackupDir, or
+// with the newest one if name is empty. The app must not be running.
+func Restore(config *foundation.Config, name string) error {
+	if config.BackupDir == "" {
+		return db.ErrBackupsDisabled
+	}
+
+	var path string
+	if name != "" {
+		path = filepath.Join(config.BackupDir, filepath.Base(name))
+	} else {
+		latest, err := latestSnapshot(config)
+		if err != nil {
+			return err
+		}
+		path = latest
+	}
+
+	err := db.RestoreSnapshot(path, config.DBPath)
+	if err != nil {
+		return errors.Wrapf(err, "restore %s", path)
+	}
+	log.Printf("restored %s from %s", config.DBPath, path)
+	return nil
+}
+
+func latestSnapshot(config *foundation.Config) (string, error) {
+	snapshots, err := db.ListSnapshots(config)
+	if err != nil {
+		return "", errors.Wrap(err, "ListSnapshots")
+	}
+	if len(snapshots) == 0 {
+		return "", errors.Errorf("no snapshots in %s", config.BackupDir)
+	}
+	return snapshots[0].Path, nil
+}
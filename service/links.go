@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/db"
+	"github.com/pkg/errors"
+)
+
+// ExportLinks writes all links, or only those tagged with tag, to path, as
+// CSV or JSON depending on its extension. It is safe to run while the app
+// is serving requests.
+func ExportLinks(config *foundation.Config, path, tag string) error {
+	format, err := db.FileFormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	database, err := openDB(config)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	records, err := database.Links.Export(context.Background(), tag)
+	if err != nil {
+		return errors.Wrap(err, "Links.Export")
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "Create")
+	}
+	err = db.WriteLinkRecords(file, format, records)
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "WriteLinkRecords")
+	}
+	err = file.Close()
+	if err != nil {
+		return errors.Wrap(err, "Close")
+	}
+	fmt.Printf("exported %d links to %s\n", len(records), path)
+	return nil
+}
+
+// ImportLinks creates and updates links from the CSV or JSON file at path.
+// New links without an owner in the file belong to the user owner. With
+// dryRun, the changes are only printed.
+func ImportLinks(config *foundation.Config, path, owner string, dryRun bool) error {
+	format, err := db.FileFormatOf(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "Open")
+	}
+	defer file.Close()
+	records, err := db.ReadLinkRecords(file, format)
+	if err != nil {
+		return errors.Wrapf(err, "read %s", path)
+	}
+
+	database, err := openDB(config)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	var ownerID int64
+	if owner != "" {
+		user, err := database.Users.ByUsername(ctx, owner)
+		if err != nil {
+			return errors.Wrapf(err, "owner %q", owner)
+		}
+		ownerID = user.ID
+	}
+
+	result, err := database.Links.Import(ctx, records, ownerID, dryRun)
+	if err != nil {
+		return errors.Wrap(err, "Links.Import")
+	}
+	printLinkImport(os.Stdout, result)
+	if result.Conflicts > 0 {
+		return errors.Errorf("%d conflicts, nothing was imported", result.Conflicts)
+	}
+	return nil
+}
+
+func printLinkImport(w io.Writer, result *db.LinkImport) {
+	for _, r := range result.Results {
+		if r.Action == db.ImportUnchanged {
+			continue
+		}
+		fmt.Fprintf(w, "%-9s %s %s %s\n", r.Action, r.Record.ShortLink, r.Record.FullURL, r.Reason)
+	}
+	status := "not applied"
+	if result.Applied {
+		status = "applied"
+	}
+	fmt.Fprintf(w, "%d created, %d updated, %d unchanged, %d conflicts, %s\n",
+		result.Creates, result.Updates, result.Unchanged, result.Conflicts, status)
+}
+
+// openDB opens the database for a command, without the background jobs
+// of the app, which may be running at the same time.
+func openDB(config *foundation.Config) (*db.DB, error) {
+	ctx := &foundation.Context{
+		Context: context.Background(),
+		Config:  config,
+	}
+	database, err := db.OpenDB(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "OpenDB")
+	}
+	return database, nil
+}
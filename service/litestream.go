@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/server/health"
+	"github.com/pkg/errors"
+)
+
+// maxReplicationLag is how far the litestream shadow WAL may fall behind
+// the database WAL before the readiness check fails.
+var maxReplicationLag = time.Minute
+
+// litestream supervises the "litestream replicate" process and reports
+// its replication state.
+type litestream struct {
+	*supervisor
+	dbPath string
+}
+
+func restoreLitestreamIfNeeded(ctx *foundation.Context) error {
+	if _, err := os.Stat(ctx.Config.DBPath); os.IsNotExist(err) {
+		// Database doesn't exist, restore from replica
+		cmd := exec.Command("litestream", "restore", "-config",
+			ctx.Config.LitestreamYml, ctx.Config.DBPath)
+		cmd.Stdout = &lineLogger{component: "litestream restore", stream: "stdout"}
+		cmd.Stderr = &lineLogger{component: "litestream restore", stream: "stderr"}
+
+		err := cmd.Run()
+		flushOutput(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to restore database: %w", err)
+		}
+	}
+	return nil
+}
+
+func startLitestream(ctx *foundation.Context) (*litestream, error) {
+	ls := &litestream{
+		supervisor: newSupervisor("litestream", "litestream", "replicate", "-config", ctx.Config.LitestreamYml),
+		dbPath:     ctx.Config.DBPath,
+	}
+	err := ls.Start()
+	if err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+// Check is a readiness check that fails if the litestream process is not
+// running or its replication lags behind the database WAL.
+func (l *litestream) Check(ctx context.Context) error {
+	err := l.checkRunning()
+	if err != nil {
+		return err
+	}
+
+	walInfo, err := os.Stat(l.dbPath + "-wal")
+	if os.IsNotExist(err) {
+		// nothing written yet, so there is nothing to replicate
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "stat WAL")
+	}
+
+	replicated, err := lastModified(shadowDir(l.dbPath))
+	if err != nil {
+		return errors.Wrap(err, "shadow WAL")
+	}
+	if replicated.IsZero() {
+		l.mutex.Lock()
+		startedAt := l.startedAt
+		l.mutex.Unlock()
+		if time.Since(startedAt) > maxReplicationLag {
+			return errors.New("no replication since start")
+		}
+		return nil
+	}
+
+	lag := walInfo.ModTime().Sub(replicated)
+	if lag > maxReplicationLag {
+		return errors.Errorf("replication lags %s behind", lag.Round(time.Second))
+	}
+	return nil
+}
+
+func (l *litestream) Status() []health.Field {
+	fields := l.supervisor.Status()
+	replicated, err := lastModified(shadowDir(l.dbPath))
+	if err == nil {
+		fields = append(fields, health.Field{Name: "Last replicated", Value: formatTime(replicated)})
+	}
+	return fields
+}
+
+// shadowDir is the directory where litestream keeps its shadow WAL,
+// "dir/.name.db-litestream" for the database "dir/name.db".
+func shadowDir(dbPath string) string {
+	dir, name := filepath.Split(dbPath)
+	return filepath.Join(dir, "."+name+"-litestream")
+}
+
+func lastModified(dir string) (time.Time, error) {
+	var last time.Time
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	return last, err
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/mbertschler/foundation"
+)
+
+// logLevel is shared by the default slog handler, so that changing it takes
+// effect immediately for all loggers.
+var logLevel = new(slog.LevelVar)
+
+// setupLogging routes slog and the standard log package through a text
+// handler with the configured level. Standard log messages have level info.
+func setupLogging(config *foundation.Config) {
+	setLogLevel(config.LogLevel)
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		AddSource:   log.Flags()&(log.Lshortfile|log.Llongfile) != 0,
+		Level:       logLevel,
+		ReplaceAttr: shortSource,
+	})
+	slog.SetDefault(slog.New(handler))
+}
+
+func setLogLevel(level string) {
+	parsed, err := foundation.ParseLogLevel(level)
+	if err != nil {
+		// the config is validated, so this shouldn't happen
+		log.Println("setLogLevel error:", err)
+		return
+	}
+	if parsed != logLevel.Level() {
+		logLevel.Set(parsed)
+		log.Println("log level set to", parsed)
+	}
+}
+
+// shortSource logs only the file name and line like log.Lshortfile.
+func shortSource(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.SourceKey && len(groups) == 0 {
+		if source, ok := a.Value.Any().(*slog.Source); ok {
+			source.File = filepath.Base(source.File)
+		}
+	}
+	return a
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mbertschler/foundation"
+	"github.com/mbertschler/foundation/server/health"
+)
+
+// ConfigPollInterval is how often the config file is checked for changes.
+var ConfigPollInterval = 2 * time.Second
+
+// ConfigSource describes where the config came from, so that it can be
+// loaded again while the app is running.
+type ConfigSource struct {
+	// Path is the config file that is watched for changes. If it is empty,
+	// the config is only reloaded on SIGHUP.
+	Path string
+	// Load builds the config again from all layers.
+	Load func() (*foundation.Config, error)
+}
+
+// configReloader reloads the config when the file changes or on SIGHUP,
+// and applies the reloadable settings to the current config snapshot.
+// Changes to other settings are reported as needing a restart.
+type configReloader struct {
+	context *foundation.Context
+	source  *ConfigSource
+
+	mutex      sync.Mutex
+	modTime    time.Time
+	reloads    int
+	lastReload time.Time
+	lastErr    error
+	restart    []string
+}
+
+func newConfigReloader(context *foundation.Context, source *ConfigSource) *configReloader {
+	r := &configReloader{
+		context: context,
+		source:  source,
+	}
+	r.modTime, _ = r.fileModTime()
+	return r
+}
+
+func (r *configReloader) start(done <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		ticker := time.NewTicker(ConfigPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-hup:
+				log.Println("SIGHUP received, reloading config")
+				r.Reload()
+			case <-ticker.C:
+				if r.fileChanged() {
+					log.Println("config file changed, reloading config")
+					r.Reload()
+				}
+			}
+		}
+	}()
+}
+
+func (r *configReloader) fileModTime() (time.Time, error) {
+	if r.source.Path == "" {
+		return time.Time{}, nil
+	}
+	info, err := os.Stat(r.source.Path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+func (r *configReloader) fileChanged() bool {
+	modTime, err := r.fileModTime()
+	if err != nil {
+		// the file may be in the middle of being replaced
+		return false
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if modTime.Equal(r.modTime) {
+		return false
+	}
+	r.modTime = modTime
+	return true
+}
+
+// Reload loads and validates the config and applies the reloadable
+// settings. An invalid config is logged and leaves the current one in place.
+func (r *configReloader) Reload() error {
+	next, err := r.source.Load()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastReload = time.Now()
+	r.lastErr = err
+	if err != nil {
+		log.Println("config reload error, keeping the current config:", err)
+		return err
+	}
+	r.reloads++
+
+	config, applied, restart := r.context.Current().Reload(next)
+	setLogLevel(config.LogLevel)
+	r.context.SetCurrent(config)
+	r.restart = restart
+
+	if len(applied) > 0 {
+		log.Println("config reloaded, applied:", strings.Join(applied, ", "))
+	} else {
+		log.Println("config reloaded, no reloadable settings changed")
+	}
+	if len(restart) > 0 {
+		log.Println("config changes that need a restart:", strings.Join(restart, ", "))
+	}
+	return nil
+}
+
+// Check never fails, an invalid config file doesn't affect the running app.
+func (r *configReloader) Check(ctx context.Context) error {
+	return nil
+}
+
+func (r *configReloader) Status() []health.Field {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	watching := r.source.Path
+	if watching == "" {
+		watching = "SIGHUP only"
+	}
+	fields := []health.Field{
+		{Name: "Watching", Value: watching},
+		{Name: "Reloads", Value: fmt.Sprint(r.reloads)},
+		{Name: "Last reload", Value: formatTime(r.lastReload)},
+	}
+	if r.lastErr != nil {
+		fields = append(fields, health.Field{Name: "Last error", Value: r.lastErr.Error()})
+	}
+	if len(r.restart) > 0 {
+		fields = append(fields, health.Field{Name: "Needs restart", Value: strings.Join(r.restart, ", ")})
+	}
+	return fields
+}
@@ -2,20 +2,25 @@ package service
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/mbertschler/foundation"
 	"github.com/mbertschler/foundation/db"
 	"github.com/mbertschler/foundation/server"
 	"github.com/mbertschler/foundation/server/broadcast"
+	"github.com/mbertschler/foundation/server/health"
 )
 
-func RunApp(config *foundation.Config) int {
+// ShutdownTimeout is how long in flight requests get to finish on shutdown.
+var ShutdownTimeout = 10 * time.Second
+
+// RunApp runs the server until it receives SIGINT or SIGTERM. The config
+// is reloaded from source when the config file changes or on SIGHUP.
+func RunApp(config *foundation.Config, source *ConfigSource) int {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -23,10 +28,13 @@ func RunApp(config *foundation.Config) int {
 		Context: ctx,
 		Config:  config,
 	}
+	setupLogging(config)
 
 	broadcaster := broadcast.New()
+	checker := health.New()
 
 	var err error
+	var ls *litestream
 	if context.Config.LitestreamYml != "" {
 		err = restoreLitestreamIfNeeded(context)
 		if err != nil {
@@ -35,11 +43,15 @@ func RunApp(config *foundation.Config) int {
 			log.Println("restoreLitestreamIfNeeded error:", err)
 		}
 
-		err = startLitestream(context)
+		ls, err = startLitestream(context)
 		if err != nil {
 			log.Println("startLitestream error:", err)
 			return 1
 		}
+		// stops litestream if starting the app fails, on shutdown it is
+		// stopped before the final checkpoint
+		defer stopLitestream(ls)
+		checker.AddComponent("litestream", ls)
 	}
 
 	database, err := db.StartDB(context)
@@ -47,56 +59,89 @@ func RunApp(config *foundation.Config) int {
 		log.Println("StartDB error:", err)
 		return 1
 	}
+	checker.Add("sqlite", database.Ping)
+	checker.Add("migrations", database.CheckMigrations)
+
+	if source != nil {
+		reloader := newConfigReloader(context, source)
+		reloader.start(ctx.Done())
+		checker.AddComponent("config", reloader)
+	}
 
-	err = server.RunServer(context, database, broadcaster)
+	srv, err := server.NewServer(context, database, broadcaster, checker)
 	if err != nil {
-		log.Println("RunServer error:", err)
+		log.Println("NewServer error:", err)
 		return 1
 	}
 
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.Run()
+	}()
+
 	// ... rest of your app initialization
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	<-sigChan
-	log.Println("shutting down...")
+	exitCode := 0
+	select {
+	case <-sigChan:
+		log.Println("shutting down...")
+		checker.SetShuttingDown()
+		drainServer(context.Current().ShutdownDrain.Duration(), sigChan)
+	case err = <-serverErr:
+		log.Println("server.Run error:", err)
+		exitCode = 1
+		checker.SetShuttingDown()
+	}
+
+	err = shutdownServer(srv)
+	if err != nil {
+		log.Println("server.Shutdown error:", err)
+	}
+
+	// litestream syncs the WAL when it stops, after that the checkpoint
+	// doesn't have to wait for its read transaction
+	if ls != nil {
+		stopLitestream(ls)
+	}
+	err = database.Checkpoint(ctx)
+	if err != nil {
+		log.Println("DB.Checkpoint error:", err)
+	}
 	err = database.Close()
 	if err != nil {
 		log.Println("DB.Close error:", err)
 	}
-	return 0
+	return exitCode
 }
 
-func restoreLitestreamIfNeeded(ctx *foundation.Context) error {
-	if _, err := os.Stat(ctx.Config.DBPath); os.IsNotExist(err) {
-		// Database doesn't exist, restore from replica
-		cmd := exec.Command("litestream", "restore", "-config",
-			ctx.Config.LitestreamYml, ctx.Config.DBPath)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to restore database: %w", err)
-		}
+// drainServer keeps serving requests for the drain duration while /readyz
+// reports that the server is shutting down. A second signal skips it.
+func drainServer(drain time.Duration, sigChan <-chan os.Signal) {
+	if drain <= 0 {
+		return
+	}
+	log.Printf("draining for %s...", drain)
+	timer := time.NewTimer(drain)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-sigChan:
+		log.Println("skipping drain")
 	}
-	return nil
 }
 
-func startLitestream(ctx *foundation.Context) error {
-	cmd := exec.CommandContext(ctx.Context, "litestream", "replicate", "-config", ctx.Config.LitestreamYml)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func shutdownServer(srv *server.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
 
-	if err := cmd.Start(); err != nil {
-		return err
+func stopLitestream(ls *litestream) {
+	err := ls.Stop(ShutdownTimeout)
+	if err != nil {
+		log.Println("litestream Stop error:", err)
 	}
-
-	go func() {
-		if err := cmd.Wait(); err != nil {
-			log.Println("litestream exited with error:", err)
-		}
-	}()
-
-	return nil
 }
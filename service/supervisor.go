@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mbertschler/foundation/server/health"
+	"github.com/pkg/errors"
+)
+
+var errStopping = errors.New("supervisor is stopping")
+
+// supervisor keeps a child process running, restarting it with exponential
+// backoff whenever it exits until Stop is called.
+type supervisor struct {
+	name string
+	args []string
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+
+	mutex      sync.Mutex
+	cmd        *exec.Cmd
+	running    bool
+	stopping   bool
+	pid        int
+	restarts   int
+	startedAt  time.Time
+	lastExitAt time.Time
+	lastErr    error
+}
+
+func newSupervisor(name string, args ...string) *supervisor {
+	return &supervisor{
+		name:       name,
+		args:       args,
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start starts the process once and returns an error if that fails, for
+// example because the binary is missing. Later exits are handled by
+// restarting the process in the background.
+func (s *supervisor) Start() error {
+	cmd, err := s.startProcess()
+	if err != nil {
+		return err
+	}
+	go s.loop(cmd)
+	return nil
+}
+
+func (s *supervisor) startProcess() (*exec.Cmd, error) {
+	cmd := exec.Command(s.args[0], s.args[1:]...)
+	cmd.Stdout = &lineLogger{component: s.name, stream: "stdout"}
+	cmd.Stderr = &lineLogger{component: s.name, stream: "stderr"}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopping {
+		return nil, errStopping
+	}
+
+	err := cmd.Start()
+	if err != nil {
+		return nil, errors.Wrapf(err, "start %s", s.name)
+	}
+	s.cmd = cmd
+	s.running = true
+	s.pid = cmd.Process.Pid
+	s.startedAt = time.Now()
+	return cmd, nil
+}
+
+func (s *supervisor) loop(cmd *exec.Cmd) {
+	defer close(s.done)
+
+	backoff := s.minBackoff
+	for {
+		if cmd != nil {
+			err := cmd.Wait()
+			flushOutput(cmd)
+			s.mutex.Lock()
+			s.running = false
+			s.lastExitAt = time.Now()
+			s.lastErr = err
+			if err == nil {
+				s.lastErr = errors.New("exited with status 0")
+			}
+			ranFor := s.lastExitAt.Sub(s.startedAt)
+			s.mutex.Unlock()
+
+			// a process that ran for a while is considered healthy again
+			if ranFor > s.maxBackoff {
+				backoff = s.minBackoff
+			}
+		}
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		log.Printf("%s exited (%v), restarting in %s", s.name, s.lastError(), backoff)
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.maxBackoff)
+
+		s.mutex.Lock()
+		s.restarts++
+		s.mutex.Unlock()
+
+		var err error
+		cmd, err = s.startProcess()
+		if err == errStopping {
+			return
+		}
+		if err != nil {
+			s.mutex.Lock()
+			s.lastExitAt = time.Now()
+			s.lastErr = err
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// Stop asks the process to terminate with SIGTERM and kills it if it
+// hasn't exited after timeout.
+func (s *supervisor) Stop(timeout time.Duration) error {
+	s.mutex.Lock()
+	if s.stopping {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.stopping = true
+	close(s.stop)
+	cmd, running := s.cmd, s.running
+	s.mutex.Unlock()
+
+	if cmd == nil {
+		return nil
+	}
+	if running {
+		err := cmd.Process.Signal(syscall.SIGTERM)
+		if err != nil && !errors.Is(err, os.ErrProcessDone) {
+			log.Printf("%s SIGTERM error: %v", s.name, err)
+		}
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	// the process may have exited right at the timeout
+	s.mutex.Lock()
+	cmd, running = s.cmd, s.running
+	s.mutex.Unlock()
+	if !running {
+		<-s.done
+		return nil
+	}
+	err := cmd.Process.Kill()
+	<-s.done
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "kill %s", s.name)
+	}
+	return errors.Errorf("%s killed after %s", s.name, timeout)
+}
+
+func (s *supervisor) lastError() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastErr
+}
+
+// checkRunning returns an error if the process is currently not running.
+func (s *supervisor) checkRunning() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.running {
+		return nil
+	}
+	if s.lastErr != nil {
+		return errors.Wrapf(s.lastErr, "%s not running", s.name)
+	}
+	return errors.Errorf("%s not running", s.name)
+}
+
+func (s *supervisor) Status() []health.Field {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := "running"
+	switch {
+	case s.stopping:
+		state = "stopped"
+	case !s.running:
+		state = "restarting"
+	}
+
+	fields := []health.Field{
+		{Name: "State", Value: state},
+		{Name: "PID", Value: fmt.Sprint(s.pid)},
+		{Name: "Started", Value: formatTime(s.startedAt)},
+		{Name: "Restarts", Value: fmt.Sprint(s.restarts)},
+		{Name: "Last exit", Value: formatTime(s.lastExitAt)},
+	}
+	if s.lastErr != nil {
+		fields = append(fields, health.Field{Name: "Last error", Value: s.lastErr.Error()})
+	}
+	return fields
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// lineLogger writes every complete line of process output to the log, with
+// the component and the stream that it came from.
+type lineLogger struct {
+	component string
+	stream    string
+	buf       []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		l.log(l.buf[:i])
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs the last line if it didn't end with a newline.
+func (l *lineLogger) Flush() {
+	l.log(l.buf)
+	l.buf = nil
+}
+
+func (l *lineLogger) log(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) > 0 {
+		slog.Info(string(line), "component", l.component, "stream", l.stream)
+	}
+}
+
+// flushOutput flushes the loggers of a process that has exited, after
+// Wait has copied all of its output.
+func flushOutput(cmd *exec.Cmd) {
+	for _, w := range []any{cmd.Stdout, cmd.Stderr} {
+		if l, ok := w.(*lineLogger); ok {
+			l.Flush()
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSupervisorRestarts(t *testing.T) {
+	s := newSupervisor("test", "sh", "-c", "exit 1")
+	s.minBackoff = time.Millisecond
+	s.maxBackoff = 10 * time.Millisecond
+
+	err := s.Start()
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mutex.Lock()
+		restarts := s.restarts
+		s.mutex.Unlock()
+		if restarts >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at least 3 restarts, got %d", restarts)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	err = s.Stop(time.Second)
+	if err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if s.lastError() == nil {
+		t.Error("expected last error to be recorded")
+	}
+}
+
+func TestSupervisorStop(t *testing.T) {
+	s := newSupervisor("test", "sleep", "60")
+
+	err := s.Start()
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := s.checkRunning(); err != nil {
+		t.Fatalf("expected process to run: %v", err)
+	}
+
+	err = s.Stop(5 * time.Second)
+	if err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := s.checkRunning(); err == nil {
+		t.Error("expected process to be stopped")
+	}
+
+	s.mutex.Lock()
+	restarts := s.restarts
+	s.mutex.Unlock()
+	if restarts != 0 {
+		t.Errorf("stopped process should not restart, got %d restarts", restarts)
+	}
+}
+
+func TestSupervisorMissingBinary(t *testing.T) {
+	s := newSupervisor("test", "/nonexistent/binary")
+	if err := s.Start(); err == nil {
+		t.Error("expected error for missing binary")
+	}
+}
+
+func TestLineLoggerFlush(t *testing.T) {
+	var out bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&out, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	l := &lineLogger{component: "test", stream: "stderr"}
+	l.Write([]byte("first\nsec"))
+	l.Write([]byte("ond\r\nlast"))
+	if strings.Contains(out.String(), "last") {
+		t.Fatal("expected the incomplete line to be buffered")
+	}
+	l.Flush()
+	for _, line := range []string{"msg=first component=test stream=stderr", "msg=second ", "msg=last "} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("expected %q in the log, got %q", line, out.String())
+		}
+	}
+}